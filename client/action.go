@@ -0,0 +1,34 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+
+	daptinClient "github.com/daptin/daptin-go-client"
+)
+
+// ExecuteStream posts an action whose request body is read from body
+// instead of being marshalled up front, so large attribute values (such as
+// base64 file contents) never have to sit in memory. body must produce the
+// same {"Name","OnType","Attributes"} document that Execute sends.
+func (e *ExtendedClient) ExecuteStream(actionName, tableName string, body io.Reader) ([]daptinClient.DaptinActionResponse, error) {
+	u := e.Endpoint + "/action/" + url.PathEscape(tableName) + "/" + url.PathEscape(actionName)
+	slog.Debug("ExecuteStream", "url", u)
+
+	resp, err := e.nextRequest().SetBody(body).Post(u)
+	if err := e.checkResponse(resp, err); err != nil {
+		return nil, err
+	}
+
+	var responses []daptinClient.DaptinActionResponse
+	if len(resp.Body()) == 0 {
+		return responses, nil
+	}
+	if err := json.Unmarshal(resp.Body(), &responses); err != nil {
+		return nil, fmt.Errorf("parse action response: %w", err)
+	}
+	return responses, nil
+}
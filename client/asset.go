@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"strings"
 )
 
 // UploadAssetStream streams a file to Daptin's asset upload endpoint.
@@ -65,3 +66,104 @@ func (e *ExtendedClient) CompleteAssetUpload(entityName, referenceID, columnName
 	}
 	return data, nil
 }
+
+// DownloadAsset streams a file from an asset column into w. filename picks
+// one file of a multi-file column; empty means the column's first file.
+// It returns the content type the server reported and the bytes written.
+func (e *ExtendedClient) DownloadAsset(entityName, referenceID, columnName, filename string, w io.Writer) (string, int64, error) {
+	u := fmt.Sprintf("%s/asset/%s/%s/%s",
+		e.Endpoint,
+		url.PathEscape(entityName),
+		url.PathEscape(referenceID),
+		url.PathEscape(columnName),
+	)
+	if filename != "" {
+		u += "?file=" + url.QueryEscape(filename)
+	}
+
+	resp, err := e.nextRequest().
+		SetHeader("Accept", "*/*").
+		SetDoNotParseResponse(true).
+		Get(u)
+	if err != nil {
+		return "", 0, err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	if resp.StatusCode() >= 400 {
+		message, _ := io.ReadAll(io.LimitReader(body, 4096))
+		return "", 0, CheckStatusCode(resp.StatusCode(), strings.TrimSpace(string(message)))
+	}
+
+	n, err := io.Copy(w, body)
+	if err != nil {
+		return "", n, fmt.Errorf("read asset: %w", err)
+	}
+	return resp.Header().Get("Content-Type"), n, nil
+}
+
+// AssetChunk places one part of a file within a resumable asset upload.
+// UploadID is empty for the first chunk; the server assigns it.
+type AssetChunk struct {
+	UploadID string
+	Offset   int64
+	Length   int64
+	Total    int64
+	SHA256   string
+}
+
+// UploadAssetChunk sends bytes [Offset, Offset+Length) of a file to the
+// asset stream operation with a Content-Range header. Servers that support
+// ranges append the part to UploadID and report the bytes received so far;
+// see ParseChunkAck.
+func (e *ExtendedClient) UploadAssetChunk(entityName, referenceID, columnName, filename, contentType string, chunk AssetChunk, body io.Reader) (map[string]interface{}, error) {
+	u := fmt.Sprintf("%s/asset/%s/%s/%s/upload?operation=stream&filename=%s",
+		e.Endpoint,
+		url.PathEscape(entityName),
+		url.PathEscape(referenceID),
+		url.PathEscape(columnName),
+		url.QueryEscape(filename),
+	)
+	if chunk.UploadID != "" {
+		u += "&upload_id=" + url.QueryEscape(chunk.UploadID)
+	}
+
+	req := e.nextRequest().
+		SetHeader("Content-Type", contentType).
+		SetHeader("X-File-Type", contentType).
+		SetHeader("X-File-Size", fmt.Sprintf("%d", chunk.Total)).
+		SetHeader("Content-Range", fmt.Sprintf("bytes %d-%d/%d", chunk.Offset, chunk.Offset+chunk.Length-1, chunk.Total)).
+		SetBody(body)
+	if chunk.SHA256 != "" {
+		req.SetHeader("X-File-Sha256", chunk.SHA256)
+	}
+	resp, err := req.Post(u)
+	if err := e.checkResponse(resp, err); err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(resp.Body(), &data); err != nil {
+		return nil, fmt.Errorf("parse upload response: %w", err)
+	}
+	return data, nil
+}
+
+// ParseChunkAck reads the upload_id and the byte count the server has
+// stored from a chunk response. ranged is false when the server did not
+// report a count, i.e. it treated the chunk as a whole file.
+// Pure function.
+func ParseChunkAck(data map[string]interface{}) (uploadID string, received int64, ranged bool) {
+	uploadID, _ = data["upload_id"].(string)
+	for _, key := range []string{"received", "offset", "bytes_received"} {
+		switch v := data[key].(type) {
+		case float64:
+			return uploadID, int64(v), true
+		case int64:
+			return uploadID, v, true
+		case int:
+			return uploadID, int64(v), true
+		}
+	}
+	return uploadID, 0, false
+}
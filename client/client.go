@@ -83,6 +83,28 @@ func (e *ExtendedClient) FindOne(tableName, referenceId string, parameters dapti
 	return ParseSingleResponse(resp.Body())
 }
 
+// FindOneIncluded is FindOne that also returns the JSON:API "included" objects,
+// e.g. when parameters carry included_relations.
+func (e *ExtendedClient) FindOneIncluded(tableName, referenceId string, parameters daptinClient.DaptinQueryParameters) (daptinClient.JsonApiObject, []daptinClient.JsonApiObject, error) {
+	u := BuildFindOneURL(e.Endpoint, tableName, referenceId, parameters)
+	slog.Debug("FindOneIncluded", "url", u)
+
+	resp, err := e.nextRequest().Get(u)
+	if err := e.checkResponse(resp, err); err != nil {
+		return nil, nil, err
+	}
+
+	data, included, err := ParseIncludedResponse(resp.Body())
+	if err != nil {
+		return nil, nil, err
+	}
+	result := make([]daptinClient.JsonApiObject, 0, len(included))
+	for _, item := range included {
+		result = append(result, item)
+	}
+	return data, result, nil
+}
+
 // FindAll overrides the upstream to handle unsupported/malformed API responses
 // without panicking on JSON:API data shape assertions.
 func (e *ExtendedClient) FindAll(tableName string, parameters daptinClient.DaptinQueryParameters) ([]daptinClient.JsonApiObject, error) {
@@ -115,6 +137,56 @@ func (e *ExtendedClient) FindAll(tableName string, parameters daptinClient.Dapti
 	return result, nil
 }
 
+// FindAllPages fetches every page of a list, pageSize rows at a time, until a
+// short page comes back. page[number] and page[size] in parameters are overridden.
+func (e *ExtendedClient) FindAllPages(tableName string, parameters daptinClient.DaptinQueryParameters, pageSize int) ([]daptinClient.JsonApiObject, error) {
+	return collectPages(parameters, pageSize, func(params daptinClient.DaptinQueryParameters) ([]daptinClient.JsonApiObject, error) {
+		return e.FindAll(tableName, params)
+	})
+}
+
+func collectPages(parameters daptinClient.DaptinQueryParameters, pageSize int, fetch func(daptinClient.DaptinQueryParameters) ([]daptinClient.JsonApiObject, error)) ([]daptinClient.JsonApiObject, error) {
+	if pageSize <= 0 {
+		pageSize = 500
+	}
+	params := daptinClient.DaptinQueryParameters{}
+	for k, v := range parameters {
+		params[k] = v
+	}
+	params["page[size]"] = pageSize
+
+	var result []daptinClient.JsonApiObject
+	for page := 1; ; page++ {
+		params["page[number]"] = page
+		items, err := fetch(params)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+		slog.Debug("collected page", "page", page, "count", len(items))
+		if len(items) < pageSize {
+			return result, nil
+		}
+	}
+}
+
+// Count returns the total number of rows in a table visible to the caller.
+// It requests a single-row page and reads the total from the pagination links.
+func (e *ExtendedClient) Count(tableName string) (int64, error) {
+	u := BuildFindAllURL(e.Endpoint, tableName, map[string]interface{}{"page[size]": 1})
+	slog.Debug("Count", "url", u)
+
+	resp, err := e.nextRequest().Get(u)
+	if err := e.checkResponse(resp, err); err != nil {
+		return 0, err
+	}
+	total, ok := ParseListTotal(resp.Body())
+	if !ok {
+		return 0, fmt.Errorf("list response for %q has no total", tableName)
+	}
+	return total, nil
+}
+
 // Update overrides the upstream to handle error responses without panicking.
 func (e *ExtendedClient) Update(tableName, referenceId string, object daptinClient.JsonApiObject) (daptinClient.JsonApiObject, error) {
 	u := e.Endpoint + "/api/" + tableName + "/" + referenceId
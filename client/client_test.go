@@ -88,3 +88,105 @@ func TestFindAllMalformedResponseReturnsError(t *testing.T) {
 		t.Fatalf("expected malformed response error, got: %v", err)
 	}
 }
+
+func TestFindAllPagesFetchesUntilShortPage(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page[number]")
+		pages = append(pages, page)
+		if r.URL.Query().Get("page[size]") != "2" {
+			t.Fatalf("expected page[size]=2, got %q", r.URL.Query().Get("page[size]"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch page {
+		case "1":
+			_, _ = w.Write([]byte(`{"data":[{"id":"1"},{"id":"2"}]}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"data":[{"id":"3"}]}`))
+		default:
+			t.Fatalf("unexpected page %q", page)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL, "", false)
+	rows, err := c.FindAllPages("world", daptinClient.DaptinQueryParameters{"page[number]": 7}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	if strings.Join(pages, ",") != "1,2" {
+		t.Fatalf("unexpected pages requested: %v", pages)
+	}
+}
+
+func TestDownloadAssetStreamsBodyAndSelectsFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/asset/product/ref-1/gallery" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("file") != "front view.jpg" {
+			t.Fatalf("expected file query, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte("jpeg-bytes"))
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	c := New(server.URL, "", false)
+	contentType, n, err := c.DownloadAsset("product", "ref-1", "gallery", "front view.jpg", &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if contentType != "image/jpeg" || n != 10 || out.String() != "jpeg-bytes" {
+		t.Fatalf("got type %q, %d bytes, body %q", contentType, n, out.String())
+	}
+}
+
+func TestDownloadAssetNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such file", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	var out strings.Builder
+	_, _, err := New(server.URL, "", false).DownloadAsset("product", "ref-1", "photo", "", &out)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("error body written to output: %q", out.String())
+	}
+}
+
+func TestUploadAssetChunkSendsRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Range"); got != "bytes 4-7/10" {
+			t.Fatalf("Content-Range = %q", got)
+		}
+		if r.URL.Query().Get("upload_id") != "u1" || r.Header.Get("X-File-Sha256") != "abc" {
+			t.Fatalf("missing upload_id or checksum: %s %v", r.URL.RawQuery, r.Header)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"upload_id":"u1","received":8}`))
+	}))
+	defer server.Close()
+
+	data, err := New(server.URL, "", false).UploadAssetChunk("product", "ref-1", "video", "v.mp4", "video/mp4",
+		AssetChunk{UploadID: "u1", Offset: 4, Length: 4, Total: 10, SHA256: "abc"}, strings.NewReader("abcd"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if id, received, ranged := ParseChunkAck(data); id != "u1" || received != 8 || !ranged {
+		t.Fatalf("ParseChunkAck = %q, %d, %v", id, received, ranged)
+	}
+}
+
+func TestParseChunkAckWithoutCount(t *testing.T) {
+	if _, _, ranged := ParseChunkAck(map[string]interface{}{"upload_id": "u1"}); ranged {
+		t.Fatal("response without a byte count should not count as ranged")
+	}
+}
@@ -25,6 +25,30 @@ func ParseSingleResponse(body []byte) (map[string]interface{}, error) {
 	return data, nil
 }
 
+// ParseIncludedResponse parses a JSON:API single-object response body together
+// with its top-level "included" array (sent when included_relations is requested).
+// Pure function.
+func ParseIncludedResponse(body []byte) (map[string]interface{}, []map[string]interface{}, error) {
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("parse response: %w", err)
+	}
+
+	data, ok := envelope["data"].(map[string]interface{})
+	if !ok || data == nil {
+		return nil, nil, ErrNotFound
+	}
+	includedRaw, _ := envelope["included"].([]interface{})
+	included := make([]map[string]interface{}, 0, len(includedRaw))
+	for _, item := range includedRaw {
+		if m, ok := item.(map[string]interface{}); ok {
+			included = append(included, m)
+		}
+	}
+	slog.Debug("parsed included response", "keys", len(data), "included", len(included))
+	return data, included, nil
+}
+
 // ParseListResponse parses a JSON:API list response body.
 // Returns the "data" array or an error.
 func ParseListResponse(body []byte) ([]map[string]interface{}, error) {
@@ -48,6 +72,26 @@ func ParseListResponse(body []byte) ([]map[string]interface{}, error) {
 	return result, nil
 }
 
+// ParseListTotal extracts the total row count from a JSON:API list response.
+// Daptin reports it as links.total; meta.total is accepted as well.
+// Returns false when the response carries no total.
+func ParseListTotal(body []byte) (int64, bool) {
+	var envelope map[string]interface{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return 0, false
+	}
+	for _, section := range []string{"links", "meta"} {
+		block, ok := envelope[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if total, ok := block["total"].(float64); ok {
+			return int64(total), true
+		}
+	}
+	return 0, false
+}
+
 // ParseActionResponses parses action execution response body.
 func ParseActionResponses(body []byte) ([]ActionResponse, error) {
 	var responses []ActionResponse
@@ -63,6 +107,16 @@ type ActionResponse struct {
 	Attributes   map[string]interface{} `json:"Attributes"`
 }
 
+// StatusError is a failed HTTP response without a sentinel of its own.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Code, e.Body)
+}
+
 // CheckStatusCode maps HTTP status codes to sentinel errors.
 // Pure function.
 func CheckStatusCode(code int, body string) error {
@@ -77,7 +131,7 @@ func CheckStatusCode(code int, body string) error {
 	case code == 404:
 		return fmt.Errorf("%w: %s", ErrNotFound, body)
 	case code >= 400:
-		return fmt.Errorf("HTTP %d: %s", code, body)
+		return &StatusError{Code: code, Body: body}
 	}
 	return nil
 }
@@ -97,6 +151,21 @@ func BuildFindOneURL(endpoint, tableName, referenceId string, parameters map[str
 	return u
 }
 
+// BuildFindRelatedURL constructs the URL for a related-rows request.
+// Pure function.
+func BuildFindRelatedURL(endpoint, tableName, referenceId, relationColumn string, parameters map[string]interface{}) string {
+	u := endpoint + "/api/" + tableName + "/" + referenceId + "/" + relationColumn
+	if len(parameters) > 0 {
+		params := url.Values{}
+		for k, v := range parameters {
+			params.Set(k, fmt.Sprintf("%v", v))
+		}
+		u = u + "?" + params.Encode()
+	}
+	slog.Debug("built FindRelated URL", "url", u)
+	return u
+}
+
 // BuildFindAllURL constructs the URL for a list request.
 // Pure function.
 func BuildFindAllURL(endpoint, tableName string, parameters map[string]interface{}) string {
@@ -34,6 +34,27 @@ func TestParseSingleResponse_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestParseIncludedResponse(t *testing.T) {
+	body := []byte(`{"data":{"type":"document","id":"d1","attributes":{"name":"doc"}},"included":[{"type":"user_account","id":"u1"},"junk"]}`)
+	data, included, err := ParseIncludedResponse(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["id"] != "d1" {
+		t.Fatalf("unexpected data: %v", data)
+	}
+	if len(included) != 1 || included[0]["id"] != "u1" {
+		t.Fatalf("unexpected included: %v", included)
+	}
+}
+
+func TestParseIncludedResponse_NoIncluded(t *testing.T) {
+	_, included, err := ParseIncludedResponse([]byte(`{"data":{"id":"d1"}}`))
+	if err != nil || len(included) != 0 {
+		t.Fatalf("expected empty included, got %v %v", included, err)
+	}
+}
+
 func TestParseListResponse_ValidData(t *testing.T) {
 	body := []byte(`{"data":[{"id":"a","type":"t"},{"id":"b","type":"t"}]}`)
 	items, err := ParseListResponse(body)
@@ -48,6 +69,20 @@ func TestParseListResponse_ValidData(t *testing.T) {
 	}
 }
 
+func TestParseListTotal_Links(t *testing.T) {
+	body := []byte(`{"data":[],"links":{"current_page":1,"total":42}}`)
+	total, ok := ParseListTotal(body)
+	if !ok || total != 42 {
+		t.Fatalf("expected total 42, got %d %v", total, ok)
+	}
+}
+
+func TestParseListTotal_Missing(t *testing.T) {
+	if _, ok := ParseListTotal([]byte(`{"data":[]}`)); ok {
+		t.Fatal("expected no total")
+	}
+}
+
 func TestParseListResponse_EmptyArray(t *testing.T) {
 	body := []byte(`{"data":[]}`)
 	items, err := ParseListResponse(body)
@@ -150,6 +185,29 @@ func TestBuildFindAllURL_WithParams(t *testing.T) {
 	}
 }
 
+func TestBuildFindRelatedURL_WithParams(t *testing.T) {
+	params := map[string]interface{}{
+		"page[size]": 20,
+		"sort":       "-created_at",
+	}
+	u := BuildFindRelatedURL("http://localhost:6336", "user_account", "abc", "usergroup_id", params)
+	expected := `http://localhost:6336/api/user_account/abc/usergroup_id?page%5Bsize%5D=20&sort=-created_at`
+	if u != expected {
+		t.Errorf("expected %s, got %s", expected, u)
+	}
+}
+
+func TestBuildRelationshipBody(t *testing.T) {
+	body := BuildRelationshipBody("usergroup", []string{"a", "b"})
+	data, ok := body["data"].([]map[string]interface{})
+	if !ok || len(data) != 2 {
+		t.Fatalf("unexpected body: %#v", body)
+	}
+	if data[1]["type"] != "usergroup" || data[1]["id"] != "b" {
+		t.Errorf("unexpected identifier: %#v", data[1])
+	}
+}
+
 func TestMapArray(t *testing.T) {
 	objects := []daptinClient.JsonApiObject{
 		{"id": "1", "attributes": map[string]interface{}{"name": "a"}},
@@ -168,3 +226,13 @@ func TestMapArray(t *testing.T) {
 		t.Errorf("expected b, got %v", result[1]["name"])
 	}
 }
+
+func TestCheckStatusCodeStatusError(t *testing.T) {
+	var statusErr *StatusError
+	if err := CheckStatusCode(410, "gone"); !errors.As(err, &statusErr) || statusErr.Code != 410 {
+		t.Fatalf("expected StatusError 410, got %v", err)
+	}
+	if err := CheckStatusCode(410, "gone"); err.Error() != "HTTP 410: gone" {
+		t.Fatalf("unexpected message %q", err)
+	}
+}
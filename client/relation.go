@@ -10,13 +10,12 @@ import (
 
 // FindRelated fetches related rows via a relationship column.
 // GET /api/{entity}/{referenceId}/{relationColumn}
+// Parameters (page[size], page[number], sort, query, ...) are passed through to the server.
 func (e *ExtendedClient) FindRelated(entityName, referenceId, relationColumn string, parameters daptinClient.DaptinQueryParameters) ([]daptinClient.JsonApiObject, error) {
-	req := e.nextRequest()
-
-	u := e.Endpoint + "/api/" + entityName + "/" + referenceId + "/" + relationColumn
+	u := BuildFindRelatedURL(e.Endpoint, entityName, referenceId, relationColumn, parameters)
 	slog.Debug("FindRelated", "url", u)
 
-	resp, err := req.Get(u)
+	resp, err := e.nextRequest().Get(u)
 	if err := e.checkResponse(resp, err); err != nil {
 		return nil, err
 	}
@@ -40,17 +39,24 @@ func (e *ExtendedClient) FindRelated(entityName, referenceId, relationColumn str
 	return result, nil
 }
 
+// FindRelatedPages fetches every page of related rows, pageSize rows at a time.
+func (e *ExtendedClient) FindRelatedPages(entityName, referenceId, relationColumn string, parameters daptinClient.DaptinQueryParameters, pageSize int) ([]daptinClient.JsonApiObject, error) {
+	return collectPages(parameters, pageSize, func(params daptinClient.DaptinQueryParameters) ([]daptinClient.JsonApiObject, error) {
+		return e.FindRelated(entityName, referenceId, relationColumn, params)
+	})
+}
+
 // AddRelation associates a target entity with a source via JSON:API relationships endpoint.
 // PATCH /api/{entity}/{referenceId}/relationships/{relationColumn}
 func (e *ExtendedClient) AddRelation(entityName, referenceId, relationColumn, targetType, targetRefId string) error {
-	slog.Debug("AddRelation", "entity", entityName, "ref", referenceId, "relation", relationColumn, "target_type", targetType, "target_ref", targetRefId)
-	body := map[string]interface{}{
-		"data": []map[string]interface{}{
-			{"type": targetType, "id": targetRefId},
-		},
-	}
+	return e.AddRelations(entityName, referenceId, relationColumn, targetType, []string{targetRefId})
+}
 
-	resp, err := e.nextRequest().SetBody(body).Patch(
+// AddRelations associates many targets with a source in a single relationships request.
+// PATCH /api/{entity}/{referenceId}/relationships/{relationColumn}
+func (e *ExtendedClient) AddRelations(entityName, referenceId, relationColumn, targetType string, targetRefIds []string) error {
+	slog.Debug("AddRelations", "entity", entityName, "ref", referenceId, "relation", relationColumn, "target_type", targetType, "count", len(targetRefIds))
+	resp, err := e.nextRequest().SetBody(BuildRelationshipBody(targetType, targetRefIds)).Patch(
 		e.Endpoint + "/api/" + entityName + "/" + referenceId + "/relationships/" + relationColumn,
 	)
 	return e.checkResponse(resp, err)
@@ -59,15 +65,25 @@ func (e *ExtendedClient) AddRelation(entityName, referenceId, relationColumn, ta
 // RemoveRelation removes a relationship association via JSON:API relationships endpoint.
 // DELETE /api/{entity}/{referenceId}/relationships/{relationColumn}
 func (e *ExtendedClient) RemoveRelation(entityName, referenceId, relationColumn, targetType, targetRefId string) error {
-	slog.Debug("RemoveRelation", "entity", entityName, "ref", referenceId, "relation", relationColumn, "target_type", targetType, "target_ref", targetRefId)
-	body := map[string]interface{}{
-		"data": []map[string]interface{}{
-			{"type": targetType, "id": targetRefId},
-		},
-	}
+	return e.RemoveRelations(entityName, referenceId, relationColumn, targetType, []string{targetRefId})
+}
 
-	resp, err := e.nextRequest().SetBody(body).Delete(
+// RemoveRelations removes many associations in a single relationships request.
+// DELETE /api/{entity}/{referenceId}/relationships/{relationColumn}
+func (e *ExtendedClient) RemoveRelations(entityName, referenceId, relationColumn, targetType string, targetRefIds []string) error {
+	slog.Debug("RemoveRelations", "entity", entityName, "ref", referenceId, "relation", relationColumn, "target_type", targetType, "count", len(targetRefIds))
+	resp, err := e.nextRequest().SetBody(BuildRelationshipBody(targetType, targetRefIds)).Delete(
 		e.Endpoint + "/api/" + entityName + "/" + referenceId + "/relationships/" + relationColumn,
 	)
 	return e.checkResponse(resp, err)
 }
+
+// BuildRelationshipBody builds a JSON:API resource identifier list body.
+// Pure function.
+func BuildRelationshipBody(targetType string, targetRefIds []string) map[string]interface{} {
+	data := make([]map[string]interface{}, 0, len(targetRefIds))
+	for _, id := range targetRefIds {
+		data = append(data, map[string]interface{}{"type": targetType, "id": id})
+	}
+	return map[string]interface{}{"data": data}
+}
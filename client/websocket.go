@@ -1,23 +1,49 @@
 package client
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// wsInboxSize bounds buffered events and unmatched responses for ReadMessage.
+const wsInboxSize = 1024
+
 // WSConn wraps a WebSocket connection to a Daptin /live endpoint.
+//
+// A single reader goroutine owns the socket's read side. Responses to
+// requests made with Send are routed to a per-id waiter; everything else
+// (events, pongs, responses to SendNoWait) goes to the inbox read by
+// ReadMessage and to every channel returned by Subscribe. Writes are
+// serialized, so any goroutine may send while another is reading.
 type WSConn struct {
 	conn   *websocket.Conn
 	nextID atomic.Int64
+	// gorilla/websocket allows one concurrent writer
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	waiters     map[string]chan map[string]interface{}
+	subscribers map[int]chan map[string]interface{}
+	nextSubID   int
+
+	inbox     chan map[string]interface{}
+	dropped   atomic.Int64
+	lastRead  atomic.Int64
+	done      chan struct{}
+	readErr   error
+	closeOnce sync.Once
 }
 
 // DialWebSocket connects to the Daptin WebSocket endpoint, performs the
@@ -40,10 +66,8 @@ func DialWebSocket(endpoint, authToken string) (*WSConn, error) {
 		return nil, fmt.Errorf("websocket dial: %w", err)
 	}
 
-	ws := &WSConn{conn: conn}
-
-	// Read and validate session-open message
-	msg, err := ws.ReadMessage()
+	// Read and validate session-open message before the reader starts
+	msg, err := readJSONMessage(conn)
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("reading session-open: %w", err)
@@ -53,64 +77,213 @@ func DialWebSocket(endpoint, authToken string) (*WSConn, error) {
 		return nil, fmt.Errorf("expected session-open, got: %v", msg)
 	}
 
+	ws := &WSConn{
+		conn:        conn,
+		waiters:     map[string]chan map[string]interface{}{},
+		subscribers: map[int]chan map[string]interface{}{},
+		inbox:       make(chan map[string]interface{}, wsInboxSize),
+		done:        make(chan struct{}),
+	}
+	ws.lastRead.Store(time.Now().UnixNano())
+	go ws.readLoop()
+
 	slog.Debug("websocket session open")
 	return ws, nil
 }
 
+func readJSONMessage(conn *websocket.Conn) (map[string]interface{}, error) {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (ws *WSConn) readLoop() {
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			slog.Debug("ws reader stopped", "error", err)
+			ws.readErr = err
+			ws.mu.Lock()
+			for id, sub := range ws.subscribers {
+				close(sub)
+				delete(ws.subscribers, id)
+			}
+			ws.mu.Unlock()
+			close(ws.done)
+			return
+		}
+		ws.lastRead.Store(time.Now().UnixNano())
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			slog.Warn("ws skipping undecodable message", "error", err)
+			continue
+		}
+		slog.Debug("ws read message", "type", msg["type"])
+		ws.dispatch(msg)
+	}
+}
+
+func (ws *WSConn) dispatch(msg map[string]interface{}) {
+	ws.mu.Lock()
+	if id, ok := msg["id"].(string); ok && msg["type"] == "response" {
+		if waiter, ok := ws.waiters[id]; ok {
+			ws.mu.Unlock()
+			// The waiter holds one response; a duplicate id is dropped
+			select {
+			case waiter <- msg:
+			default:
+			}
+			return
+		}
+	}
+	for id, sub := range ws.subscribers {
+		select {
+		case sub <- msg:
+		default:
+			slog.Warn("ws subscriber full, dropping message", "subscriber", id, "type", msg["type"])
+		}
+	}
+	ws.mu.Unlock()
+
+	select {
+	case ws.inbox <- msg:
+	default:
+		ws.dropped.Add(1)
+		slog.Warn("ws inbox full, dropping message", "type", msg["type"])
+	}
+}
+
+// Dropped returns how many messages were discarded because the inbox was
+// full.
+func (ws *WSConn) Dropped() int64 {
+	return ws.dropped.Load()
+}
+
+// LastRead returns when the reader last received a message, whether or not
+// anyone has consumed it yet.
+func (ws *WSConn) LastRead() time.Time {
+	return time.Unix(0, ws.lastRead.Load())
+}
+
 // Send sends a method call with attributes and returns the request ID.
+// The response is held for Wait / WaitResponse / WaitResponseTimeout.
 func (ws *WSConn) Send(method string, attrs map[string]interface{}) (string, error) {
 	id := strconv.FormatInt(ws.nextID.Add(1), 10)
+	// Register before writing so a fast response cannot miss its waiter
+	ws.mu.Lock()
+	ws.waiters[id] = make(chan map[string]interface{}, 1)
+	ws.mu.Unlock()
+	if err := ws.write(method, id, attrs); err != nil {
+		ws.forget(id)
+		return "", err
+	}
+	return id, nil
+}
+
+// SendNoWait sends a method call whose response nobody will wait for.
+// Any response is delivered to the inbox like an event.
+func (ws *WSConn) SendNoWait(method string, attrs map[string]interface{}) (string, error) {
+	id := strconv.FormatInt(ws.nextID.Add(1), 10)
+	if err := ws.write(method, id, attrs); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (ws *WSConn) write(method, id string, attrs map[string]interface{}) error {
 	slog.Debug("ws send", "method", method, "id", id)
 	msg := map[string]interface{}{
 		"id":         id,
 		"method":     method,
 		"attributes": attrs,
 	}
+	ws.writeMu.Lock()
 	err := ws.conn.WriteJSON(msg)
+	ws.writeMu.Unlock()
 	if err != nil {
-		return "", fmt.Errorf("websocket send: %w", err)
+		return fmt.Errorf("websocket send: %w", err)
 	}
-	return id, nil
+	return nil
+}
+
+func (ws *WSConn) forget(id string) {
+	ws.mu.Lock()
+	delete(ws.waiters, id)
+	ws.mu.Unlock()
 }
 
 // SendPing sends a keepalive ping.
 func (ws *WSConn) SendPing() error {
 	slog.Debug("ws sending ping")
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
 	return ws.conn.WriteJSON(map[string]interface{}{"method": "ping"})
 }
 
-// ReadMessage reads one JSON message from the connection.
-func (ws *WSConn) ReadMessage() (map[string]interface{}, error) {
-	_, data, err := ws.conn.ReadMessage()
-	if err != nil {
-		return nil, err
+// Wait blocks until the response to a Send with the given id arrives, the
+// context ends, or the connection closes. A response with ok=false is
+// returned together with a server error.
+func (ws *WSConn) Wait(ctx context.Context, id string) (map[string]interface{}, error) {
+	ws.mu.Lock()
+	waiter, ok := ws.waiters[id]
+	ws.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no pending request with id %s", id)
 	}
-	var msg map[string]interface{}
-	if err := json.Unmarshal(data, &msg); err != nil {
-		return nil, err
+	defer ws.forget(id)
+
+	select {
+	case msg := <-waiter:
+		return checkWSResponse(msg)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-ws.done:
+		// The response may have landed just before the reader stopped
+		select {
+		case msg := <-waiter:
+			return checkWSResponse(msg)
+		default:
+		}
+		return nil, ws.readErr
+	}
+}
+
+func checkWSResponse(msg map[string]interface{}) (map[string]interface{}, error) {
+	slog.Debug("ws matched response", "id", msg["id"])
+	if ok, _ := msg["ok"].(bool); !ok {
+		errMsg, _ := msg["error"].(string)
+		return msg, fmt.Errorf("server error: %s", errMsg)
 	}
-	slog.Debug("ws read message", "type", msg["type"])
 	return msg, nil
 }
 
-// WaitResponse reads messages until it finds a response matching the given ID.
-// Non-response messages (events, pongs) are passed to the handler if non-nil.
+// WaitResponse waits for the response matching the given ID.
+// Events read from the inbox meanwhile are passed to the handler if non-nil.
 func (ws *WSConn) WaitResponse(id string, eventHandler func(map[string]interface{})) (map[string]interface{}, error) {
+	if eventHandler == nil {
+		return ws.Wait(context.Background(), id)
+	}
+	ws.mu.Lock()
+	waiter, ok := ws.waiters[id]
+	ws.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no pending request with id %s", id)
+	}
 	for {
-		msg, err := ws.ReadMessage()
-		if err != nil {
-			return nil, err
-		}
-		if msg["type"] == "response" && msg["id"] == id {
-			slog.Debug("ws matched response", "id", id)
-			if ok, _ := msg["ok"].(bool); !ok {
-				errMsg, _ := msg["error"].(string)
-				return msg, fmt.Errorf("server error: %s", errMsg)
-			}
-			return msg, nil
-		}
-		if eventHandler != nil {
+		select {
+		case msg := <-waiter:
+			ws.forget(id)
+			return checkWSResponse(msg)
+		case msg := <-ws.inbox:
 			eventHandler(msg)
+		case <-ws.done:
+			return ws.Wait(context.Background(), id)
 		}
 	}
 }
@@ -119,55 +292,100 @@ func (ws *WSConn) WaitResponse(id string, eventHandler func(map[string]interface
 // arrives within the timeout. This is needed for fire-and-forget methods
 // like new-message where the server only responds on error.
 func (ws *WSConn) WaitResponseTimeout(id string, timeout time.Duration) (map[string]interface{}, error) {
-	ws.conn.SetReadDeadline(time.Now().Add(timeout))
-	defer ws.conn.SetReadDeadline(time.Time{})
-
-	for {
-		msg, err := ws.ReadMessage()
-		if err != nil {
-			if isTimeout(err) {
-				slog.Warn("ws response timeout", "id", id, "timeout", timeout)
-				return nil, nil
-			}
-			return nil, err
-		}
-		if msg["type"] == "response" && msg["id"] == id {
-			if ok, _ := msg["ok"].(bool); !ok {
-				errMsg, _ := msg["error"].(string)
-				return msg, fmt.Errorf("server error: %s", errMsg)
-			}
-			return msg, nil
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	msg, err := ws.Wait(ctx, id)
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Debug("ws response timeout", "id", id, "timeout", timeout)
+		return nil, nil
 	}
+	return msg, err
 }
 
-func isTimeout(err error) bool {
-	type netTimeout interface {
-		Timeout() bool
+// ReadMessage returns the next event or unmatched response from the inbox.
+func (ws *WSConn) ReadMessage() (map[string]interface{}, error) {
+	return ws.ReadMessageContext(context.Background())
+}
+
+// ReadMessageContext is ReadMessage bounded by a context.
+func (ws *WSConn) ReadMessageContext(ctx context.Context) (map[string]interface{}, error) {
+	// Drain buffered messages before reporting a closed connection
+	select {
+	case msg := <-ws.inbox:
+		return msg, nil
+	default:
 	}
-	if t, ok := err.(netTimeout); ok {
-		return t.Timeout()
+	select {
+	case msg := <-ws.inbox:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-ws.done:
+		select {
+		case msg := <-ws.inbox:
+			return msg, nil
+		default:
+		}
+		return nil, ws.readErr
 	}
-	return false
 }
 
-// ReadMessageTimeout reads one JSON message with a deadline.
+// ReadMessageTimeout reads one message with a deadline.
 // Returns nil, nil on timeout.
 func (ws *WSConn) ReadMessageTimeout(timeout time.Duration) (map[string]interface{}, error) {
-	ws.conn.SetReadDeadline(time.Now().Add(timeout))
-	defer ws.conn.SetReadDeadline(time.Time{})
-	msg, err := ws.ReadMessage()
-	if err != nil && isTimeout(err) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	msg, err := ws.ReadMessageContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
 		slog.Warn("ws read timeout", "timeout", timeout)
 		return nil, nil
 	}
 	return msg, err
 }
 
-// Close closes the WebSocket connection.
+// Subscribe returns a channel receiving a copy of every event and unmatched
+// response, independent of the inbox. The channel is closed when the
+// connection ends or cancel is called. Slow subscribers drop messages.
+func (ws *WSConn) Subscribe(buffer int) (<-chan map[string]interface{}, func()) {
+	ch := make(chan map[string]interface{}, buffer)
+	ws.mu.Lock()
+	select {
+	case <-ws.done:
+		ws.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	default:
+	}
+	id := ws.nextSubID
+	ws.nextSubID++
+	ws.subscribers[id] = ch
+	ws.mu.Unlock()
+
+	cancel := func() {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		if sub, ok := ws.subscribers[id]; ok {
+			delete(ws.subscribers, id)
+			close(sub)
+		}
+	}
+	return ch, cancel
+}
+
+// Done is closed once the reader has stopped.
+func (ws *WSConn) Done() <-chan struct{} {
+	return ws.done
+}
+
+// Close closes the WebSocket connection; the reader then stops and pending
+// waits return the read error.
 func (ws *WSConn) Close() error {
-	slog.Debug("ws closing connection")
-	return ws.conn.Close()
+	var err error
+	ws.closeOnce.Do(func() {
+		slog.Debug("ws closing connection")
+		err = ws.conn.Close()
+	})
+	return err
 }
 
 // DecodeResponseData extracts the "data" field from a WS response.
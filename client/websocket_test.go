@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newEchoLiveServer acknowledges "echo" requests, ignores "silent" ones and
+// answers every request with an event first, so responses and events interleave.
+func newEchoLiveServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]interface{}{"type": "session", "status": "open"})
+		for {
+			var req map[string]interface{}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			conn.WriteJSON(map[string]interface{}{"type": "event", "for": req["id"]})
+			switch req["method"] {
+			case "echo":
+				conn.WriteJSON(map[string]interface{}{"type": "response", "id": req["id"], "ok": true})
+			case "fail":
+				conn.WriteJSON(map[string]interface{}{"type": "response", "id": req["id"], "ok": false, "error": "denied"})
+			}
+		}
+	}))
+}
+
+func TestWSConnConcurrentRequestsWhileStreaming(t *testing.T) {
+	server := newEchoLiveServer(t)
+	defer server.Close()
+	ws, err := DialWebSocket(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	events := make(chan struct{})
+	go func() {
+		defer close(events)
+		for i := 0; i < 20; i++ {
+			if _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := ws.Send("echo", nil)
+			if err != nil {
+				errs <- err
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			resp, err := ws.Wait(ctx, id)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if resp["id"] != id {
+				errs <- fmt.Errorf("response id %v for request %s", resp["id"], id)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatal("events were not delivered to the inbox")
+	}
+}
+
+func TestWSConnWaitErrors(t *testing.T) {
+	server := newEchoLiveServer(t)
+	defer server.Close()
+	ws, err := DialWebSocket(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	id, _ := ws.Send("fail", nil)
+	if _, err := ws.WaitResponseTimeout(id, time.Second); err == nil {
+		t.Fatal("expected server error")
+	}
+
+	id, _ = ws.Send("silent", nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ws.Wait(ctx, id); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestWSConnSendNoWaitResponseGoesToInbox(t *testing.T) {
+	server := newEchoLiveServer(t)
+	defer server.Close()
+	ws, err := DialWebSocket(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	id, err := ws.SendNoWait("echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		msg, err := ws.ReadMessageTimeout(time.Second)
+		if err != nil || msg == nil {
+			t.Fatalf("expected message, got %v %v", msg, err)
+		}
+		if msg["type"] == "response" && msg["id"] == id {
+			return
+		}
+	}
+	t.Fatal("response to SendNoWait did not reach the inbox")
+}
+
+func TestWSConnSubscribeFanOut(t *testing.T) {
+	server := newEchoLiveServer(t)
+	defer server.Close()
+	ws, err := DialWebSocket(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := ws.Subscribe(4)
+	second, cancelSecond := ws.Subscribe(4)
+	if _, err := ws.SendNoWait("silent", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, ch := range []<-chan map[string]interface{}{first, second} {
+		select {
+		case msg := <-ch:
+			if msg["type"] != "event" {
+				t.Fatalf("unexpected message %v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("subscriber did not receive the event")
+		}
+	}
+	cancelSecond()
+	if _, ok := <-second; ok {
+		t.Fatal("expected cancelled subscriber channel to be closed")
+	}
+
+	ws.Close()
+	select {
+	case _, ok := <-first:
+		if ok {
+			t.Fatal("expected subscriber channel closed after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber channel not closed after Close")
+	}
+}
+
+func TestWSConnCountsInboxDrops(t *testing.T) {
+	ws := &WSConn{
+		waiters:     map[string]chan map[string]interface{}{},
+		subscribers: map[int]chan map[string]interface{}{},
+		inbox:       make(chan map[string]interface{}, 1),
+	}
+	for i := 0; i < 3; i++ {
+		ws.dispatch(map[string]interface{}{"type": "event", "n": i})
+	}
+	if ws.Dropped() != 2 {
+		t.Fatalf("expected 2 dropped, got %d", ws.Dropped())
+	}
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// subscribeTimeout bounds the wait for a subscribe response, so a reconnect
+// cannot hang on a server that accepts the connection but never answers.
+var subscribeTimeout = 10 * time.Second
+
+// ErrWSClosed is returned by ReconnectingWS.Next after Close.
+var ErrWSClosed = errors.New("websocket closed")
+
+// ReconnectOptions configures a ReconnectingWS.
+type ReconnectOptions struct {
+	// Reconnect enables redialing after the connection drops.
+	Reconnect bool
+	// MaxRetries bounds consecutive reconnect attempts; 0 means unlimited.
+	MaxRetries   int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	// PingInterval sends a keepalive ping this often; 0 disables keepalive.
+	PingInterval time.Duration
+	// DeadAfter drops the connection when nothing (not even a pong) has
+	// arrived for this long. Defaults to three ping intervals.
+	DeadAfter time.Duration
+}
+
+// DefaultReconnectOptions returns the options used by ws listen/subscribe.
+func DefaultReconnectOptions() ReconnectOptions {
+	return ReconnectOptions{
+		Reconnect:    true,
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		PingInterval: 30 * time.Second,
+	}
+}
+
+// BackoffDelay returns the wait before reconnect attempt n (1-based):
+// initial, doubled for each further attempt, capped at max.
+// Pure function.
+func BackoffDelay(attempt int, initial, max time.Duration) time.Duration {
+	if attempt <= 0 || initial <= 0 {
+		return 0
+	}
+	delay := initial
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if max > 0 && delay >= max {
+			return max
+		}
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+type wsSubscription struct {
+	Topic   string
+	Filters map[string]interface{}
+}
+
+// ReconnectingWS is a WSConn that redials with exponential backoff when the
+// connection drops and re-subscribes every topic it was subscribed to.
+// Next is meant to be called from a single reading goroutine.
+type ReconnectingWS struct {
+	endpoint  string
+	authToken string
+	opts      ReconnectOptions
+
+	mu   sync.Mutex
+	conn *WSConn
+	subs []wsSubscription
+	// dropped counts inbox drops on connections already replaced
+	dropped int64
+
+	closed atomic.Bool
+	stop   chan struct{}
+}
+
+// DialReconnecting opens the first connection; the initial dial is not retried
+// so misconfiguration fails fast.
+func DialReconnecting(endpoint, authToken string, opts ReconnectOptions) (*ReconnectingWS, error) {
+	if opts.DeadAfter == 0 && opts.PingInterval > 0 {
+		opts.DeadAfter = 3 * opts.PingInterval
+	}
+	conn, err := DialWebSocket(endpoint, authToken)
+	if err != nil {
+		return nil, err
+	}
+	r := &ReconnectingWS{
+		endpoint:  endpoint,
+		authToken: authToken,
+		opts:      opts,
+		conn:      conn,
+		stop:      make(chan struct{}),
+	}
+	if opts.PingInterval > 0 {
+		go r.keepalive()
+	}
+	return r, nil
+}
+
+// Subscribe subscribes to a topic and remembers it for re-subscription.
+func (r *ReconnectingWS) Subscribe(topic string, filters map[string]interface{}) error {
+	if err := subscribeTopic(r.current(), topic, filters); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.subs = append(r.subs, wsSubscription{Topic: topic, Filters: filters})
+	r.mu.Unlock()
+	return nil
+}
+
+// Topics returns the subscribed topic names.
+func (r *ReconnectingWS) Topics() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	topics := make([]string, 0, len(r.subs))
+	for _, sub := range r.subs {
+		topics = append(topics, sub.Topic)
+	}
+	return topics
+}
+
+// Unsubscribe sends unsubscribe for every recorded topic without waiting.
+func (r *ReconnectingWS) Unsubscribe() {
+	conn := r.current()
+	for _, topic := range r.Topics() {
+		conn.SendNoWait("unsubscribe", map[string]interface{}{"topicName": topic})
+	}
+}
+
+// Next returns the next event. Pongs are swallowed. When the connection
+// drops and reconnect is enabled, Next redials, re-subscribes and returns a
+// {"type":"reconnected"} marker event before resuming the stream.
+func (r *ReconnectingWS) Next() (map[string]interface{}, error) {
+	for {
+		msg, err := r.current().ReadMessage()
+		if err == nil {
+			if msg["type"] == "pong" {
+				continue
+			}
+			return msg, nil
+		}
+		if r.closed.Load() {
+			return nil, ErrWSClosed
+		}
+		if !r.opts.Reconnect {
+			return nil, fmt.Errorf("connection lost: %w", err)
+		}
+		slog.Warn("ws connection lost, reconnecting", "error", err)
+		return r.reconnect(err)
+	}
+}
+
+func (r *ReconnectingWS) reconnect(cause error) (map[string]interface{}, error) {
+	lastErr := cause
+	for attempt := 1; r.opts.MaxRetries == 0 || attempt <= r.opts.MaxRetries; attempt++ {
+		delay := BackoffDelay(attempt, r.opts.InitialDelay, r.opts.MaxDelay)
+		slog.Info("ws reconnect attempt", "attempt", attempt, "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-r.stop:
+			return nil, ErrWSClosed
+		}
+
+		conn, err := DialWebSocket(r.endpoint, r.authToken)
+		if err != nil {
+			slog.Warn("ws reconnect failed", "attempt", attempt, "error", err)
+			lastErr = err
+			continue
+		}
+
+		r.mu.Lock()
+		subs := append([]wsSubscription(nil), r.subs...)
+		r.mu.Unlock()
+		topics := make([]string, 0, len(subs))
+		var subErr error
+		for _, sub := range subs {
+			if subErr = subscribeTopic(conn, sub.Topic, sub.Filters); subErr != nil {
+				break
+			}
+			topics = append(topics, sub.Topic)
+		}
+		if subErr != nil {
+			slog.Warn("ws resubscribe failed", "attempt", attempt, "error", subErr)
+			conn.Close()
+			lastErr = subErr
+			continue
+		}
+
+		r.mu.Lock()
+		old := r.conn
+		r.conn = conn
+		r.mu.Unlock()
+		old.Close()
+		r.mu.Lock()
+		r.dropped += old.Dropped()
+		r.mu.Unlock()
+		slog.Info("ws reconnected", "attempt", attempt, "topics", topics)
+		return map[string]interface{}{
+			"type":         "reconnected",
+			"attempt":      attempt,
+			"resubscribed": topics,
+			"time":         time.Now().UTC().Format(time.RFC3339),
+		}, nil
+	}
+	return nil, fmt.Errorf("reconnect failed after %d attempts: %w", r.opts.MaxRetries, lastErr)
+}
+
+// keepalive pings periodically and drops a connection that has gone quiet,
+// which makes the blocked read in Next fail and trigger a reconnect. Quiet
+// is measured at the connection's reader, so a consumer that is slow to
+// call Next does not make a healthy connection look dead.
+func (r *ReconnectingWS) keepalive() {
+	ticker := time.NewTicker(r.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+		conn := r.current()
+		idle := time.Since(conn.LastRead())
+		if r.opts.DeadAfter > 0 && idle > r.opts.DeadAfter {
+			slog.Warn("ws connection idle, dropping", "idle", idle)
+			conn.Close()
+			continue
+		}
+		if err := conn.SendPing(); err != nil {
+			slog.Debug("ws keepalive ping failed", "error", err)
+		}
+	}
+}
+
+// Dropped returns how many events were discarded because the reader fell
+// behind, across reconnects.
+func (r *ReconnectingWS) Dropped() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped + r.conn.Dropped()
+}
+
+func (r *ReconnectingWS) current() *WSConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.conn
+}
+
+// Close stops keepalive and reconnects and closes the current connection.
+func (r *ReconnectingWS) Close() error {
+	if r.closed.Swap(true) {
+		return nil
+	}
+	close(r.stop)
+	return r.current().Close()
+}
+
+func subscribeTopic(conn *WSConn, topic string, filters map[string]interface{}) error {
+	attrs := map[string]interface{}{
+		"topicName": topic,
+	}
+	if filters != nil {
+		attrs["filters"] = filters
+	}
+	id, err := conn.Send("subscribe", attrs)
+	if err != nil {
+		return err
+	}
+	resp, err := conn.WaitResponseTimeout(id, subscribeTimeout)
+	if err != nil {
+		return fmt.Errorf("subscribe to %s failed: %w", topic, err)
+	}
+	if resp == nil {
+		return fmt.Errorf("subscribe to %s: no response after %s", topic, subscribeTimeout)
+	}
+	return nil
+}
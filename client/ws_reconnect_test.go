@@ -0,0 +1,192 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	cases := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{10, 30 * time.Second},
+	}
+	for _, tc := range cases {
+		if got := BackoffDelay(tc.attempt, time.Second, 30*time.Second); got != tc.expected {
+			t.Fatalf("attempt %d: expected %v, got %v", tc.attempt, tc.expected, got)
+		}
+	}
+}
+
+// newLiveServer serves a minimal /live endpoint. The first connection is
+// dropped right after its subscribe is acknowledged; later connections
+// acknowledge the subscribe and then send one event.
+func newLiveServer(t *testing.T, subscribes *atomic.Int32) *httptest.Server {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	var connections atomic.Int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		n := connections.Add(1)
+		conn.WriteJSON(map[string]interface{}{"type": "session", "status": "open"})
+		for {
+			var req map[string]interface{}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req["method"] != "subscribe" {
+				continue
+			}
+			subscribes.Add(1)
+			conn.WriteJSON(map[string]interface{}{"type": "response", "id": req["id"], "ok": true})
+			if n == 1 {
+				return
+			}
+			conn.WriteJSON(map[string]interface{}{"type": "event", "topic": "document"})
+		}
+	}))
+}
+
+func TestReconnectingWSResubscribesAfterDrop(t *testing.T) {
+	var subscribes atomic.Int32
+	server := newLiveServer(t, &subscribes)
+	defer server.Close()
+
+	opts := ReconnectOptions{Reconnect: true, MaxRetries: 3, InitialDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	ws, err := DialReconnecting(server.URL, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := ws.Subscribe("document", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	marker, err := ws.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marker["type"] != "reconnected" {
+		t.Fatalf("expected reconnected marker, got %v", marker)
+	}
+	event, err := ws.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event["type"] != "event" {
+		t.Fatalf("expected event after reconnect, got %v", event)
+	}
+	if got := subscribes.Load(); got != 2 {
+		t.Fatalf("expected 2 subscribe calls, got %d", got)
+	}
+}
+
+func TestReconnectingWSWithoutReconnectReturnsError(t *testing.T) {
+	var subscribes atomic.Int32
+	server := newLiveServer(t, &subscribes)
+	defer server.Close()
+
+	ws, err := DialReconnecting(server.URL, "", ReconnectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := ws.Subscribe("document", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.Next(); err == nil {
+		t.Fatal("expected error when the connection drops with reconnect disabled")
+	}
+}
+
+func TestReconnectingWSSlowConsumerKeepsConnection(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	var connections atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		connections.Add(1)
+		conn.WriteJSON(map[string]interface{}{"type": "session", "status": "open"})
+		conn.WriteJSON(map[string]interface{}{"type": "event", "topic": "document"})
+		for {
+			var req map[string]interface{}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req["method"] == "ping" {
+				conn.WriteJSON(map[string]interface{}{"type": "pong"})
+			}
+		}
+	}))
+	defer server.Close()
+
+	opts := ReconnectOptions{Reconnect: true, InitialDelay: time.Millisecond, PingInterval: 10 * time.Millisecond, DeadAfter: 40 * time.Millisecond}
+	ws, err := DialReconnecting(server.URL, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	// Busy for several DeadAfter periods without calling Next
+	time.Sleep(200 * time.Millisecond)
+	msg, err := ws.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg["type"] != "event" || connections.Load() != 1 {
+		t.Fatalf("expected the first event on the first connection, got %v after %d connections", msg, connections.Load())
+	}
+}
+
+func TestSubscribeTopicTimesOut(t *testing.T) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(map[string]interface{}{"type": "session", "status": "open"})
+		// Never answer
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	defer func(timeout time.Duration) { subscribeTimeout = timeout }(subscribeTimeout)
+	subscribeTimeout = 50 * time.Millisecond
+	conn, err := DialWebSocket(server.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	done := make(chan error, 1)
+	go func() { done <- subscribeTopic(conn, "document", nil) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected a timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribe did not time out")
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"syscall"
 
 	"github.com/daptin/daptin-cli/client"
-	"github.com/daptin/daptin-cli/render"
 	daptinClient "github.com/daptin/daptin-go-client"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/term"
@@ -116,7 +115,7 @@ func applyEffects(effects []ResponseEffect, appCtx *AppContext) error {
 			if appCtx.Quiet {
 				continue
 			}
-			if _, ok := appCtx.Renderer.(*render.JsonRenderer); ok {
+			if appCtx.StructuredOutput() {
 				if err := appCtx.Renderer.RenderObject(e.Data); err != nil {
 					return err
 				}
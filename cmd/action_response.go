@@ -206,7 +206,9 @@ func boolValue(value interface{}) bool {
 	case bool:
 		return v
 	case string:
-		return strings.EqualFold(v, "true")
+		return strings.EqualFold(v, "true") || v == "1"
+	case float64:
+		return v != 0
 	default:
 		return false
 	}
@@ -19,6 +19,16 @@ type AppContext struct {
 	Quiet    bool
 }
 
+// StructuredOutput reports whether the selected renderer emits machine-readable
+// documents (json or yaml) rather than tables.
+func (a *AppContext) StructuredOutput() bool {
+	switch a.Renderer.(type) {
+	case *render.JsonRenderer, *render.YamlRenderer:
+		return true
+	}
+	return false
+}
+
 func NewApp(cfg *config.Config, version string) *cli.App {
 	appCtx := &AppContext{Config: cfg}
 
@@ -75,6 +85,8 @@ func NewApp(cfg *config.Config, version string) *cli.App {
 			switch outputFmt {
 			case "json":
 				appCtx.Renderer = render.NewJsonRenderer()
+			case "yaml":
+				appCtx.Renderer = render.NewYamlRenderer()
 			default:
 				if c.Bool("no-truncate") {
 					appCtx.Renderer = render.NewTableRendererNoTruncate()
@@ -97,7 +109,7 @@ func NewApp(cfg *config.Config, version string) *cli.App {
 			&cli.StringFlag{
 				Name:        "output",
 				Aliases:     []string{"o"},
-				Usage:       "Output format: table or json",
+				Usage:       "Output format: table, json, or yaml",
 				DefaultText: "table",
 				Value:       "table",
 				EnvVars:     []string{"DAPTIN_CLI_OUTPUT"},
@@ -133,15 +145,20 @@ func NewApp(cfg *config.Config, version string) *cli.App {
 			relatedCommand(appCtx),
 			relateCommand(appCtx),
 			unrelateCommand(appCtx),
+			relationsCommand(appCtx),
 			describeCommand(appCtx),
+			schemaCommand(appCtx),
+			tablesCommand(appCtx),
 			executeCommand(appCtx),
 			oauthCommand(appCtx),
 			integrationCommand(appCtx),
 			storageCommand(appCtx),
+			siteCommand(appCtx),
 			assetCommand(appCtx),
 			permissionCommand(appCtx),
 			tableCommand(appCtx),
 			wsCommand(appCtx),
+			syncCommand(appCtx),
 		},
 	}
 
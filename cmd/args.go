@@ -9,7 +9,9 @@ var knownCommands = map[string]bool{
 	"update": true, "delete": true, "related": true, "describe": true,
 	"execute": true, "help": true, "relate": true, "unrelate": true,
 	"permission": true, "storage": true, "asset": true, "oauth": true,
-	"integration": true, "table": true,
+	"integration": true, "table": true, "schema": true,
+	"tables": true, "relations": true, "ws": true, "sync": true,
+	"site": true,
 }
 
 // Only commands that actually have subcommands, mapped to their subcommand names.
@@ -18,13 +20,28 @@ var commandSubcommands = map[string]map[string]bool{
 	"describe":   {"table": true, "action": true},
 	"permission": {"decode": true, "encode": true},
 	"table":      {"defaults": true},
-	"defaults":   {"get": true, "set": true, "group": true, "ensure": true},
-	"group":      {"add": true},
+	"schema":     {"graph": true},
+	"relations":  {"replace": true},
+	"ws": {
+		"listen": true, "subscribe": true, "publish": true, "ping": true,
+		"topic": true, "verify": true, "record": true, "replay": true,
+		"forward": true,
+	},
+	"topic":    {"list": true, "describe": true, "create": true, "delete": true, "permission": true},
+	"defaults": {"get": true, "set": true, "group": true, "ensure": true},
+	"group":    {"add": true},
 	"storage": {
 		"add": true, "list": true, "remove": true, "ls": true,
-		"upload": true, "download": true, "mv": true, "rm": true, "mkdir": true,
+		"upload": true, "sync": true, "download": true, "cp": true, "mv": true, "rm": true, "mkdir": true,
+		"credential": true, "shell": true,
+	},
+	"credential": {"show": true, "update": true, "rotate": true, "test": true},
+	"site": {
+		"create": true, "list": true, "describe": true, "remove": true,
+		"files": true, "sync-cache": true,
 	},
-	"asset":   {"upload": true, "list": true},
+	"files":   {"ls": true, "get": true, "put": true, "rm": true},
+	"asset":   {"upload": true, "list": true, "download": true, "delete": true},
 	"oauth":   {"app": true, "connect": true, "login-url": true, "tokens": true},
 	"app":     {"register": true, "list": true, "describe": true, "rotate-secret": true},
 	"connect": {"create": true, "list": true},
@@ -45,6 +62,7 @@ var valueFlags = map[string]bool{
 	"--sort":                            true,
 	"--filter":                          true,
 	"--include":                         true,
+	"--depth":                           true,
 	"--reference-id":                    true,
 	"--type":                            true,
 	"--provider":                        true,
@@ -97,6 +115,49 @@ var valueFlags = map[string]bool{
 	"--pkce-challenge-method":           true,
 	"--permission":                      true,
 	"--group":                           true,
+	"--format":                          true,
+	"--table":                           true,
+	"--from-file":                       true,
+	"--max-retries":                     true,
+	"--ping-interval":                   true,
+	"--template":                        true,
+	"--select":                          true,
+	"--count":                           true,
+	"--timeout":                         true,
+	"--exec":                            true,
+	"--endpoints":                       true,
+	"--set":                             true,
+	"--file":                            true,
+	"--speed":                           true,
+	"--topic":                           true,
+	"--rate":                            true,
+	"--rounds":                          true,
+	"--kind":                            true,
+	"--db":                              true,
+	"--follow":                          true,
+	"--interval":                        true,
+	"--sqlite3":                         true,
+	"--to":                              true,
+	"--secret":                          true,
+	"--header":                          true,
+	"--batch-size":                      true,
+	"--batch-interval":                  true,
+	"--retries":                         true,
+	"--retry-delay":                     true,
+	"--request-timeout":                 true,
+	"--dead-letter":                     true,
+	"--max-backlog":                     true,
+	"--batch-bytes":                     true,
+	"--batch-files":                     true,
+	"--exclude":                         true,
+	"--site":                            true,
+	"--secret-key-env":                  true,
+	"--unset":                           true,
+	"--store":                           true,
+	"--path":                            true,
+	"--parallel":                        true,
+	"--chunk-size":                      true,
+	"--metadata-column":                 true,
 }
 
 var boolFlags = map[string]bool{
@@ -116,6 +177,20 @@ var boolFlags = map[string]bool{
 	"--confidential":        true,
 	"--public":              true,
 	"--open":                true,
+	"--no-join-tables":      true,
+	"--all":                 true,
+	"--allow-empty":         true,
+	"--dry-run":             true,
+	"--reconnect":           true,
+	"--append":              true,
+	"--bench":               true,
+	"--watch":               true,
+	"--delete":              true,
+	"--reveal":              true,
+	"--no-test":             true,
+	"--ftp":                 true,
+	"--https":               true,
+	"--metadata":            true,
 	"--help":                true, "-h": true,
 	"--version": true, "-v": true,
 }
@@ -165,7 +240,8 @@ func ReorderArgs(args []string) []string {
 
 	for i := 0; i < len(commandArgs); i++ {
 		arg := commandArgs[i]
-		if strings.HasPrefix(arg, "-") {
+		// A bare "-" is the conventional stdin placeholder, not a flag
+		if strings.HasPrefix(arg, "-") && arg != "-" {
 			flags = append(flags, arg)
 			// If it's --flag=value, the value is included. Otherwise peek next.
 			if flagConsumesNextValue(arg, commandArgs, i) {
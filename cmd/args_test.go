@@ -226,3 +226,30 @@ func TestReorderArgs_Empty(t *testing.T) {
 		t.Errorf("expected %v, got %v", expected, result)
 	}
 }
+
+func TestReorderArgs_WsSubscribeFlagsAfterTopics(t *testing.T) {
+	input := []string{"daptin-cli", "ws", "subscribe", "document", "--filter", "event=create", "--count", "1"}
+	expected := []string{"daptin-cli", "ws", "subscribe", "--filter", "event=create", "--count", "1", "document"}
+	result := ReorderArgs(input)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestReorderArgs_StdinDashStaysPositional(t *testing.T) {
+	input := []string{"daptin-cli", "ws", "publish", "chat", "-", "--rate", "10"}
+	expected := []string{"daptin-cli", "ws", "publish", "--rate", "10", "chat", "-"}
+	result := ReorderArgs(input)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestReorderArgs_WsTopicListFlags(t *testing.T) {
+	input := []string{"daptin-cli", "ws", "topic", "list", "--kind", "user", "--filter", "chat-*"}
+	expected := []string{"daptin-cli", "ws", "topic", "list", "--kind", "user", "--filter", "chat-*"}
+	result := ReorderArgs(input)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
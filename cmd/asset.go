@@ -0,0 +1,445 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+func assetCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "asset",
+		Usage: "Manage file asset columns",
+		UsageText: `daptin asset <command> [options]
+   daptin asset upload product <product_reference_id> photo ./image.jpg
+   daptin asset upload product <product_reference_id> gallery './shots/*.jpg'
+   daptin asset list product <product_reference_id> photo
+   daptin asset download product <product_reference_id> gallery front.jpg -o ./front.jpg
+   daptin asset delete product <product_reference_id> gallery front.jpg`,
+		Description: "Asset commands use Daptin's /asset routes for file.* columns on normal entity rows.",
+		Subcommands: []*cli.Command{
+			assetUploadCommand(appCtx),
+			assetListCommand(appCtx),
+			assetDownloadCommand(appCtx),
+			assetDeleteCommand(appCtx),
+		},
+	}
+}
+
+func assetUploadCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "upload",
+		Usage:     "Stream files into a file.* asset column",
+		ArgsUsage: "<entity> <reference_id> <column> <local-file-or-glob>...",
+		UsageText: `daptin asset upload <entity> <reference_id> <column> <local-file-or-glob>...
+   daptin asset upload product <product_reference_id> photo ./image.jpg
+   daptin asset upload product <product_reference_id> gallery ./a.jpg ./b.jpg
+   daptin asset upload product <product_reference_id> gallery './shots/*.jpg' --parallel 4
+   daptin asset upload product <product_reference_id> video ./launch.mp4 --chunk-size 64MB
+   daptin asset upload product <product_reference_id> photo ./image.jpg --metadata-column width --metadata-column height=photo_height`,
+		Description: "Each file is streamed and completed on its own; files larger than --chunk-size go up in Content-Range chunks " +
+			"and a rerun after a failure resumes from the last chunk the server acknowledged, or starts over if the server " +
+			"refuses the saved upload. Afterwards the row is read back and " +
+			"every file is checked for its name, size, content type and, when the server records one, SHA-256; a file that is " +
+			"missing or differs counts as failed. Content types are sniffed from the file's first bytes, falling back to the " +
+			"extension. --metadata reads image size, EXIF orientation and MP4/MOV/M4A/WAV duration locally; --metadata-column " +
+			"then writes those values to columns of the row.",
+		Flags: assetUploadFlags(),
+		Action: func(c *cli.Context) error {
+			entityName, referenceID, columnName := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2)
+			if entityName == "" || referenceID == "" || columnName == "" || c.Args().Len() < 4 {
+				return fmt.Errorf("usage: asset upload <entity> <reference_id> <column> <local-file-or-glob>...")
+			}
+			localPaths, err := expandAssetPaths(c.Args().Slice()[3:])
+			if err != nil {
+				return err
+			}
+			metadataColumns, err := ParseMetadataColumns(c.StringSlice("metadata-column"))
+			if err != nil {
+				return err
+			}
+			if len(metadataColumns) > 0 && len(localPaths) != 1 {
+				return fmt.Errorf("--metadata-column needs exactly one file, got %d", len(localPaths))
+			}
+
+			uploader, err := newAssetUploader(appCtx, c, entityName, referenceID, columnName)
+			if err != nil {
+				return err
+			}
+			files, errs := uploader.uploadAll(localPaths, c.Int("parallel"))
+
+			rows := make([]map[string]interface{}, 0, len(localPaths))
+			uploaded := map[string]UploadFile{}
+			for i, file := range files {
+				row := map[string]interface{}{"file": localPaths[i], "name": file.Name, "size": file.Size, "type": file.ContentType, "status": "ok"}
+				if errs[i] != nil {
+					row["status"] = "FAIL " + errs[i].Error()
+				} else {
+					uploaded[localPaths[i]] = file
+				}
+				rows = append(rows, row)
+			}
+
+			// Verify against the stored row rather than the complete
+			// responses, so a column that silently dropped a file shows up
+			if len(uploaded) > 0 {
+				stored, err := loadAssetFiles(appCtx, entityName, referenceID, columnName)
+				for _, row := range rows {
+					file, ok := uploaded[row["file"].(string)]
+					if !ok {
+						continue
+					}
+					checked, verifyErr := false, err
+					if verifyErr == nil {
+						checked, verifyErr = VerifyAssetFile(stored, file.Name, file.Size, file.ContentType, file.Hash)
+					}
+					row["sha256"] = "unchecked"
+					if checked {
+						row["sha256"] = "match"
+					}
+					if verifyErr != nil {
+						row["status"] = "FAIL verify: " + verifyErr.Error()
+					}
+				}
+			}
+
+			if c.Bool("metadata") || len(metadataColumns) > 0 {
+				for _, row := range rows {
+					if row["status"] != "ok" {
+						continue
+					}
+					meta, err := extractMediaMetadata(row["file"].(string))
+					if err != nil {
+						slog.Warn("could not read media metadata", "file", row["file"], "error", err)
+						continue
+					}
+					values := meta.Map()
+					for _, key := range []string{"width", "height", "orientation", "duration"} {
+						if value, ok := values[key]; ok {
+							row[key] = value
+						}
+					}
+					if attrs := MetadataUpdate(values, metadataColumns); len(attrs) > 0 {
+						slog.Info("asset metadata update", "entity", entityName, "reference_id", referenceID, "columns", attrs)
+						if _, err := appCtx.Client.Update(entityName, referenceID, jsonAPIObject(entityName, attrs, referenceID)); err != nil {
+							row["status"] = "FAIL metadata update: " + err.Error()
+						}
+					}
+				}
+			}
+
+			var failed []string
+			for _, row := range rows {
+				if row["status"] != "ok" {
+					failed = append(failed, row["file"].(string))
+				}
+			}
+			if !appCtx.Quiet {
+				if err := appCtx.Renderer.RenderArray(rows); err != nil {
+					return err
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%d of %d files failed: %s", len(failed), len(rows), strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func assetListCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List files recorded in a file.* asset column",
+		ArgsUsage: "<entity> <reference_id> <column>",
+		UsageText: `daptin asset list <entity> <reference_id> <column>
+   daptin asset list product <product_reference_id> photo`,
+		Action: func(c *cli.Context) error {
+			entityName, referenceID, columnName := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2)
+			if entityName == "" || referenceID == "" || columnName == "" {
+				return fmt.Errorf("usage: asset list <entity> <reference_id> <column>")
+			}
+			files, err := loadAssetFiles(appCtx, entityName, referenceID, columnName)
+			if err != nil {
+				return err
+			}
+			return appCtx.Renderer.RenderArray(files)
+		},
+	}
+}
+
+func assetDownloadCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "download",
+		Usage:     "Download files from a file.* asset column",
+		ArgsUsage: "<entity> <reference_id> <column> [name]",
+		UsageText: `daptin asset download <entity> <reference_id> <column> [name] [-o path]
+   daptin asset download product <product_reference_id> photo
+   daptin asset download product <product_reference_id> gallery front.jpg -o ./images/
+   daptin asset download product <product_reference_id> gallery -o ./gallery`,
+		Description: "With a name, one file is downloaded to -o (a file path or a directory ending in \"/\"), defaulting to the " +
+			"name in the current directory. Without a name every file in the column is downloaded into the -o directory.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Local file or directory to write to"},
+		},
+		Action: func(c *cli.Context) error {
+			entityName, referenceID, columnName, name := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2), c.Args().Get(3)
+			if entityName == "" || referenceID == "" || columnName == "" {
+				return fmt.Errorf("usage: asset download <entity> <reference_id> <column> [name] [-o path]")
+			}
+			output := c.String("output")
+
+			if name != "" {
+				target := DownloadTarget(output, path.Base(name), isDir(output))
+				return downloadAsset(appCtx, entityName, referenceID, columnName, name, target)
+			}
+
+			files, err := loadAssetFiles(appCtx, entityName, referenceID, columnName)
+			if err != nil {
+				return err
+			}
+			names := AssetFileNames(files)
+			if len(names) == 0 {
+				return fmt.Errorf("column %q has no files", columnName)
+			}
+			if len(names) == 1 {
+				target := DownloadTarget(output, path.Base(names[0]), isDir(output))
+				return downloadAsset(appCtx, entityName, referenceID, columnName, names[0], target)
+			}
+			dir := firstNonEmpty(output, ".")
+			var failed []string
+			for _, name := range names {
+				target, err := SafeLocalPath(dir, name)
+				if err == nil {
+					err = downloadAsset(appCtx, entityName, referenceID, columnName, name, target)
+				}
+				if err != nil {
+					failed = append(failed, name)
+					fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", name, err)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%d of %d files failed to download: %s", len(failed), len(names), strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func assetDeleteCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "delete",
+		Usage:     "Remove one file from a file.* asset column",
+		ArgsUsage: "<entity> <reference_id> <column> <name>",
+		UsageText: `daptin asset delete <entity> <reference_id> <column> <name>
+   daptin asset delete product <product_reference_id> gallery front.jpg`,
+		Description: "The row is updated with the column's file list minus the named file. The other files are left in place.",
+		Action: func(c *cli.Context) error {
+			entityName, referenceID, columnName, name := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2), c.Args().Get(3)
+			if entityName == "" || referenceID == "" || columnName == "" || name == "" {
+				return fmt.Errorf("usage: asset delete <entity> <reference_id> <column> <name>")
+			}
+			files, err := loadAssetFiles(appCtx, entityName, referenceID, columnName)
+			if err != nil {
+				return err
+			}
+			remaining, ok := RemoveAssetFile(files, name)
+			if !ok {
+				return fmt.Errorf("column %q has no file named %q; have %s", columnName, name, strings.Join(AssetFileNames(files), ", "))
+			}
+			slog.Info("asset delete", "entity", entityName, "reference_id", referenceID, "column", columnName, "name", name)
+			if _, err := appCtx.Client.Update(entityName, referenceID, jsonAPIObject(entityName, map[string]interface{}{
+				columnName: remaining,
+			}, referenceID)); err != nil {
+				return err
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stdout, "Deleted %s, %d files left in %s\n", name, len(remaining), columnName)
+			}
+			return nil
+		},
+	}
+}
+
+// downloadAsset writes one asset file to target, removing a partial file
+// when the transfer fails.
+func downloadAsset(appCtx *AppContext, entityName, referenceID, columnName, name, target string) error {
+	if dir := filepath.Dir(target); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	contentType, n, err := appCtx.Client.DownloadAsset(entityName, referenceID, columnName, name, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+	slog.Debug("asset downloaded", "name", name, "target", target, "type", contentType)
+	if !appCtx.Quiet {
+		fmt.Fprintf(os.Stdout, "%s -> %s (%s)\n", name, target, FormatBytes(n))
+	}
+	return nil
+}
+
+func loadAssetFiles(appCtx *AppContext, entityName, referenceID, columnName string) ([]map[string]interface{}, error) {
+	row, err := appCtx.Client.FindOne(entityName, referenceID, nil)
+	if err != nil {
+		return nil, err
+	}
+	attrs, _ := row["attributes"].(map[string]interface{})
+	if attrs == nil {
+		return nil, fmt.Errorf("no attributes returned")
+	}
+	return AssetFiles(attrs, columnName)
+}
+
+// expandAssetPaths expands glob patterns (for shells that pass them through
+// quoted) and keeps plain paths as given, dropping duplicates.
+func expandAssetPaths(args []string) ([]string, error) {
+	seen := map[string]bool{}
+	var paths []string
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				paths = append(paths, match)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// AssetFiles returns the file list stored in a file.* column. An empty or
+// null column is an empty list.
+// Pure function.
+func AssetFiles(attrs map[string]interface{}, columnName string) ([]map[string]interface{}, error) {
+	switch files := attrs[columnName].(type) {
+	case nil:
+		if _, ok := attrs[columnName]; !ok {
+			return nil, fmt.Errorf("column %q not found", columnName)
+		}
+		return []map[string]interface{}{}, nil
+	case []map[string]interface{}:
+		return files, nil
+	case []interface{}:
+		rows := make([]map[string]interface{}, 0, len(files))
+		for _, file := range files {
+			if row, ok := file.(map[string]interface{}); ok {
+				rows = append(rows, row)
+			}
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("column %q has no file list", columnName)
+}
+
+// AssetFileNames lists the names of files in a column, skipping unnamed entries.
+// Pure function.
+func AssetFileNames(files []map[string]interface{}) []string {
+	var names []string
+	for _, file := range files {
+		if name, _ := file["name"].(string); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RemoveAssetFile drops every entry called name and reports whether any was found.
+// Pure function.
+func RemoveAssetFile(files []map[string]interface{}, name string) ([]map[string]interface{}, bool) {
+	remaining := make([]map[string]interface{}, 0, len(files))
+	found := false
+	for _, file := range files {
+		if file["name"] == name {
+			found = true
+			continue
+		}
+		remaining = append(remaining, file)
+	}
+	return remaining, found
+}
+
+// VerifyAssetFile checks that a column holds name with the given size,
+// content type and SHA-256. Each is only compared when the server stored
+// it; checked reports whether a checksum was compared.
+// Pure function.
+func VerifyAssetFile(files []map[string]interface{}, name string, size int64, contentType, sha string) (checked bool, err error) {
+	for _, file := range files {
+		if file["name"] != name {
+			continue
+		}
+		var stored int64 = -1
+		switch v := file["size"].(type) {
+		case float64:
+			stored = int64(v)
+		case int64:
+			stored = v
+		case int:
+			stored = int64(v)
+		}
+		if stored >= 0 && stored != size {
+			return false, fmt.Errorf("%s stored with %d bytes, uploaded %d", name, stored, size)
+		}
+		if storedType, _ := file["type"].(string); storedType != "" && !sameMediaType(storedType, contentType) {
+			return false, fmt.Errorf("%s stored as %s, uploaded as %s", name, storedType, contentType)
+		}
+		storedHash := StoredSHA256(file)
+		if storedHash == "" || sha == "" {
+			return false, nil
+		}
+		if !strings.EqualFold(storedHash, sha) {
+			return true, fmt.Errorf("%s stored with sha256 %s, uploaded %s", name, storedHash, sha)
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("%s not found in column after upload", name)
+}
+
+// StoredSHA256 returns the SHA-256 the server recorded for a file, from a
+// sha256 key or a "sha256:"-prefixed checksum key.
+// Pure function.
+func StoredSHA256(file map[string]interface{}) string {
+	if sha, _ := file["sha256"].(string); sha != "" {
+		return sha
+	}
+	checksum, _ := file["checksum"].(string)
+	if strings.HasPrefix(strings.ToLower(checksum), "sha256:") {
+		return checksum[len("sha256:"):]
+	}
+	return ""
+}
+
+// sameMediaType compares content types ignoring parameters such as charset.
+func sameMediaType(a, b string) bool {
+	trim := func(s string) string {
+		if i := strings.Index(s, ";"); i >= 0 {
+			s = s[:i]
+		}
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	return trim(a) == trim(b)
+}
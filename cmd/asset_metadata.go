@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// mediaHeaderBytes is how much of a file is read for sniffing, EXIF and
+// WAV headers. Image dimensions and MP4 boxes are read from the file itself.
+const mediaHeaderBytes = 64 << 10
+
+// MediaMetadata is what can be learned about an upload without decoding it.
+// Zero values mean unknown.
+type MediaMetadata struct {
+	ContentType string
+	Width       int
+	Height      int
+	Orientation int
+	Duration    float64
+}
+
+// Map returns the known fields under the keys used by --metadata-column.
+// Pure function.
+func (m MediaMetadata) Map() map[string]interface{} {
+	out := map[string]interface{}{}
+	if m.ContentType != "" {
+		out["mime"] = m.ContentType
+	}
+	if m.Width > 0 && m.Height > 0 {
+		out["width"] = m.Width
+		out["height"] = m.Height
+	}
+	if m.Orientation > 0 {
+		out["orientation"] = m.Orientation
+	}
+	if m.Duration > 0 {
+		out["duration"] = math.Round(m.Duration*1000) / 1000
+	}
+	return out
+}
+
+// extractMediaMetadata reads the header of a local file for its content
+// type, image size and EXIF orientation, or audio/video duration.
+func extractMediaMetadata(localPath string) (MediaMetadata, error) {
+	var meta MediaMetadata
+	f, err := os.Open(localPath)
+	if err != nil {
+		return meta, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return meta, err
+	}
+	header := make([]byte, mediaHeaderBytes)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return meta, err
+	}
+	header = header[:n]
+	meta.ContentType = PickContentType(http.DetectContentType(header), contentTypeForPath(localPath))
+
+	switch {
+	case strings.HasPrefix(meta.ContentType, "image/"):
+		if config, _, err := image.DecodeConfig(io.NewSectionReader(f, 0, info.Size())); err == nil {
+			meta.Width, meta.Height = config.Width, config.Height
+		}
+		meta.Orientation = JPEGOrientation(header)
+		if meta.Orientation >= 5 {
+			// Orientations 5-8 are rotated a quarter turn; report the
+			// size the image is displayed at
+			meta.Width, meta.Height = meta.Height, meta.Width
+		}
+	case bytes.HasPrefix(header, []byte("RIFF")) && len(header) >= 12 && string(header[8:12]) == "WAVE":
+		meta.Duration = WAVDuration(header)
+	case strings.HasPrefix(meta.ContentType, "video/") || strings.HasPrefix(meta.ContentType, "audio/") ||
+		len(header) >= 8 && string(header[4:8]) == "ftyp":
+		if duration, err := MP4Duration(io.NewSectionReader(f, 0, info.Size()), info.Size()); err == nil {
+			meta.Duration = duration
+		}
+	}
+	return meta, nil
+}
+
+// sniffContentType picks a content type from the first bytes of a file,
+// falling back to the extension where sniffing only finds a generic type.
+func sniffContentType(localPath string) string {
+	byExt := contentTypeForPath(localPath)
+	f, err := os.Open(localPath)
+	if err != nil {
+		return byExt
+	}
+	defer f.Close()
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(f, header)
+	return PickContentType(http.DetectContentType(header[:n]), byExt)
+}
+
+// PickContentType prefers the sniffed type unless it is one of the generic
+// answers DetectContentType gives for formats it does not know (CSS, SVG,
+// JSON, MP4 variants...), where the extension is more specific.
+// Pure function.
+func PickContentType(sniffed, byExtension string) string {
+	generic := sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") ||
+		strings.HasPrefix(sniffed, "text/xml") || sniffed == "application/zip"
+	if generic && byExtension != "" && byExtension != "application/octet-stream" {
+		return byExtension
+	}
+	return sniffed
+}
+
+// JPEGOrientation returns the EXIF orientation (1-8) from the start of a
+// JPEG file, or 0 when there is none.
+// Pure function.
+func JPEGOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 0
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan or end of image: no more metadata segments
+			return 0
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 0
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 0
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF block.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < count; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// WAVDuration computes the length in seconds of a PCM WAV file from its
+// header, using the fmt chunk's byte rate and the data chunk's size.
+// Pure function.
+func WAVDuration(header []byte) float64 {
+	var byteRate uint32
+	for i := 12; i+8 <= len(header); {
+		id := string(header[i : i+4])
+		size := binary.LittleEndian.Uint32(header[i+4:])
+		switch {
+		case id == "fmt " && i+20 <= len(header):
+			byteRate = binary.LittleEndian.Uint32(header[i+16:])
+		case id == "data":
+			if byteRate == 0 {
+				return 0
+			}
+			return float64(size) / float64(byteRate)
+		}
+		// Chunks are padded to an even length
+		i += 8 + int(size) + int(size&1)
+	}
+	return 0
+}
+
+// MP4Duration reads the movie header (moov/mvhd) of an MP4, M4A or MOV
+// file and returns its duration in seconds.
+// Pure function (reads only from r).
+func MP4Duration(r io.ReaderAt, size int64) (float64, error) {
+	moov, moovSize, err := findMP4Box(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findMP4Box(r, moov, moov+moovSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 32)
+	if _, err := r.ReadAt(buf, mvhd); err != nil && err != io.EOF {
+		return 0, err
+	}
+	var timescale uint32
+	var duration uint64
+	if buf[0] == 1 {
+		timescale = binary.BigEndian.Uint32(buf[20:])
+		duration = binary.BigEndian.Uint64(buf[24:])
+	} else {
+		timescale = binary.BigEndian.Uint32(buf[12:])
+		duration = uint64(binary.BigEndian.Uint32(buf[16:]))
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("mvhd has no timescale")
+	}
+	return float64(duration) / float64(timescale), nil
+}
+
+// findMP4Box scans the boxes in [start, end) for boxType and returns the
+// offset and size of its payload.
+func findMP4Box(r io.ReaderAt, start, end int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		headerLen := int64(8)
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerLen = 16
+		}
+		if size < headerLen || offset+size > end {
+			return 0, 0, fmt.Errorf("malformed %q box at %d", header[4:8], offset)
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerLen, size - headerLen, nil
+		}
+		offset += size
+	}
+	return 0, 0, fmt.Errorf("no %s box", boxType)
+}
+
+// ParseMetadataColumns parses --metadata-column values of the form
+// key=column (or just key, stored in a column of the same name).
+// Pure function.
+func ParseMetadataColumns(values []string) (map[string]string, error) {
+	known := map[string]bool{"mime": true, "width": true, "height": true, "orientation": true, "duration": true}
+	columns := map[string]string{}
+	for _, value := range values {
+		key, column, found := strings.Cut(value, "=")
+		key, column = strings.TrimSpace(key), strings.TrimSpace(column)
+		if !found {
+			column = key
+		}
+		if !known[key] {
+			return nil, fmt.Errorf("unknown metadata key %q in --metadata-column; use mime, width, height, orientation or duration", key)
+		}
+		if column == "" {
+			return nil, fmt.Errorf("--metadata-column %q has no column name", value)
+		}
+		columns[key] = column
+	}
+	return columns, nil
+}
+
+// MetadataUpdate maps extracted metadata onto row columns, skipping keys
+// that could not be extracted.
+// Pure function.
+func MetadataUpdate(meta map[string]interface{}, columns map[string]string) map[string]interface{} {
+	attrs := map[string]interface{}{}
+	for key, column := range columns {
+		if value, ok := meta[key]; ok {
+			attrs[column] = value
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// exifJPEG builds the start of a JPEG whose EXIF block sets orientation.
+func exifJPEG(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := new(bytes.Buffer)
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(tiff, order, uint16(42))
+	binary.Write(tiff, order, uint32(8))
+	binary.Write(tiff, order, uint16(1))
+	binary.Write(tiff, order, uint16(0x0112))
+	binary.Write(tiff, order, uint16(3))
+	binary.Write(tiff, order, uint32(1))
+	binary.Write(tiff, order, orientation)
+	binary.Write(tiff, order, uint16(0))
+	binary.Write(tiff, order, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00, 0xFF, 0xE1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, 0xFF, 0xDA)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	if got := JPEGOrientation(exifJPEG(binary.LittleEndian, 6)); got != 6 {
+		t.Errorf("little endian = %d, want 6", got)
+	}
+	if got := JPEGOrientation(exifJPEG(binary.BigEndian, 3)); got != 3 {
+		t.Errorf("big endian = %d, want 3", got)
+	}
+	if got := JPEGOrientation([]byte{0xFF, 0xD8, 0xFF, 0xDA}); got != 0 {
+		t.Errorf("no exif = %d", got)
+	}
+	if got := JPEGOrientation(exifJPEG(binary.BigEndian, 3)[:20]); got != 0 {
+		t.Errorf("truncated = %d", got)
+	}
+	if got := JPEGOrientation([]byte("not a jpeg")); got != 0 {
+		t.Errorf("not jpeg = %d", got)
+	}
+}
+
+func TestWAVDuration(t *testing.T) {
+	header := new(bytes.Buffer)
+	header.WriteString("RIFF")
+	binary.Write(header, binary.LittleEndian, uint32(0))
+	header.WriteString("WAVEfmt ")
+	binary.Write(header, binary.LittleEndian, uint32(16))
+	binary.Write(header, binary.LittleEndian, []uint16{1, 2})
+	binary.Write(header, binary.LittleEndian, []uint32{44100, 176400})
+	binary.Write(header, binary.LittleEndian, []uint16{4, 16})
+	header.WriteString("data")
+	binary.Write(header, binary.LittleEndian, uint32(176400*3/2))
+	if got := WAVDuration(header.Bytes()); got != 1.5 {
+		t.Errorf("WAVDuration = %v, want 1.5", got)
+	}
+	if got := WAVDuration([]byte("RIFF\x00\x00\x00\x00WAVE")); got != 0 {
+		t.Errorf("no chunks = %v", got)
+	}
+}
+
+func mp4Box(boxType string, payload []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(out, boxType...), payload...)
+}
+
+func TestMP4Duration(t *testing.T) {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 12345)
+	file := append(mp4Box("ftyp", []byte("isom\x00\x00\x02\x00")), mp4Box("moov", append(mp4Box("mvhd", mvhd), mp4Box("trak", nil)...))...)
+	got, err := MP4Duration(bytes.NewReader(file), int64(len(file)))
+	if err != nil || got != 12.345 {
+		t.Errorf("MP4Duration = %v, %v; want 12.345", got, err)
+	}
+	if _, err := MP4Duration(bytes.NewReader(file[:20]), 20); err == nil {
+		t.Error("expected error without a moov box")
+	}
+}
+
+func TestPickContentType(t *testing.T) {
+	cases := []struct{ sniffed, ext, want string }{
+		{"image/png", "image/jpeg", "image/png"},
+		{"text/plain; charset=utf-8", "text/css; charset=utf-8", "text/css; charset=utf-8"},
+		{"application/octet-stream", "", "application/octet-stream"},
+		{"application/zip", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	}
+	for _, tc := range cases {
+		if got := PickContentType(tc.sniffed, tc.ext); got != tc.want {
+			t.Errorf("PickContentType(%q, %q) = %q, want %q", tc.sniffed, tc.ext, got, tc.want)
+		}
+	}
+}
+
+func TestExtractMediaMetadata_PNG(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "photo.bin")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 12, 7))); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	meta, err := extractMediaMetadata(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"mime": "image/png", "width": 12, "height": 7}
+	if got := meta.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata = %v, want %v", got, want)
+	}
+}
+
+func TestParseMetadataColumns(t *testing.T) {
+	got, err := ParseMetadataColumns([]string{"width", "height=photo_height"})
+	if err != nil || !reflect.DeepEqual(got, map[string]string{"width": "width", "height": "photo_height"}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := ParseMetadataColumns([]string{"colour=c"}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+	if _, err := ParseMetadataColumns([]string{"width="}); err == nil {
+		t.Error("expected error for empty column")
+	}
+}
+
+func TestMetadataUpdate(t *testing.T) {
+	meta := map[string]interface{}{"width": 12, "height": 7}
+	got := MetadataUpdate(meta, map[string]string{"width": "w", "duration": "d"})
+	if !reflect.DeepEqual(got, map[string]interface{}{"w": 12}) {
+		t.Errorf("got %v", got)
+	}
+}
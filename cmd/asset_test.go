@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+)
+
+func TestAssetFiles(t *testing.T) {
+	attrs := map[string]interface{}{
+		"gallery": []interface{}{map[string]interface{}{"name": "a.jpg"}, "junk", map[string]interface{}{"name": "b.jpg"}},
+		"photo":   nil,
+		"title":   "x",
+	}
+	files, err := AssetFiles(attrs, "gallery")
+	if err != nil || !reflect.DeepEqual(AssetFileNames(files), []string{"a.jpg", "b.jpg"}) {
+		t.Fatalf("gallery = %v, %v", files, err)
+	}
+	if files, err := AssetFiles(attrs, "photo"); err != nil || len(files) != 0 {
+		t.Errorf("null column = %v, %v; want empty list", files, err)
+	}
+	if _, err := AssetFiles(attrs, "title"); err == nil {
+		t.Error("expected error for a non-file column")
+	}
+	if _, err := AssetFiles(attrs, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing column err = %v", err)
+	}
+}
+
+func TestRemoveAssetFile(t *testing.T) {
+	files := []map[string]interface{}{{"name": "a.jpg"}, {"name": "b.jpg"}, {"name": "a.jpg"}}
+	remaining, ok := RemoveAssetFile(files, "a.jpg")
+	if !ok || !reflect.DeepEqual(AssetFileNames(remaining), []string{"b.jpg"}) {
+		t.Errorf("remaining = %v, %v", remaining, ok)
+	}
+	if _, ok := RemoveAssetFile(files, "c.jpg"); ok {
+		t.Error("expected not found")
+	}
+	remaining, _ = RemoveAssetFile([]map[string]interface{}{{"name": "a.jpg"}}, "a.jpg")
+	if remaining == nil || len(remaining) != 0 {
+		t.Errorf("last file removed should leave an empty list, got %#v", remaining)
+	}
+}
+
+func TestVerifyAssetFile(t *testing.T) {
+	files := []map[string]interface{}{
+		{"name": "a.jpg", "size": float64(10), "type": "image/jpeg", "sha256": "ABC123"},
+		{"name": "b.txt", "type": "text/plain; charset=utf-8"},
+	}
+	cases := []struct {
+		name        string
+		size        int64
+		contentType string
+		sha         string
+		wantChecked bool
+		wantErr     string
+	}{
+		{"a.jpg", 10, "image/jpeg", "abc123", true, ""},
+		{"a.jpg", 10, "image/jpeg", "fff", true, "sha256 ABC123"},
+		{"a.jpg", 11, "image/jpeg", "abc123", false, "10 bytes"},
+		{"a.jpg", 10, "image/png", "abc123", false, "stored as image/jpeg"},
+		{"b.txt", 999, "text/plain", "abc123", false, ""},
+		{"c.jpg", 1, "image/jpeg", "", false, "not found"},
+	}
+	for _, tc := range cases {
+		checked, err := VerifyAssetFile(files, tc.name, tc.size, tc.contentType, tc.sha)
+		if checked != tc.wantChecked || tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("VerifyAssetFile(%s, %d, %s, %s) = %v, %v; want %v, %q", tc.name, tc.size, tc.contentType, tc.sha, checked, err, tc.wantChecked, tc.wantErr)
+		}
+	}
+}
+
+func TestStoredSHA256(t *testing.T) {
+	cases := []struct {
+		file map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"sha256": "aa"}, "aa"},
+		{map[string]interface{}{"checksum": "SHA256:bb"}, "bb"},
+		{map[string]interface{}{"checksum": "md5:cc"}, ""},
+		{map[string]interface{}{}, ""},
+	}
+	for _, tc := range cases {
+		if got := StoredSHA256(tc.file); got != tc.want {
+			t.Errorf("StoredSHA256(%v) = %q, want %q", tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestChunkLength(t *testing.T) {
+	cases := []struct{ offset, size, chunk, want int64 }{
+		{0, 100, 40, 40},
+		{80, 100, 40, 20},
+		{0, 40, 40, 40},
+	}
+	for _, tc := range cases {
+		if got := ChunkLength(tc.offset, tc.size, tc.chunk); got != tc.want {
+			t.Errorf("ChunkLength(%d, %d, %d) = %d, want %d", tc.offset, tc.size, tc.chunk, got, tc.want)
+		}
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	got := FormatProgress(50<<20, 100<<20, 5*time.Second, 10)
+	want := "[=====>    ]  50% 50.0 MB/100.0 MB 10.0 MB/s ETA 5s"
+	if got != want {
+		t.Errorf("FormatProgress = %q, want %q", got, want)
+	}
+	if got := FormatProgress(10, 10, time.Second, 4); !strings.HasPrefix(got, "[====] 100%") || !strings.HasSuffix(got, "ETA 0s") {
+		t.Errorf("complete progress = %q", got)
+	}
+	if got := FormatProgress(0, 10, 0, 4); !strings.HasSuffix(got, "ETA --") {
+		t.Errorf("no rate yet = %q", got)
+	}
+}
+
+func TestExpandAssetPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg", "c.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(dir, "a.jpg")
+	got, err := expandAssetPaths([]string{a, filepath.Join(dir, "*.jpg"), filepath.Join(dir, "c.png")})
+	want := []string{a, filepath.Join(dir, "b.jpg"), filepath.Join(dir, "c.png")}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, %v; want %v", got, err, want)
+	}
+	if _, err := expandAssetPaths([]string{filepath.Join(dir, "*.gif")}); err == nil {
+		t.Error("expected error for a pattern with no matches")
+	}
+}
+
+func TestAssetUploaderRestartsRejectedResume(t *testing.T) {
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offsets = append(offsets, r.Header.Get("Content-Range"))
+		if r.URL.Query().Get("upload_id") == "expired" {
+			http.Error(w, "unknown upload", http.StatusNotFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var start, end, total int64
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total)
+		json.NewEncoder(w).Encode(map[string]interface{}{"upload_id": "fresh", "received": start + int64(len(body))})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	localPath := filepath.Join(dir, "data.bin")
+	os.WriteFile(localPath, []byte("0123456789"), 0o644)
+	hash, _ := sha256File(localPath)
+	file := UploadFile{LocalPath: localPath, Name: "data.bin", Size: 10, Hash: hash, ContentType: "application/octet-stream"}
+	u := &assetUploader{
+		appCtx:    &AppContext{Client: client.New(server.URL, "", false), Quiet: true},
+		entity:    "doc",
+		ref:       "r1",
+		column:    "file",
+		chunkSize: 4,
+		stateDir:  dir,
+		progress:  newTransferProgress(10, false),
+	}
+	u.saveState(file, assetUploadState{UploadID: "expired", Offset: 4, Size: 10, SHA256: hash})
+
+	f, _ := os.Open(localPath)
+	defer f.Close()
+	uploadID, err := u.sendChunks(f, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"bytes 4-7/10", "bytes 0-3/10", "bytes 4-7/10", "bytes 8-9/10"}
+	if uploadID != "fresh" || strings.Join(offsets, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected a restart from 0 with a fresh upload, got %q %v", uploadID, offsets)
+	}
+}
+
+func TestAssetUploaderFirstChunkErrorDoesNotFallBack(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	localPath := filepath.Join(t.TempDir(), "data.bin")
+	os.WriteFile(localPath, []byte("0123456789"), 0o644)
+	file := UploadFile{LocalPath: localPath, Name: "data.bin", Size: 10, ContentType: "application/octet-stream"}
+	u := &assetUploader{
+		appCtx:    &AppContext{Client: client.New(server.URL, "", false), Quiet: true},
+		chunkSize: 4,
+		retries:   3,
+		progress:  newTransferProgress(10, false),
+	}
+	f, _ := os.Open(localPath)
+	defer f.Close()
+	if _, err := u.sendChunks(f, file); !errors.Is(err, client.ErrForbidden) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected one request without retries or whole-file fallback, got %d", calls)
+	}
+}
+
+func TestUploadRejected(t *testing.T) {
+	for err, expected := range map[error]bool{
+		client.CheckStatusCode(404, ""): true,
+		client.CheckStatusCode(410, ""): true,
+		client.CheckStatusCode(416, ""): true,
+		client.CheckStatusCode(429, ""): false,
+		client.CheckStatusCode(503, ""): false,
+		client.CheckStatusCode(401, ""): false,
+		fmt.Errorf("dial tcp: refused"): false,
+	} {
+		if UploadRejected(err) != expected {
+			t.Errorf("UploadRejected(%v): expected %v", err, expected)
+		}
+	}
+	if UploadRejected(nil) {
+		t.Error("nil is not a rejection")
+	}
+}
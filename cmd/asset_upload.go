@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+func assetUploadFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{Name: "parallel", Value: 1, Usage: "Upload up to N files at the same time"},
+		&cli.StringFlag{Name: "chunk-size", Value: "8MB", Usage: "Files larger than this are sent in resumable chunks of this size"},
+		&cli.IntFlag{Name: "retries", Value: 3, Usage: "Retries per chunk before a file fails"},
+		&cli.DurationFlag{Name: "retry-delay", Value: time.Second, Usage: "Initial wait between retries, doubled each attempt"},
+		&cli.BoolFlag{Name: "metadata", Usage: "Show image size, EXIF orientation and audio/video duration of each file"},
+		&cli.StringSliceFlag{Name: "metadata-column", Usage: "Store metadata in a row column after upload, as key=column (repeatable); keys: mime, width, height, orientation, duration"},
+	}
+}
+
+// assetUploader uploads files into one asset column. Files above chunkSize
+// go up in Content-Range chunks; the last acknowledged offset is kept in a
+// state file so an interrupted upload continues where it stopped.
+type assetUploader struct {
+	appCtx     *AppContext
+	entity     string
+	ref        string
+	column     string
+	chunkSize  int64
+	retries    int
+	retryDelay time.Duration
+	stateDir   string
+	progress   *transferProgress
+}
+
+// assetUploadState is what is persisted between runs for one file.
+type assetUploadState struct {
+	UploadID string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+func newAssetUploader(appCtx *AppContext, c *cli.Context, entity, ref, column string) (*assetUploader, error) {
+	chunkSize, err := ParseByteSize(c.String("chunk-size"))
+	if err != nil || chunkSize <= 0 {
+		return nil, fmt.Errorf("invalid --chunk-size %q", c.String("chunk-size"))
+	}
+	if c.Int("retries") < 0 {
+		return nil, fmt.Errorf("--retries must not be negative")
+	}
+	u := &assetUploader{
+		appCtx:     appCtx,
+		entity:     entity,
+		ref:        ref,
+		column:     column,
+		chunkSize:  chunkSize,
+		retries:    c.Int("retries"),
+		retryDelay: c.Duration("retry-delay"),
+	}
+	if appCtx.Config != nil && appCtx.Config.Path() != "" {
+		u.stateDir = filepath.Join(filepath.Dir(appCtx.Config.Path()), "uploads")
+	}
+	return u, nil
+}
+
+// uploadAll uploads localPaths with up to parallel workers and returns the
+// results in the order given.
+func (u *assetUploader) uploadAll(localPaths []string, parallel int) ([]UploadFile, []error) {
+	files := make([]UploadFile, len(localPaths))
+	errs := make([]error, len(localPaths))
+	var total int64
+	for _, localPath := range localPaths {
+		if info, err := os.Stat(localPath); err == nil {
+			total += info.Size()
+		}
+	}
+	u.progress = newTransferProgress(total, !u.appCtx.Quiet && term.IsTerminal(int(os.Stderr.Fd())))
+	defer u.progress.finish()
+
+	if parallel < 1 {
+		parallel = 1
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				files[i], errs[i] = u.upload(localPaths[i])
+			}
+		}()
+	}
+	for i := range localPaths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return files, errs
+}
+
+// upload streams one local file into the column and completes it.
+func (u *assetUploader) upload(localPath string) (UploadFile, error) {
+	file := UploadFile{LocalPath: localPath, Name: filepath.Base(localPath), ContentType: sniffContentType(localPath)}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return file, err
+	}
+	if info.IsDir() {
+		return file, fmt.Errorf("asset upload expects a file, got directory %q", localPath)
+	}
+	file.Size = info.Size()
+	if file.Hash, err = sha256File(localPath); err != nil {
+		return file, err
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+
+	var uploadID string
+	if file.Size > u.chunkSize {
+		uploadID, err = u.sendChunks(f, file)
+	} else {
+		uploadID, err = u.sendWhole(f, file)
+	}
+	if err != nil {
+		return file, err
+	}
+	complete, err := u.appCtx.Client.CompleteAssetUpload(u.entity, u.ref, u.column, file.Name, uploadID, file.ContentType, file.Size)
+	if err != nil {
+		return file, err
+	}
+	u.clearState(file)
+	slog.Debug("asset upload complete", "file", localPath, "response", complete)
+	return file, nil
+}
+
+// sendWhole posts the file in one stream request, retrying from the start.
+func (u *assetUploader) sendWhole(f *os.File, file UploadFile) (string, error) {
+	var upload map[string]interface{}
+	err := u.retry(file.Name, func() (int64, error) {
+		counter := u.counter(io.NewSectionReader(f, 0, file.Size))
+		var err error
+		upload, err = u.appCtx.Client.UploadAssetStream(u.entity, u.ref, u.column, file.Name, file.ContentType, file.Size, counter)
+		return counter.sent, err
+	})
+	if err != nil {
+		return "", err
+	}
+	uploadID, _ := upload["upload_id"].(string)
+	return uploadID, nil
+}
+
+// sendChunks posts the file in chunkSize parts, resuming from saved state.
+// A server that does not acknowledge ranges on the first chunk gets the
+// whole file in one request instead. When the server rejects a saved
+// upload_id, the state is discarded and the upload starts over.
+func (u *assetUploader) sendChunks(f *os.File, file UploadFile) (string, error) {
+	state := u.loadState(file)
+	resumed := state.Offset > 0
+	if resumed {
+		slog.Info("asset upload resuming", "file", file.LocalPath, "upload_id", state.UploadID, "offset", state.Offset)
+		u.progress.add(state.Offset)
+	}
+	for state.Offset < file.Size {
+		chunk := client.AssetChunk{
+			UploadID: state.UploadID,
+			Offset:   state.Offset,
+			Length:   ChunkLength(state.Offset, file.Size, u.chunkSize),
+			Total:    file.Size,
+			SHA256:   file.Hash,
+		}
+		var ack map[string]interface{}
+		err := u.retry(file.Name, func() (int64, error) {
+			counter := u.counter(io.NewSectionReader(f, chunk.Offset, chunk.Length))
+			var err error
+			ack, err = u.appCtx.Client.UploadAssetChunk(u.entity, u.ref, u.column, file.Name, file.ContentType, chunk, counter)
+			return counter.sent, err
+		})
+		uploadID, received, ranged := client.ParseChunkAck(ack)
+		if resumed && (UploadRejected(err) || err == nil && ranged && received <= chunk.Offset) {
+			slog.Info("asset upload resume rejected, starting over", "file", file.LocalPath, "upload_id", state.UploadID, "error", err)
+			if err == nil {
+				u.progress.add(-chunk.Length)
+			}
+			u.progress.add(-state.Offset)
+			u.clearState(file)
+			state, resumed = assetUploadState{}, false
+			continue
+		}
+		if err != nil && chunk.Offset == 0 {
+			return "", err
+		}
+		if err != nil {
+			return "", fmt.Errorf("at byte %d (rerun to resume): %w", chunk.Offset, err)
+		}
+		if !ranged && chunk.Offset == 0 {
+			slog.Info("asset upload server ignores ranges, sending whole file", "file", file.LocalPath)
+			u.progress.add(-chunk.Length)
+			return u.sendWhole(f, file)
+		}
+		if !ranged || received <= chunk.Offset || received > file.Size {
+			return "", fmt.Errorf("server acknowledged %d bytes after sending up to %d", received, chunk.Offset+chunk.Length)
+		}
+		// The server accepted the upload_id, so later failures resume it
+		resumed = false
+		// The server may have kept less than was sent; resend the rest
+		u.progress.add(received - chunk.Offset - chunk.Length)
+		state = assetUploadState{UploadID: firstNonEmpty(uploadID, state.UploadID), Offset: received, Size: file.Size, SHA256: file.Hash}
+		u.saveState(file, state)
+	}
+	return state.UploadID, nil
+}
+
+// UploadRejected reports whether err is the server refusing a request, as
+// opposed to a network error, a server fault or a rejected login, so
+// retrying the same upload_id cannot succeed.
+// Pure function.
+func UploadRejected(err error) bool {
+	if errors.Is(err, client.ErrNotFound) {
+		return true
+	}
+	var statusErr *client.StatusError
+	return errors.As(err, &statusErr) && statusErr.Code < 500 && !RetryableStatus(statusErr.Code)
+}
+
+// retry runs send until it succeeds, retries run out or the server refuses
+// the request, taking back the progress of failed attempts.
+func (u *assetUploader) retry(name string, send func() (int64, error)) error {
+	var lastErr error
+	for attempt := 0; attempt <= u.retries; attempt++ {
+		if attempt > 0 {
+			delay := client.BackoffDelay(attempt, u.retryDelay, 30*time.Second)
+			slog.Info("asset upload retry", "file", name, "attempt", attempt, "delay", delay, "error", lastErr)
+			time.Sleep(delay)
+		}
+		sent, err := send()
+		if err == nil {
+			return nil
+		}
+		u.progress.add(-sent)
+		lastErr = err
+		if UploadRejected(err) || errors.Is(err, client.ErrUnauthorized) || errors.Is(err, client.ErrForbidden) {
+			break
+		}
+	}
+	return lastErr
+}
+
+// counter wraps r so bytes read from it move the progress line.
+func (u *assetUploader) counter(r io.Reader) *progressReader {
+	var reported int64
+	return &progressReader{reader: r, onRead: func(sent int64) {
+		u.progress.add(sent - reported)
+		reported = sent
+	}}
+}
+
+// statePath names the state file for a file in this column.
+func (u *assetUploader) statePath(file UploadFile) string {
+	if u.stateDir == "" {
+		return ""
+	}
+	key := sha256.Sum256([]byte(strings.Join([]string{u.appCtx.Client.Endpoint, u.entity, u.ref, u.column, file.Name}, "\x00")))
+	return filepath.Join(u.stateDir, hex.EncodeToString(key[:8])+".json")
+}
+
+// loadState returns the saved state when it is for the same file content.
+func (u *assetUploader) loadState(file UploadFile) assetUploadState {
+	var state assetUploadState
+	name := u.statePath(file)
+	if name == "" {
+		return state
+	}
+	data, err := os.ReadFile(name)
+	if err != nil || json.Unmarshal(data, &state) != nil || state.SHA256 != file.Hash || state.Size != file.Size || state.UploadID == "" {
+		return assetUploadState{}
+	}
+	return state
+}
+
+func (u *assetUploader) saveState(file UploadFile, state assetUploadState) {
+	name := u.statePath(file)
+	if name == "" {
+		return
+	}
+	data, _ := json.Marshal(state)
+	err := os.MkdirAll(filepath.Dir(name), 0o700)
+	if err == nil {
+		err = os.WriteFile(name, data, 0o600)
+	}
+	if err != nil {
+		slog.Warn("could not save upload state", "file", file.LocalPath, "error", err)
+	}
+}
+
+func (u *assetUploader) clearState(file UploadFile) {
+	if name := u.statePath(file); name != "" {
+		os.Remove(name)
+	}
+}
+
+func sha256File(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// transferProgress draws one progress line on stderr for all files of an
+// upload. It draws nothing when stderr is not a terminal.
+type transferProgress struct {
+	total    int64
+	done     atomic.Int64
+	start    time.Time
+	draw     bool
+	mu       sync.Mutex
+	lastDraw time.Time
+}
+
+func newTransferProgress(total int64, draw bool) *transferProgress {
+	return &transferProgress{total: total, start: time.Now(), draw: draw}
+}
+
+func (p *transferProgress) add(n int64) {
+	done := p.done.Add(n)
+	if !p.draw {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.lastDraw) < 200*time.Millisecond {
+		return
+	}
+	p.lastDraw = time.Now()
+	fmt.Fprintf(os.Stderr, "\r%s", FormatProgress(done, p.total, time.Since(p.start), 30))
+}
+
+func (p *transferProgress) finish() {
+	if !p.draw {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r%s\n", FormatProgress(p.done.Load(), p.total, time.Since(p.start), 30))
+}
+
+// FormatProgress renders a progress bar with percentage, byte counts,
+// throughput and the estimated time left.
+// Pure function.
+func FormatProgress(done, total int64, elapsed time.Duration, width int) string {
+	if done < 0 {
+		done = 0
+	}
+	fraction := 1.0
+	if total > 0 {
+		fraction = float64(done) / float64(total)
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction * float64(width))
+	bar := strings.Repeat("=", filled)
+	if filled < width {
+		bar += ">" + strings.Repeat(" ", width-filled-1)
+	}
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(done) / elapsed.Seconds()
+	}
+	eta := "--"
+	if rate > 0 && done < total {
+		eta = time.Duration(float64(total-done) / rate * float64(time.Second)).Round(time.Second).String()
+	} else if done >= total {
+		eta = "0s"
+	}
+	return fmt.Sprintf("[%s] %3.0f%% %s/%s %s/s ETA %s", bar, fraction*100, FormatBytes(done), FormatBytes(total), FormatBytes(int64(rate)), eta)
+}
+
+// ChunkLength is the size of the chunk starting at offset.
+// Pure function.
+func ChunkLength(offset, size, chunkSize int64) int64 {
+	if remaining := size - offset; remaining < chunkSize {
+		return remaining
+	}
+	return chunkSize
+}
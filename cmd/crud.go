@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/daptin/daptin-cli/client"
 	"github.com/daptin/daptin-cli/render"
@@ -20,7 +22,8 @@ func listCommand(appCtx *AppContext) *cli.Command {
 		UsageText: `daptin list <entity> [flags]
    daptin list usergroup --filter name=administrators --columns name,reference_id
    daptin list world --filter "table_name like %doc%" --page-size 50
-   daptin list document --sort -created_at`,
+   daptin list document --sort -created_at
+   daptin list document --watch --columns reference_id,name,status`,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "columns",
@@ -48,6 +51,7 @@ func listCommand(appCtx *AppContext) *cli.Command {
 				Name:  "include",
 				Usage: "Comma-separated relation names to include",
 			},
+			watchFlag(),
 		},
 		Action: func(c *cli.Context) error {
 			entityName := c.Args().Get(0)
@@ -63,8 +67,10 @@ func listCommand(appCtx *AppContext) *cli.Command {
 			if s := c.String("sort"); s != "" {
 				params["sort"] = s
 			}
+			var clauses []FilterClause
 			if f := c.String("filter"); f != "" {
-				clauses, err := ParseFilter(f)
+				var err error
+				clauses, err = ParseFilter(f)
 				if err != nil {
 					return err
 				}
@@ -74,40 +80,64 @@ func listCommand(appCtx *AppContext) *cli.Command {
 				params["included_relations"] = inc
 			}
 
-			result, err := appCtx.Client.FindAll(entityName, params)
-			if err != nil {
-				return err
-			}
-			if len(result) == 0 {
-				fmt.Println("No rows found")
-				return nil
-			}
-
-			rows := client.MapArray(result, "attributes")
-			slog.Debug("list results", "count", len(rows))
-			if appCtx.Quiet {
-				return printRefs(rows)
+			listRows := func() ([]map[string]interface{}, error) {
+				result, err := appCtx.Client.FindAll(entityName, params)
+				if err != nil {
+					return nil, err
+				}
+				rows := client.MapArray(result, "attributes")
+				return rows, renderListRows(appCtx, rows, c.String("columns"))
 			}
-			if cols := c.String("columns"); cols != "" {
-				colList := strings.Split(cols, ",")
-				slog.Debug("filtering columns", "columns", colList)
-				rows = render.FilterColumns(rows, colList)
+			if c.Bool("watch") {
+				return watchRows(appCtx, entityName, clauses, c.String("columns"), listRows)
 			}
-			return appCtx.Renderer.RenderArray(rows)
+			_, err := listRows()
+			return err
 		},
 	}
 }
 
+func renderListRows(appCtx *AppContext, rows []map[string]interface{}, columns string) error {
+	if len(rows) == 0 {
+		fmt.Println("No rows found")
+		return nil
+	}
+	slog.Debug("list results", "count", len(rows))
+	if appCtx.Quiet {
+		return printRefs(rows)
+	}
+	if columns != "" {
+		colList := strings.Split(columns, ",")
+		slog.Debug("filtering columns", "columns", colList)
+		rows = render.FilterColumns(rows, colList)
+	}
+	return appCtx.Renderer.RenderArray(rows)
+}
+
 func getCommand(appCtx *AppContext) *cli.Command {
 	return &cli.Command{
 		Name:      "get",
 		Usage:     "Get a single row by reference_id",
 		ArgsUsage: "<entity> <reference_id>",
+		UsageText: `daptin get <entity> <reference_id> [flags]
+   daptin get document <ref_id>
+   daptin get document <ref_id> --include user_account_id
+   daptin -o yaml get document <ref_id> --include user_account_id.usergroup_id,comments --depth 2
+   daptin get document <ref_id> --watch`,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "columns",
 				Usage: "Comma-separated column names to show",
 			},
+			&cli.StringFlag{
+				Name:  "include",
+				Usage: "Comma-separated relation paths to resolve, dot-separated for nesting (e.g. user_account_id,comments.author)",
+			},
+			&cli.IntFlag{
+				Name:  "depth",
+				Usage: "Maximum nesting depth for --include paths (default: longest path)",
+			},
+			watchFlag(),
 		},
 		Action: func(c *cli.Context) error {
 			entityName := c.Args().Get(0)
@@ -115,28 +145,107 @@ func getCommand(appCtx *AppContext) *cli.Command {
 			if entityName == "" || referenceId == "" {
 				return fmt.Errorf("usage: get <entity> <reference_id>")
 			}
-			slog.Info("get", "entity", entityName, "reference_id", referenceId)
+			slog.Info("get", "entity", entityName, "reference_id", referenceId, "include", c.String("include"))
 
-			result, err := appCtx.Client.FindOne(entityName, referenceId, nil)
-			if err != nil {
-				return err
+			if c.IsSet("depth") && c.String("include") == "" {
+				return fmt.Errorf("--depth requires --include")
 			}
-
-			row, ok := result["attributes"].(map[string]interface{})
-			if !ok {
-				fmt.Println("No data found")
-				return nil
+			if c.Bool("watch") {
+				return watchGet(appCtx, c, entityName, referenceId)
 			}
+			return renderGet(appCtx, c, entityName, referenceId)
+		},
+	}
+}
 
-			if appCtx.Quiet {
-				return printRef(row)
-			}
-			if cols := c.String("columns"); cols != "" {
-				row = render.IncludeColumns(row, strings.Split(cols, ","))
+func renderGet(appCtx *AppContext, c *cli.Context, entityName, referenceId string) error {
+	if c.String("include") != "" {
+		return getWithIncludes(appCtx, c, entityName, referenceId)
+	}
+
+	result, err := appCtx.Client.FindOne(entityName, referenceId, nil)
+	if err != nil {
+		return err
+	}
+
+	row, ok := result["attributes"].(map[string]interface{})
+	if !ok {
+		fmt.Println("No data found")
+		return nil
+	}
+
+	if appCtx.Quiet {
+		return printRef(row)
+	}
+	if cols := c.String("columns"); cols != "" {
+		row = render.IncludeColumns(row, strings.Split(cols, ","))
+	}
+	return appCtx.Renderer.RenderObject(row)
+}
+
+// watchGet renders the row once the subscription is active, so no update
+// falls between the two, then re-renders it after every update and stops
+// when it is deleted.
+func watchGet(appCtx *AppContext, c *cli.Context, entityName, referenceId string) error {
+	clauses := []FilterClause{{Column: "reference_id", Operator: "is", Value: referenceId}}
+	ready := func() error {
+		return renderGet(appCtx, c, entityName, referenceId)
+	}
+	return watchEntity(appCtx, entityName, clauses, ready, func(action string, row map[string]interface{}) (bool, error) {
+		// The subscription filter is applied by the server; this guards against servers that ignore it
+		if ref, ok := row["reference_id"]; ok && ref != referenceId {
+			return false, nil
+		}
+		if action == "delete" {
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "%s %s was deleted\n", entityName, referenceId)
 			}
-			return appCtx.Renderer.RenderObject(row)
-		},
+			return true, nil
+		}
+		if !appCtx.Quiet && !appCtx.StructuredOutput() {
+			fmt.Printf("\n--- %s at %s ---\n", action, time.Now().Format(time.TimeOnly))
+		}
+		// Re-fetch rather than render event_data so --include and --columns apply as before
+		return false, renderGet(appCtx, c, entityName, referenceId)
+	})
+}
+
+func getWithIncludes(appCtx *AppContext, c *cli.Context, entityName, referenceId string) error {
+	tree, err := ParseIncludePaths(c.String("include"), c.Int("depth"))
+	if err != nil {
+		return err
+	}
+	slog.Debug("include tree", "relations", tree.Relations(), "depth", tree.Depth())
+
+	// The server resolves the first level itself; deeper levels are fetched per row
+	result, included, err := appCtx.Client.FindOneIncluded(entityName, referenceId, daptinClient.DaptinQueryParameters{
+		"included_relations": strings.Join(tree.Relations(), ","),
+	})
+	if err != nil {
+		return err
+	}
+	if appCtx.Quiet {
+		row, _ := result["attributes"].(map[string]interface{})
+		return printRef(row)
+	}
+
+	resolver := newIncludeResolver(included, func(entity, ref, relation string) ([]daptinClient.JsonApiObject, error) {
+		return appCtx.Client.FindRelatedPages(entity, ref, relation, nil, 500)
+	})
+	node, err := resolver.resolve(result, entityName, tree)
+	if err != nil {
+		return err
+	}
+
+	var columns []string
+	if cols := c.String("columns"); cols != "" {
+		columns = splitCSV(cols)
 	}
+	if appCtx.StructuredOutput() {
+		return appCtx.Renderer.RenderObject(node.Map(columns))
+	}
+	fmt.Fprint(os.Stdout, RenderIncludeTree(node, columns))
+	return nil
 }
 
 func createCommand(appCtx *AppContext) *cli.Command {
@@ -251,11 +360,37 @@ func relatedCommand(appCtx *AppContext) *cli.Command {
 		Name:      "related",
 		Usage:     "Get related rows via a relationship",
 		ArgsUsage: "<entity> <reference_id> <relation>",
+		UsageText: `daptin related <entity> <reference_id> <relation> [flags]
+   daptin related user_account <ref_id> usergroup_id
+   daptin related user_account <ref_id> usergroup_id --all --columns name,reference_id
+   daptin related document <ref_id> user_account_id --filter "email contains example" --sort -created_at`,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "columns",
 				Usage: "Comma-separated column names to show",
 			},
+			&cli.IntFlag{
+				Name:  "page-size",
+				Usage: "Number of items per page",
+				Value: 10,
+			},
+			&cli.IntFlag{
+				Name:  "page",
+				Usage: "Page number",
+				Value: 1,
+			},
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "Fetch every page instead of a single page",
+			},
+			&cli.StringFlag{
+				Name:  "sort",
+				Usage: "Sort column (prefix - for descending)",
+			},
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "Filter expression, e.g. name=value or \"name is value\"",
+			},
 		},
 		Action: func(c *cli.Context) error {
 			entityName := c.Args().Get(0)
@@ -264,9 +399,30 @@ func relatedCommand(appCtx *AppContext) *cli.Command {
 			if entityName == "" || referenceId == "" || relation == "" {
 				return fmt.Errorf("usage: related <entity> <reference_id> <relation>")
 			}
-			slog.Info("related", "entity", entityName, "reference_id", referenceId, "relation", relation)
+			slog.Info("related", "entity", entityName, "reference_id", referenceId, "relation", relation, "all", c.Bool("all"))
 
-			result, err := appCtx.Client.FindRelated(entityName, referenceId, relation, nil)
+			params := daptinClient.DaptinQueryParameters{
+				"page[size]":   c.Int("page-size"),
+				"page[number]": c.Int("page"),
+			}
+			if s := c.String("sort"); s != "" {
+				params["sort"] = s
+			}
+			if f := c.String("filter"); f != "" {
+				clauses, err := ParseFilter(f)
+				if err != nil {
+					return err
+				}
+				params["query"] = FilterToJSON(clauses)
+			}
+
+			var result []daptinClient.JsonApiObject
+			var err error
+			if c.Bool("all") {
+				result, err = appCtx.Client.FindRelatedPages(entityName, referenceId, relation, params, c.Int("page-size"))
+			} else {
+				result, err = appCtx.Client.FindRelated(entityName, referenceId, relation, params)
+			}
 			if err != nil {
 				return err
 			}
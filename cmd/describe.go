@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -20,12 +19,16 @@ func describeCommand(appCtx *AppContext) *cli.Command {
 		Subcommands: []*cli.Command{
 			{
 				Name:      "table",
-				Usage:     "Show table schema (columns and actions)",
+				Usage:     "Show table schema (columns, relations, defaults, row count and actions)",
 				ArgsUsage: "<entity>",
+				UsageText: `daptin describe table <entity> [flags]
+   daptin describe table document
+   daptin describe table document --columns name,type,default
+   daptin describe table document --columns ColumnName,DataType,IsPrimaryKey`,
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:  "columns",
-						Usage: "Comma-separated column names to show from schema",
+						Usage: "Comma-separated columns: name, label, type, data_type, nullable, unique, indexed, default, foreign_key, or raw schema keys",
 					},
 				},
 				Action: func(c *cli.Context) error {
@@ -58,87 +61,225 @@ func describeCommand(appCtx *AppContext) *cli.Command {
 
 func describeTable(appCtx *AppContext, entityName, columnsFlag string) error {
 	slog.Info("describe table", "entity", entityName)
-	// Fetch the world definition on demand
-	worlds, err := appCtx.Client.FindAll("world", daptinClient.DaptinQueryParameters{
-		"page[size]": 500,
-	})
+	// Fetch the world definitions on demand; relations need every table's schema
+	worlds, err := loadWorldSchemas(appCtx)
 	if err != nil {
 		return err
 	}
 
-	worldAttrs := client.MapArray(worlds, "attributes")
-	var world map[string]interface{}
-	var worldRefId string
-	for _, w := range worldAttrs {
-		if w["table_name"] == entityName {
-			world = w
-			worldRefId, _ = w["reference_id"].(string)
+	var world *worldSchema
+	for i := range worlds {
+		if worlds[i].TableName == entityName {
+			world = &worlds[i]
 			break
 		}
 	}
 	if world == nil {
 		return fmt.Errorf("entity %q not found", entityName)
 	}
+	worldRefId, _ := world.Attrs["reference_id"].(string)
 	slog.Debug("found world", "entity", entityName, "reference_id", worldRefId)
 
-	schemaJson, ok := world["world_schema_json"].(string)
-	if !ok {
+	if _, ok := world.Attrs["world_schema_json"].(string); !ok {
 		return fmt.Errorf("no schema found for %q", entityName)
 	}
-
-	var schema map[string]interface{}
-	if err := json.Unmarshal([]byte(schemaJson), &schema); err != nil {
-		return err
-	}
-	slog.Debug("parsed schema", "entity", entityName)
-
-	columnsData, ok := schema["Columns"].([]interface{})
+	columnsData, ok := world.Schema["Columns"].([]interface{})
 	if !ok {
 		return fmt.Errorf("no columns in schema")
 	}
 
-	dataMap := make([]map[string]interface{}, 0, len(columnsData))
+	rawColumns := make([]map[string]interface{}, 0, len(columnsData))
 	for _, col := range columnsData {
 		if cm, ok := col.(map[string]interface{}); ok {
-			dataMap = append(dataMap, cm)
+			rawColumns = append(rawColumns, cm)
 		}
 	}
 
-	if columnsFlag == "" {
-		dataMap = render.FilterColumns(dataMap, []string{"ColumnName", "ColumnType"})
-	} else {
-		dataMap = render.FilterColumns(dataMap, strings.Split(columnsFlag, ","))
+	requested := defaultDescribeColumns
+	if columnsFlag != "" {
+		requested = splitCSV(columnsFlag)
 	}
+	columnRows := DescribeColumnRows(rawColumns, requested)
+	relationRows := DescribeRelationRows(CollectSchemaRelations(worlds), entityName)
 
-	if err := appCtx.Renderer.RenderArray(dataMap); err != nil {
-		return err
+	defaults := &tableDefaults{EntityName: entityName, RefID: worldRefId, Schema: world.Schema}
+	permission, hasPermission := defaults.defaultPermission()
+	if !hasPermission {
+		if value, ok := world.Attrs["default_permission"].(float64); ok {
+			permission, hasPermission = int64(value), true
+		}
 	}
+	groups := defaults.defaultGroups()
 
-	// Show actions for this table
+	rowCount, countErr := appCtx.Client.Count(entityName)
+	if countErr != nil {
+		slog.Debug("row count unavailable", "entity", entityName, "error", countErr)
+	}
+
+	// Actions are non-fatal: an error just skips them
+	var worldActions []map[string]interface{}
 	actions, err := appCtx.Client.FindAll("action", daptinClient.DaptinQueryParameters{
 		"page[size]": 500,
 	})
-	if err != nil {
-		return nil // Non-fatal: just skip actions
+	if err == nil {
+		for _, a := range client.MapArray(actions, "attributes") {
+			if a["world_id"] == worldRefId {
+				worldActions = append(worldActions, a)
+			}
+		}
+		worldActions = render.FilterColumns(worldActions, []string{"action_name", "label", "reference_id"})
 	}
+	slog.Debug("found actions", "entity", entityName, "count", len(worldActions))
 
-	actionAttrs := client.MapArray(actions, "attributes")
-	var worldActions []map[string]interface{}
-	for _, a := range actionAttrs {
-		if a["world_id"] == worldRefId {
-			worldActions = append(worldActions, a)
+	if appCtx.StructuredOutput() {
+		description := map[string]interface{}{
+			"table_name":     entityName,
+			"reference_id":   worldRefId,
+			"columns":        columnRows,
+			"relations":      relationRows,
+			"default_groups": groups,
+			"actions":        worldActions,
+		}
+		if hasPermission {
+			description["default_permission"] = permission
+			description["default_permission_decoded"] = DecodePermission(permission)
+		}
+		if countErr == nil {
+			description["row_count"] = rowCount
 		}
+		return appCtx.Renderer.RenderObject(description)
+	}
+
+	if err := appCtx.Renderer.RenderArray(columnRows); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "\nRelations: %d\n", len(relationRows))
+	if len(relationRows) > 0 {
+		if err := appCtx.Renderer.RenderArray(relationRows); err != nil {
+			return err
+		}
+	}
+
+	if hasPermission {
+		fmt.Fprintf(os.Stdout, "\nDefault permission: %d\n%s", permission, FormatPermission(permission))
+	}
+	groupNames := make([]string, 0, len(groups))
+	for _, group := range groups {
+		name := fmt.Sprintf("%v", group["Name"])
+		if groupPermission, ok := group["Permission"]; ok {
+			name = fmt.Sprintf("%s:%v", name, groupPermission)
+		}
+		groupNames = append(groupNames, name)
+	}
+	if len(groupNames) == 0 {
+		groupNames = append(groupNames, "(none)")
+	}
+	fmt.Fprintf(os.Stdout, "Default groups: %s\n", strings.Join(groupNames, ", "))
+	if countErr == nil {
+		fmt.Fprintf(os.Stdout, "Rows: %d\n", rowCount)
 	}
-	slog.Debug("found actions", "entity", entityName, "count", len(worldActions))
 
 	fmt.Fprintf(os.Stdout, "\nActions: %d\n", len(worldActions))
 	if len(worldActions) > 0 {
-		worldActions = render.FilterColumns(worldActions, []string{"action_name", "label", "reference_id"})
 		return appCtx.Renderer.RenderArray(worldActions)
 	}
 	return nil
 }
 
+var defaultDescribeColumns = []string{"name", "type", "nullable", "unique", "indexed", "default", "foreign_key"}
+
+// describeColumnAliases maps the friendly --columns names to Daptin's schema keys.
+var describeColumnAliases = map[string]string{
+	"name":      "ColumnName",
+	"label":     "Name",
+	"type":      "ColumnType",
+	"data_type": "DataType",
+	"nullable":  "IsNullable",
+	"unique":    "IsUnique",
+	"indexed":   "IsIndexed",
+	"default":   "DefaultValue",
+}
+
+// DescribeColumnRows converts raw schema columns into display rows.
+// Requested names may be friendly aliases (name, type, nullable, unique,
+// indexed, default, foreign_key) or raw schema keys such as ColumnName.
+// Pure function.
+func DescribeColumnRows(columns []map[string]interface{}, requested []string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(columns))
+	for _, col := range columns {
+		row := make(map[string]interface{}, len(requested))
+		for _, name := range requested {
+			if name == "foreign_key" {
+				row[name] = columnForeignKey(col)
+				continue
+			}
+			key := name
+			if alias, ok := describeColumnAliases[name]; ok {
+				key = alias
+			}
+			value, ok := col[key]
+			if !ok {
+				if _, isAlias := describeColumnAliases[name]; !isAlias {
+					continue
+				}
+			}
+			switch name {
+			case "nullable", "unique", "indexed":
+				value = boolValue(value)
+			default:
+				if value == nil {
+					value = ""
+				}
+			}
+			row[name] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func columnForeignKey(col map[string]interface{}) string {
+	if !boolValue(col["IsForeignKey"]) {
+		return ""
+	}
+	fk, _ := col["ForeignKeyData"].(map[string]interface{})
+	namespace := stringField(fk, "Namespace")
+	keyName := stringField(fk, "KeyName")
+	if namespace == "" {
+		return "yes"
+	}
+	if keyName == "" {
+		return namespace
+	}
+	return namespace + "(" + keyName + ")"
+}
+
+// DescribeRelationRows lists the relations touching a table from that table's
+// point of view. relation_column is the name accepted by related/relate.
+// Pure function.
+func DescribeRelationRows(relations []SchemaRelation, tableName string) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0)
+	for _, rel := range relations {
+		column := rel.RelationColumnFor(tableName)
+		if column == "" {
+			continue
+		}
+		direction, other := "outgoing", rel.Object
+		if rel.Subject != tableName {
+			direction, other = "incoming", rel.Subject
+		}
+		rows = append(rows, map[string]interface{}{
+			"relation":        rel.Relation,
+			"direction":       direction,
+			"table":           other,
+			"relation_column": column,
+			"join_table":      rel.JoinTable(),
+		})
+	}
+	return rows
+}
+
 func describeAction(appCtx *AppContext, entityName, actionName string) error {
 	schema, err := fetchActionSchemaFromServer(appCtx, entityName, actionName)
 	if err != nil {
@@ -0,0 +1,71 @@
+package cmd
+
+import "testing"
+
+func TestDescribeColumnRowsFriendlyNames(t *testing.T) {
+	columns := []map[string]interface{}{
+		{
+			"ColumnName":   "user_account_id",
+			"ColumnType":   "alias",
+			"IsNullable":   true,
+			"IsIndexed":    true,
+			"DefaultValue": nil,
+			"IsForeignKey": true,
+			"ForeignKeyData": map[string]interface{}{
+				"DataSource": "self",
+				"Namespace":  "user_account",
+				"KeyName":    "id",
+			},
+		},
+	}
+	rows := DescribeColumnRows(columns, defaultDescribeColumns)
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	row := rows[0]
+	if row["name"] != "user_account_id" || row["type"] != "alias" {
+		t.Fatalf("unexpected name/type: %#v", row)
+	}
+	if row["nullable"] != true || row["indexed"] != true || row["unique"] != false {
+		t.Fatalf("unexpected flags: %#v", row)
+	}
+	if row["default"] != "" {
+		t.Fatalf("expected empty default, got %#v", row["default"])
+	}
+	if row["foreign_key"] != "user_account(id)" {
+		t.Fatalf("unexpected foreign key: %#v", row["foreign_key"])
+	}
+}
+
+func TestDescribeColumnRowsRawKeys(t *testing.T) {
+	columns := []map[string]interface{}{
+		{"ColumnName": "title", "DataType": "varchar(500)"},
+	}
+	rows := DescribeColumnRows(columns, []string{"ColumnName", "DataType", "Missing"})
+	if rows[0]["ColumnName"] != "title" || rows[0]["DataType"] != "varchar(500)" {
+		t.Fatalf("unexpected raw row: %#v", rows[0])
+	}
+	if _, ok := rows[0]["Missing"]; ok {
+		t.Fatalf("expected unknown raw key to be omitted: %#v", rows[0])
+	}
+}
+
+func TestDescribeRelationRows(t *testing.T) {
+	relations := []SchemaRelation{
+		{Subject: "document", Relation: "belongs_to", Object: "user_account", SubjectName: "document_id", ObjectName: "user_account_id"},
+		{Subject: "user_account", Relation: "has_many", Object: "usergroup", SubjectName: "user_account_id", ObjectName: "usergroup_id"},
+	}
+	rows := DescribeRelationRows(relations, "user_account")
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %#v", rows)
+	}
+	if rows[0]["direction"] != "incoming" || rows[0]["relation_column"] != "document_id" || rows[0]["table"] != "document" {
+		t.Fatalf("unexpected incoming row: %#v", rows[0])
+	}
+	if rows[1]["direction"] != "outgoing" || rows[1]["relation_column"] != "usergroup_id" {
+		t.Fatalf("unexpected outgoing row: %#v", rows[1])
+	}
+	if rows[1]["join_table"] != "user_account_user_account_id_has_usergroup_usergroup_id" {
+		t.Fatalf("unexpected join table: %#v", rows[1]["join_table"])
+	}
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/daptin/daptin-cli/render"
+	daptinClient "github.com/daptin/daptin-go-client"
+)
+
+// IncludeTree is a parsed --include specification: each relation column maps
+// to the relations to resolve beneath it.
+type IncludeTree map[string]IncludeTree
+
+// ParseIncludePaths parses a comma-separated list of dotted relation paths such
+// as "user_account_id,comments.author" into a tree. A positive depth truncates
+// paths longer than depth levels; zero keeps every path whole.
+// Pure function.
+func ParseIncludePaths(spec string, depth int) (IncludeTree, error) {
+	if depth < 0 {
+		return nil, fmt.Errorf("--depth must be positive, got %d", depth)
+	}
+	tree := IncludeTree{}
+	for _, path := range splitCSV(spec) {
+		segments := strings.Split(path, ".")
+		if depth > 0 && len(segments) > depth {
+			segments = segments[:depth]
+		}
+		node := tree
+		for _, segment := range segments {
+			segment = strings.TrimSpace(segment)
+			if segment == "" {
+				return nil, fmt.Errorf("invalid include path %q", path)
+			}
+			child, ok := node[segment]
+			if !ok {
+				child = IncludeTree{}
+				node[segment] = child
+			}
+			node = child
+		}
+	}
+	if len(tree) == 0 {
+		return nil, fmt.Errorf("--include needs at least one relation")
+	}
+	return tree, nil
+}
+
+// Depth returns the number of levels in the tree.
+func (t IncludeTree) Depth() int {
+	depth := 0
+	for _, child := range t {
+		if d := child.Depth() + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// Relations returns the top-level relation names, sorted.
+func (t IncludeTree) Relations() []string {
+	names := make([]string, 0, len(t))
+	for name := range t {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IncludeNode is one resolved row and the related rows requested beneath it.
+type IncludeNode struct {
+	Type       string
+	ID         string
+	Attributes map[string]interface{}
+	Children   map[string][]*IncludeNode
+	// Single marks relations the server reports as to-one (belongs_to / has_one).
+	Single map[string]bool
+}
+
+// Map converts the node into nested maps for JSON/YAML output. Each resolved
+// relation replaces its column with the related object (to-one) or a list.
+// Pure function.
+func (n *IncludeNode) Map(columns []string) map[string]interface{} {
+	// Both helpers return a copy, so the node's attributes stay untouched
+	result := render.ExcludeColumns(n.Attributes, nil)
+	if len(columns) > 0 {
+		result = render.IncludeColumns(n.Attributes, columns)
+	}
+	for relation, children := range n.Children {
+		if n.Single[relation] {
+			if len(children) == 0 {
+				result[relation] = nil
+			} else {
+				result[relation] = children[0].Map(columns)
+			}
+			continue
+		}
+		list := make([]interface{}, 0, len(children))
+		for _, child := range children {
+			list = append(list, child.Map(columns))
+		}
+		result[relation] = list
+	}
+	return result
+}
+
+// RenderIncludeTree renders a resolved node as an indented text tree. Each row
+// is labelled type/id followed by the requested columns, or by the first of
+// name/title/label/email when no columns are given.
+// Pure function.
+func RenderIncludeTree(node *IncludeNode, columns []string) string {
+	var sb strings.Builder
+	writeIncludeNode(&sb, node, columns, 0)
+	return sb.String()
+}
+
+func writeIncludeNode(sb *strings.Builder, node *IncludeNode, columns []string, level int) {
+	indent := strings.Repeat("  ", level)
+	sb.WriteString(indent + includeNodeLabel(node, columns) + "\n")
+	relations := make([]string, 0, len(node.Children))
+	for relation := range node.Children {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+	for _, relation := range relations {
+		children := node.Children[relation]
+		if len(children) == 0 {
+			sb.WriteString(fmt.Sprintf("%s  %s: (none)\n", indent, relation))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s  %s:\n", indent, relation))
+		for _, child := range children {
+			writeIncludeNode(sb, child, columns, level+2)
+		}
+	}
+}
+
+func includeNodeLabel(node *IncludeNode, columns []string) string {
+	label := node.Type + "/" + node.ID
+	if len(columns) == 0 {
+		for _, key := range []string{"name", "title", "label", "email"} {
+			if value, ok := node.Attributes[key]; ok && value != nil && value != "" {
+				return fmt.Sprintf("%s %s=%v", label, key, value)
+			}
+		}
+		return label
+	}
+	parts := []string{label}
+	for _, column := range columns {
+		if value, ok := node.Attributes[column]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%v", column, value))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// includeResolver walks an IncludeTree, preferring objects the server already
+// sent in the "included" array and falling back to related-row requests.
+type includeResolver struct {
+	fetchRelated func(entity, referenceId, relation string) ([]daptinClient.JsonApiObject, error)
+	included     map[string]daptinClient.JsonApiObject
+}
+
+func newIncludeResolver(included []daptinClient.JsonApiObject, fetchRelated func(entity, referenceId, relation string) ([]daptinClient.JsonApiObject, error)) *includeResolver {
+	index := make(map[string]daptinClient.JsonApiObject, len(included))
+	for _, obj := range included {
+		typeName, _ := obj["type"].(string)
+		index[typeName+"/"+refID(obj)] = obj
+	}
+	return &includeResolver{fetchRelated: fetchRelated, included: index}
+}
+
+func (r *includeResolver) resolve(obj daptinClient.JsonApiObject, defaultType string, tree IncludeTree) (*IncludeNode, error) {
+	node := &IncludeNode{
+		Type:     defaultType,
+		ID:       refID(obj),
+		Children: map[string][]*IncludeNode{},
+		Single:   map[string]bool{},
+	}
+	if typeName, ok := obj["type"].(string); ok && typeName != "" {
+		node.Type = typeName
+	}
+	node.Attributes, _ = obj["attributes"].(map[string]interface{})
+	if node.Attributes == nil {
+		node.Attributes = map[string]interface{}{}
+	}
+
+	for _, relation := range tree.Relations() {
+		identifiers, single, known := relationshipIdentifiers(obj, relation)
+		node.Single[relation] = single
+
+		related, ok := r.lookupIncluded(identifiers)
+		if !known || !ok {
+			slog.Debug("fetching related rows", "entity", node.Type, "reference_id", node.ID, "relation", relation)
+			fetched, err := r.fetchRelated(node.Type, node.ID, relation)
+			if err != nil {
+				return nil, fmt.Errorf("resolve %s.%s: %w", node.Type, relation, err)
+			}
+			related = fetched
+		}
+
+		children := make([]*IncludeNode, 0, len(related))
+		for _, relatedObj := range related {
+			child, err := r.resolve(relatedObj, strings.TrimSuffix(relation, "_id"), tree[relation])
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
+		node.Children[relation] = children
+	}
+	return node, nil
+}
+
+// lookupIncluded returns the included objects for every identifier, or false
+// if any of them is missing.
+func (r *includeResolver) lookupIncluded(identifiers []map[string]interface{}) ([]daptinClient.JsonApiObject, bool) {
+	result := make([]daptinClient.JsonApiObject, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		typeName, _ := identifier["type"].(string)
+		id, _ := identifier["id"].(string)
+		obj, ok := r.included[typeName+"/"+id]
+		if !ok {
+			return nil, false
+		}
+		result = append(result, obj)
+	}
+	return result, true
+}
+
+// relationshipIdentifiers reads relationships.<relation>.data from a JSON:API
+// object. known is false when the server did not send linkage data, which
+// Daptin omits for to-many relations.
+// Pure function.
+func relationshipIdentifiers(obj map[string]interface{}, relation string) (identifiers []map[string]interface{}, single bool, known bool) {
+	relationships, _ := obj["relationships"].(map[string]interface{})
+	entry, _ := relationships[relation].(map[string]interface{})
+	data, present := entry["data"]
+	if !present {
+		return nil, false, false
+	}
+	switch value := data.(type) {
+	case nil:
+		return nil, true, true
+	case map[string]interface{}:
+		return []map[string]interface{}{value}, true, true
+	case []interface{}:
+		for _, item := range value {
+			if m, ok := item.(map[string]interface{}); ok {
+				identifiers = append(identifiers, m)
+			}
+		}
+		return identifiers, false, true
+	}
+	return nil, false, false
+}
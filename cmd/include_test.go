@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	daptinClient "github.com/daptin/daptin-go-client"
+)
+
+func TestParseIncludePaths(t *testing.T) {
+	tree, err := ParseIncludePaths("user_account_id, comments.author,comments.document_id", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree.Relations(), []string{"comments", "user_account_id"}) {
+		t.Fatalf("unexpected relations: %v", tree.Relations())
+	}
+	if !reflect.DeepEqual(tree["comments"].Relations(), []string{"author", "document_id"}) {
+		t.Fatalf("unexpected nested relations: %v", tree["comments"].Relations())
+	}
+	if tree.Depth() != 2 {
+		t.Fatalf("expected depth 2, got %d", tree.Depth())
+	}
+}
+
+func TestParseIncludePathsTruncatesToDepth(t *testing.T) {
+	tree, err := ParseIncludePaths("a.b.c", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Depth() != 2 || len(tree["a"]["b"]) != 0 {
+		t.Fatalf("expected a.b only, got %#v", tree)
+	}
+}
+
+func TestParseIncludePathsRejectsEmptySegment(t *testing.T) {
+	if _, err := ParseIncludePaths("a..b", 0); err == nil {
+		t.Fatal("expected error for empty segment")
+	}
+	if _, err := ParseIncludePaths(" , ", 0); err == nil {
+		t.Fatal("expected error for empty include")
+	}
+}
+
+func TestRelationshipIdentifiers(t *testing.T) {
+	obj := map[string]interface{}{
+		"relationships": map[string]interface{}{
+			"user_account_id": map[string]interface{}{"data": map[string]interface{}{"type": "user_account", "id": "u1"}},
+			"usergroup_id":    map[string]interface{}{"data": []interface{}{map[string]interface{}{"type": "usergroup", "id": "g1"}}},
+			"comments":        map[string]interface{}{"links": map[string]interface{}{}},
+		},
+	}
+	ids, single, known := relationshipIdentifiers(obj, "user_account_id")
+	if !known || !single || len(ids) != 1 {
+		t.Fatalf("unexpected to-one linkage: %v %v %v", ids, single, known)
+	}
+	ids, single, known = relationshipIdentifiers(obj, "usergroup_id")
+	if !known || single || len(ids) != 1 {
+		t.Fatalf("unexpected to-many linkage: %v %v %v", ids, single, known)
+	}
+	if _, _, known = relationshipIdentifiers(obj, "comments"); known {
+		t.Fatal("expected unknown linkage without data")
+	}
+}
+
+func TestIncludeResolverUsesIncludedThenFetches(t *testing.T) {
+	doc := daptinClient.JsonApiObject{
+		"type":       "document",
+		"id":         "d1",
+		"attributes": map[string]interface{}{"reference_id": "d1", "name": "Doc", "user_account_id": "u1"},
+		"relationships": map[string]interface{}{
+			"user_account_id": map[string]interface{}{"data": map[string]interface{}{"type": "user_account", "id": "u1"}},
+		},
+	}
+	included := []daptinClient.JsonApiObject{{
+		"type":       "user_account",
+		"id":         "u1",
+		"attributes": map[string]interface{}{"reference_id": "u1", "email": "a@example.com"},
+	}}
+	var fetched []string
+	resolver := newIncludeResolver(included, func(entity, ref, relation string) ([]daptinClient.JsonApiObject, error) {
+		fetched = append(fetched, entity+"/"+ref+"/"+relation)
+		return []daptinClient.JsonApiObject{{
+			"type":       "usergroup",
+			"id":         "g1",
+			"attributes": map[string]interface{}{"reference_id": "g1", "name": "admins"},
+		}}, nil
+	})
+
+	tree, _ := ParseIncludePaths("user_account_id.usergroup_id", 0)
+	node, err := resolver.resolve(doc, "document", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fetched, []string{"user_account/u1/usergroup_id"}) {
+		t.Fatalf("unexpected fetches: %v", fetched)
+	}
+
+	out := node.Map(nil)
+	account, ok := out["user_account_id"].(map[string]interface{})
+	if !ok || account["email"] != "a@example.com" {
+		t.Fatalf("expected nested account, got %#v", out["user_account_id"])
+	}
+	groups, ok := account["usergroup_id"].([]interface{})
+	if !ok || len(groups) != 1 {
+		t.Fatalf("expected nested group list, got %#v", account["usergroup_id"])
+	}
+	if doc["attributes"].(map[string]interface{})["user_account_id"] != "u1" {
+		t.Fatal("Map must not mutate the source attributes")
+	}
+
+	text := RenderIncludeTree(node, nil)
+	for _, want := range []string{
+		"document/d1 name=Doc\n",
+		"  user_account_id:\n",
+		"    user_account/u1 email=a@example.com\n",
+		"        usergroup/g1 name=admins\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in tree:\n%s", want, text)
+		}
+	}
+}
@@ -73,6 +73,22 @@ func FormatPermission(value int64) string {
 	return sb.String()
 }
 
+// FormatPermissionInline returns the tiers on one line for table cells,
+// e.g. "Guest: Read | Owner: Peek, Read | Group: (none)".
+// Pure function.
+func FormatPermissionInline(value int64) string {
+	p := DecodePermission(value)
+	parts := make([]string, 0, len(tierNames))
+	for i, tier := range [][]string{p.Guest, p.Owner, p.Group} {
+		ops := "(none)"
+		if len(tier) > 0 {
+			ops = strings.Join(tier, ", ")
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", tierNames[i], ops))
+	}
+	return strings.Join(parts, " | ")
+}
+
 // ApplyPermissionModifier applies a +TierOp or -TierOp modifier to a permission value.
 // E.g., "+GuestRead" adds Guest Read, "-OwnerDelete" removes Owner Delete.
 // Pure function.
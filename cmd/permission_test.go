@@ -99,6 +99,16 @@ func TestFormatPermission(t *testing.T) {
 	}
 }
 
+func TestFormatPermissionInline(t *testing.T) {
+	expected := "Guest: Peek, Execute | Owner: Read, Execute | Group: Read, Execute"
+	if s := FormatPermissionInline(561441); s != expected {
+		t.Errorf("expected %q, got %q", expected, s)
+	}
+	if s := FormatPermissionInline(0); s != "Guest: (none) | Owner: (none) | Group: (none)" {
+		t.Errorf("unexpected output for 0: %q", s)
+	}
+}
+
 func containsStr(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsSubstr(s, substr))
 }
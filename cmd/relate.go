@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/urfave/cli/v2"
@@ -11,27 +15,35 @@ import (
 func relateCommand(appCtx *AppContext) *cli.Command {
 	return &cli.Command{
 		Name:      "relate",
-		Usage:     "Add a relationship association between entities",
-		ArgsUsage: "<entity> <reference_id> <relation_column> <target_ref_id>",
+		Usage:     "Add relationship associations between entities",
+		ArgsUsage: "<entity> <reference_id> <relation_column> [target_ref_id ...]",
+		UsageText: `daptin relate <entity> <reference_id> <relation_column> [target_ref_id ...] [flags]
+   daptin relate user_account <ref_id> usergroup_id <group_ref_id>
+   daptin relate user_account <ref_id> usergroup_id <group_ref_1> <group_ref_2>
+   daptin relate user_account <ref_id> usergroup_id --from-file groups.txt
+   daptin -q list usergroup --filter "name like %team%" | daptin relate user_account <ref_id> usergroup_id --from-file -`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "from-file",
+				Usage: "Read target reference ids from `FILE` (one per line, - for stdin)",
+			},
+		},
 		Action: func(c *cli.Context) error {
-			entity := c.Args().Get(0)
-			refId := c.Args().Get(1)
-			relation := c.Args().Get(2)
-			targetRefId := c.Args().Get(3)
-			if entity == "" || refId == "" || relation == "" || targetRefId == "" {
-				return fmt.Errorf("usage: relate <entity> <reference_id> <relation_column> <target_ref_id>")
+			entity, refId, relation, targets, err := relationArgs(c, "relate")
+			if err != nil {
+				return err
 			}
 
-			slog.Info("relate", "entity", entity, "reference_id", refId, "relation", relation)
+			slog.Info("relate", "entity", entity, "reference_id", refId, "relation", relation, "count", len(targets))
 			// Derive target type from relation column name (e.g., "usergroup_id" -> "usergroup")
 			targetType := strings.TrimSuffix(relation, "_id")
 			slog.Debug("derived target type", "target_type", targetType)
 
-			err := appCtx.Client.AddRelation(entity, refId, relation, targetType, targetRefId)
+			err = appCtx.Client.AddRelations(entity, refId, relation, targetType, targets)
 			if err != nil {
 				return err
 			}
-			fmt.Println("Related")
+			fmt.Println(relationResultMessage("Related", len(targets)))
 			return nil
 		},
 	}
@@ -40,26 +52,211 @@ func relateCommand(appCtx *AppContext) *cli.Command {
 func unrelateCommand(appCtx *AppContext) *cli.Command {
 	return &cli.Command{
 		Name:      "unrelate",
-		Usage:     "Remove a relationship association between entities",
-		ArgsUsage: "<entity> <reference_id> <relation_column> <target_ref_id>",
+		Usage:     "Remove relationship associations between entities",
+		ArgsUsage: "<entity> <reference_id> <relation_column> [target_ref_id ...]",
+		UsageText: `daptin unrelate <entity> <reference_id> <relation_column> [target_ref_id ...] [flags]
+   daptin unrelate user_account <ref_id> usergroup_id <group_ref_id>
+   daptin unrelate user_account <ref_id> usergroup_id --from-file groups.txt`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "from-file",
+				Usage: "Read target reference ids from `FILE` (one per line, - for stdin)",
+			},
+		},
 		Action: func(c *cli.Context) error {
-			entity := c.Args().Get(0)
-			refId := c.Args().Get(1)
-			relation := c.Args().Get(2)
-			targetRefId := c.Args().Get(3)
-			if entity == "" || refId == "" || relation == "" || targetRefId == "" {
-				return fmt.Errorf("usage: unrelate <entity> <reference_id> <relation_column> <target_ref_id>")
+			entity, refId, relation, targets, err := relationArgs(c, "unrelate")
+			if err != nil {
+				return err
 			}
 
-			slog.Info("unrelate", "entity", entity, "reference_id", refId, "relation", relation)
+			slog.Info("unrelate", "entity", entity, "reference_id", refId, "relation", relation, "count", len(targets))
 			targetType := strings.TrimSuffix(relation, "_id")
 			slog.Debug("derived target type", "target_type", targetType)
-			err := appCtx.Client.RemoveRelation(entity, refId, relation, targetType, targetRefId)
+			err = appCtx.Client.RemoveRelations(entity, refId, relation, targetType, targets)
 			if err != nil {
 				return err
 			}
-			fmt.Println("Unrelated")
+			fmt.Println(relationResultMessage("Unrelated", len(targets)))
 			return nil
 		},
 	}
 }
+
+func relationsCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "relations",
+		Usage: "Manage the full set of relationship associations",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "replace",
+				Usage:     "Make the given targets the complete set of associations for a relation",
+				ArgsUsage: "<entity> <reference_id> <relation_column> [target_ref_id ...]",
+				UsageText: `daptin relations replace <entity> <reference_id> <relation_column> [target_ref_id ...] [flags]
+   daptin relations replace user_account <ref_id> usergroup_id <group_ref_1> <group_ref_2>
+   daptin relations replace user_account <ref_id> usergroup_id --from-file groups.txt --dry-run`,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from-file",
+						Usage: "Read target reference ids from `FILE` (one per line, - for stdin)",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-empty",
+						Usage: "Allow an empty target list, removing every association",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Show what would be added and removed without changing anything",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					entity, refId, relation, targets, err := relationArgsAllowEmpty(c, "relations replace", c.Bool("allow-empty"))
+					if err != nil {
+						return err
+					}
+					slog.Info("relations replace", "entity", entity, "reference_id", refId, "relation", relation, "count", len(targets))
+					targetType := strings.TrimSuffix(relation, "_id")
+
+					current, err := appCtx.Client.FindRelatedPages(entity, refId, relation, nil, 500)
+					if err != nil {
+						return err
+					}
+					currentIDs := make([]string, 0, len(current))
+					for _, obj := range current {
+						if ref := refID(obj); ref != "" {
+							currentIDs = append(currentIDs, ref)
+						}
+					}
+
+					toAdd, toRemove := DiffRelationIDs(currentIDs, targets)
+					slog.Debug("relations replace plan", "add", len(toAdd), "remove", len(toRemove))
+					if c.Bool("dry-run") {
+						for _, id := range toAdd {
+							fmt.Printf("+ %s\n", id)
+						}
+						for _, id := range toRemove {
+							fmt.Printf("- %s\n", id)
+						}
+						fmt.Printf("Would add %d, remove %d\n", len(toAdd), len(toRemove))
+						return nil
+					}
+					if len(toAdd) > 0 {
+						if err := appCtx.Client.AddRelations(entity, refId, relation, targetType, toAdd); err != nil {
+							return err
+						}
+					}
+					if len(toRemove) > 0 {
+						if err := appCtx.Client.RemoveRelations(entity, refId, relation, targetType, toRemove); err != nil {
+							return err
+						}
+					}
+					fmt.Printf("Added %d, removed %d\n", len(toAdd), len(toRemove))
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func relationArgs(c *cli.Context, command string) (string, string, string, []string, error) {
+	return relationArgsAllowEmpty(c, command, false)
+}
+
+func relationArgsAllowEmpty(c *cli.Context, command string, allowEmpty bool) (string, string, string, []string, error) {
+	usage := fmt.Errorf("usage: %s <entity> <reference_id> <relation_column> <target_ref_id ...> (or --from-file)", command)
+	entity := c.Args().Get(0)
+	refId := c.Args().Get(1)
+	relation := c.Args().Get(2)
+	if entity == "" || refId == "" || relation == "" {
+		return "", "", "", nil, usage
+	}
+
+	targets := append([]string{}, c.Args().Slice()[3:]...)
+	if path := c.String("from-file"); path != "" {
+		var r io.Reader = os.Stdin
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				return "", "", "", nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		fileTargets, err := ParseIDList(r)
+		if err != nil {
+			return "", "", "", nil, err
+		}
+		targets = append(targets, fileTargets...)
+	}
+	targets = uniqueStrings(targets)
+	if len(targets) == 0 && !allowEmpty {
+		return "", "", "", nil, usage
+	}
+	return entity, refId, relation, targets, nil
+}
+
+// ParseIDList reads reference ids separated by newlines, commas or whitespace.
+// Blank lines and lines starting with # are ignored.
+func ParseIDList(r io.Reader) ([]string, error) {
+	var ids []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			ids = append(ids, field)
+		}
+	}
+	return ids, scanner.Err()
+}
+
+// DiffRelationIDs computes which ids to add and remove to turn current into desired.
+// Results are sorted.
+// Pure function.
+func DiffRelationIDs(current, desired []string) ([]string, []string) {
+	currentSet := make(map[string]bool, len(current))
+	for _, id := range current {
+		currentSet[id] = true
+	}
+	desiredSet := make(map[string]bool, len(desired))
+	for _, id := range desired {
+		desiredSet[id] = true
+	}
+	var toAdd, toRemove []string
+	for id := range desiredSet {
+		if !currentSet[id] {
+			toAdd = append(toAdd, id)
+		}
+	}
+	for id := range currentSet {
+		if !desiredSet[id] {
+			toRemove = append(toRemove, id)
+		}
+	}
+	sort.Strings(toAdd)
+	sort.Strings(toRemove)
+	return toAdd, toRemove
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	return result
+}
+
+func relationResultMessage(verb string, count int) string {
+	if count == 1 {
+		return verb
+	}
+	return fmt.Sprintf("%s %d targets", verb, count)
+}
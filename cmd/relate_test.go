@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIDList(t *testing.T) {
+	input := "# groups\nabc\n\ndef, ghi\njkl mno\n"
+	ids, err := ParseIDList(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"abc", "def", "ghi", "jkl", "mno"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+}
+
+func TestDiffRelationIDs(t *testing.T) {
+	toAdd, toRemove := DiffRelationIDs([]string{"a", "b", "c"}, []string{"c", "d", "a"})
+	if !reflect.DeepEqual(toAdd, []string{"d"}) {
+		t.Fatalf("unexpected additions: %v", toAdd)
+	}
+	if !reflect.DeepEqual(toRemove, []string{"b"}) {
+		t.Fatalf("unexpected removals: %v", toRemove)
+	}
+}
+
+func TestDiffRelationIDsEmptyDesiredRemovesAll(t *testing.T) {
+	toAdd, toRemove := DiffRelationIDs([]string{"b", "a"}, nil)
+	if len(toAdd) != 0 || !reflect.DeepEqual(toRemove, []string{"a", "b"}) {
+		t.Fatalf("unexpected diff: %v %v", toAdd, toRemove)
+	}
+}
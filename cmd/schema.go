@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+func schemaCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "schema",
+		Usage: "Inspect how entities relate to each other",
+		Subcommands: []*cli.Command{
+			schemaGraphCommand(appCtx),
+		},
+	}
+}
+
+func schemaGraphCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "graph",
+		Usage: "Render the relationship graph between tables as DOT, Mermaid, or text",
+		UsageText: `daptin schema graph [flags]
+   daptin schema graph
+   daptin schema graph --format dot | dot -Tsvg > schema.svg
+   daptin schema graph --format mermaid --table user_account --table usergroup
+   daptin --output json schema graph`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Graph format: text, dot, or mermaid",
+				Value: "text",
+			},
+			&cli.StringSliceFlag{
+				Name:  "table",
+				Usage: "Only show relations touching this table (repeatable)",
+			},
+			&cli.BoolFlag{
+				Name:  "no-join-tables",
+				Usage: "Hide the generated *_has_* join tables for many-to-many relations",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			format := c.String("format")
+			switch format {
+			case "text", "dot", "mermaid":
+			default:
+				return fmt.Errorf("unknown graph format %q, expected text, dot, or mermaid", format)
+			}
+			slog.Info("schema graph", "format", format)
+
+			worlds, err := loadWorldSchemas(appCtx)
+			if err != nil {
+				return err
+			}
+			relations := CollectSchemaRelations(worlds)
+			if tables := c.StringSlice("table"); len(tables) > 0 {
+				relations = FilterSchemaRelations(relations, tables)
+			}
+			slog.Debug("schema relations", "count", len(relations))
+
+			if appCtx.StructuredOutput() {
+				return appCtx.Renderer.RenderArray(schemaRelationRows(relations))
+			}
+
+			showJoin := !c.Bool("no-join-tables")
+			var out string
+			switch format {
+			case "dot":
+				out = RenderRelationsDOT(relations, showJoin)
+			case "mermaid":
+				out = RenderRelationsMermaid(relations, showJoin)
+			default:
+				out = RenderRelationsText(relations, showJoin)
+			}
+			fmt.Fprint(os.Stdout, out)
+			return nil
+		},
+	}
+}
+
+// worldSchema is a world row together with its decoded world_schema_json.
+type worldSchema struct {
+	TableName string
+	Attrs     map[string]interface{}
+	Schema    map[string]interface{}
+}
+
+// loadWorldSchemas fetches every world row and decodes its schema JSON.
+// Rows whose schema cannot be decoded are kept with an empty schema.
+func loadWorldSchemas(appCtx *AppContext) ([]worldSchema, error) {
+	worlds, err := appCtx.Client.FindAllPages("world", nil, 500)
+	if err != nil {
+		return nil, err
+	}
+	return decodeWorldSchemas(client.MapArray(worlds, "attributes")), nil
+}
+
+func decodeWorldSchemas(worldAttrs []map[string]interface{}) []worldSchema {
+	result := make([]worldSchema, 0, len(worldAttrs))
+	for _, attrs := range worldAttrs {
+		tableName, _ := attrs["table_name"].(string)
+		if tableName == "" {
+			continue
+		}
+		schema := map[string]interface{}{}
+		if schemaJSON, ok := attrs["world_schema_json"].(string); ok && schemaJSON != "" {
+			if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+				slog.Debug("skipping undecodable world schema", "table", tableName, "error", err)
+				schema = map[string]interface{}{}
+			}
+		}
+		result = append(result, worldSchema{TableName: tableName, Attrs: attrs, Schema: schema})
+	}
+	return result
+}
+
+// SchemaRelation is one relation between two tables as declared in a world schema.
+// Pure value type.
+type SchemaRelation struct {
+	Subject     string
+	Relation    string
+	Object      string
+	SubjectName string
+	ObjectName  string
+}
+
+// JoinTable returns the generated join table name for many-to-many style
+// relations, or "" when the relation is stored as a column on the subject.
+func (r SchemaRelation) JoinTable() string {
+	switch r.Relation {
+	case "has_many", "has_many_and_belongs_to_many":
+		return r.Subject + "_" + r.SubjectName + "_has_" + r.Object + "_" + r.ObjectName
+	}
+	return ""
+}
+
+// RelationColumnFor returns the relation name usable with related/relate
+// when starting from the given table, or "" if the table is not part of the relation.
+func (r SchemaRelation) RelationColumnFor(tableName string) string {
+	switch tableName {
+	case r.Subject:
+		return r.ObjectName
+	case r.Object:
+		return r.SubjectName
+	}
+	return ""
+}
+
+func (r SchemaRelation) key() string {
+	return strings.Join([]string{r.Subject, r.Relation, r.Object, r.SubjectName, r.ObjectName}, "|")
+}
+
+// CollectSchemaRelations extracts the de-duplicated relation list from world schemas.
+// Daptin repeats a relation in the schema of both tables it connects.
+// Pure function.
+func CollectSchemaRelations(worlds []worldSchema) []SchemaRelation {
+	seen := map[string]bool{}
+	var relations []SchemaRelation
+	for _, w := range worlds {
+		rawRelations, _ := w.Schema["Relations"].([]interface{})
+		for _, raw := range rawRelations {
+			rm, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rel := SchemaRelation{
+				Subject:     stringField(rm, "Subject"),
+				Relation:    stringField(rm, "Relation"),
+				Object:      stringField(rm, "Object"),
+				SubjectName: stringField(rm, "SubjectName"),
+				ObjectName:  stringField(rm, "ObjectName"),
+			}
+			if rel.Subject == "" || rel.Object == "" || rel.Relation == "" {
+				continue
+			}
+			if rel.SubjectName == "" {
+				rel.SubjectName = rel.Subject + "_id"
+			}
+			if rel.ObjectName == "" {
+				rel.ObjectName = rel.Object + "_id"
+			}
+			if seen[rel.key()] {
+				continue
+			}
+			seen[rel.key()] = true
+			relations = append(relations, rel)
+		}
+	}
+	sort.Slice(relations, func(i, j int) bool {
+		return relations[i].key() < relations[j].key()
+	})
+	return relations
+}
+
+// FilterSchemaRelations keeps relations whose subject or object is one of tables.
+// Pure function.
+func FilterSchemaRelations(relations []SchemaRelation, tables []string) []SchemaRelation {
+	wanted := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		wanted[table] = true
+	}
+	result := make([]SchemaRelation, 0, len(relations))
+	for _, rel := range relations {
+		if wanted[rel.Subject] || wanted[rel.Object] {
+			result = append(result, rel)
+		}
+	}
+	return result
+}
+
+// RenderRelationsText renders a per-table adjacency report.
+// Pure function.
+func RenderRelationsText(relations []SchemaRelation, showJoin bool) string {
+	if len(relations) == 0 {
+		return "No relations found\n"
+	}
+	outgoing := map[string][]SchemaRelation{}
+	incoming := map[string][]SchemaRelation{}
+	for _, rel := range relations {
+		outgoing[rel.Subject] = append(outgoing[rel.Subject], rel)
+		incoming[rel.Object] = append(incoming[rel.Object], rel)
+	}
+
+	var sb strings.Builder
+	for _, table := range relationTables(relations, false) {
+		sb.WriteString(table + "\n")
+		for _, rel := range outgoing[table] {
+			sb.WriteString(fmt.Sprintf("  -> %s %s (%s)", rel.Relation, rel.Object, rel.ObjectName))
+			if join := rel.JoinTable(); join != "" && showJoin {
+				sb.WriteString(" via " + join)
+			}
+			sb.WriteString("\n")
+		}
+		for _, rel := range incoming[table] {
+			sb.WriteString(fmt.Sprintf("  <- %s %s (%s)\n", rel.Subject, rel.Relation, rel.SubjectName))
+		}
+	}
+	return sb.String()
+}
+
+// RenderRelationsDOT renders the relation graph in Graphviz DOT syntax.
+// Pure function.
+func RenderRelationsDOT(relations []SchemaRelation, showJoin bool) string {
+	var sb strings.Builder
+	sb.WriteString("digraph schema {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	for _, table := range relationTables(relations, showJoin) {
+		if strings.Contains(table, "_has_") {
+			sb.WriteString(fmt.Sprintf("  %q [shape=diamond];\n", table))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %q;\n", table))
+	}
+	for _, rel := range relations {
+		join := rel.JoinTable()
+		if join != "" && showJoin {
+			sb.WriteString(fmt.Sprintf("  %q -> %q [label=%q, style=dashed];\n", rel.Subject, join, rel.SubjectName))
+			sb.WriteString(fmt.Sprintf("  %q -> %q [label=%q];\n", join, rel.Object, rel.ObjectName))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %q -> %q [label=%q];\n", rel.Subject, rel.Object, rel.Relation+" "+rel.ObjectName))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// RenderRelationsMermaid renders the relation graph as a Mermaid flowchart.
+// Pure function.
+func RenderRelationsMermaid(relations []SchemaRelation, showJoin bool) string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for _, table := range relationTables(relations, showJoin) {
+		if strings.Contains(table, "_has_") {
+			sb.WriteString(fmt.Sprintf("  %s{{%s}}\n", mermaidID(table), table))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %s[%s]\n", mermaidID(table), table))
+	}
+	for _, rel := range relations {
+		join := rel.JoinTable()
+		if join != "" && showJoin {
+			sb.WriteString(fmt.Sprintf("  %s -.->|%s| %s\n", mermaidID(rel.Subject), rel.SubjectName, mermaidID(join)))
+			sb.WriteString(fmt.Sprintf("  %s -->|%s| %s\n", mermaidID(join), rel.ObjectName, mermaidID(rel.Object)))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  %s -->|%s %s| %s\n", mermaidID(rel.Subject), rel.Relation, rel.ObjectName, mermaidID(rel.Object)))
+	}
+	return sb.String()
+}
+
+func relationTables(relations []SchemaRelation, showJoin bool) []string {
+	seen := map[string]bool{}
+	for _, rel := range relations {
+		seen[rel.Subject] = true
+		seen[rel.Object] = true
+		if join := rel.JoinTable(); join != "" && showJoin {
+			seen[join] = true
+		}
+	}
+	tables := make([]string, 0, len(seen))
+	for table := range seen {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+func mermaidID(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name)
+}
+
+func schemaRelationRows(relations []SchemaRelation) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(relations))
+	for _, rel := range relations {
+		rows = append(rows, map[string]interface{}{
+			"subject":      rel.Subject,
+			"relation":     rel.Relation,
+			"object":       rel.Object,
+			"subject_name": rel.SubjectName,
+			"object_name":  rel.ObjectName,
+			"join_table":   rel.JoinTable(),
+		})
+	}
+	return rows
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+const testUserAccountSchema = `{
+  "TableName": "user_account",
+  "Relations": [
+    {"Subject": "user_account", "Relation": "has_many", "Object": "usergroup", "SubjectName": "user_account_id", "ObjectName": "usergroup_id"}
+  ]
+}`
+
+const testDocumentSchema = `{
+  "TableName": "document",
+  "Relations": [
+    {"Subject": "document", "Relation": "belongs_to", "Object": "user_account", "SubjectName": "document_id", "ObjectName": "user_account_id"},
+    {"Subject": "user_account", "Relation": "has_many", "Object": "usergroup", "SubjectName": "user_account_id", "ObjectName": "usergroup_id"}
+  ]
+}`
+
+func testWorldSchemas() []worldSchema {
+	return decodeWorldSchemas([]map[string]interface{}{
+		{"table_name": "user_account", "world_schema_json": testUserAccountSchema},
+		{"table_name": "document", "world_schema_json": testDocumentSchema},
+		{"table_name": "broken", "world_schema_json": "{not json"},
+	})
+}
+
+func TestCollectSchemaRelationsDeduplicates(t *testing.T) {
+	relations := CollectSchemaRelations(testWorldSchemas())
+	if len(relations) != 2 {
+		t.Fatalf("expected 2 relations, got %#v", relations)
+	}
+	if relations[0].Subject != "document" || relations[0].Relation != "belongs_to" {
+		t.Fatalf("unexpected first relation: %#v", relations[0])
+	}
+}
+
+func TestSchemaRelationJoinTable(t *testing.T) {
+	rel := SchemaRelation{Subject: "user_account", Relation: "has_many", Object: "usergroup", SubjectName: "user_account_id", ObjectName: "usergroup_id"}
+	if got := rel.JoinTable(); got != "user_account_user_account_id_has_usergroup_usergroup_id" {
+		t.Fatalf("unexpected join table: %q", got)
+	}
+	belongs := SchemaRelation{Subject: "document", Relation: "belongs_to", Object: "user_account"}
+	if got := belongs.JoinTable(); got != "" {
+		t.Fatalf("expected no join table for belongs_to, got %q", got)
+	}
+}
+
+func TestSchemaRelationColumnFor(t *testing.T) {
+	rel := SchemaRelation{Subject: "document", Relation: "belongs_to", Object: "user_account", SubjectName: "document_id", ObjectName: "user_account_id"}
+	if got := rel.RelationColumnFor("document"); got != "user_account_id" {
+		t.Fatalf("unexpected subject column: %q", got)
+	}
+	if got := rel.RelationColumnFor("user_account"); got != "document_id" {
+		t.Fatalf("unexpected object column: %q", got)
+	}
+}
+
+func TestFilterSchemaRelations(t *testing.T) {
+	relations := FilterSchemaRelations(CollectSchemaRelations(testWorldSchemas()), []string{"usergroup"})
+	if len(relations) != 1 || relations[0].Object != "usergroup" {
+		t.Fatalf("unexpected filtered relations: %#v", relations)
+	}
+}
+
+func TestRenderRelationsDOT(t *testing.T) {
+	out := RenderRelationsDOT(CollectSchemaRelations(testWorldSchemas()), true)
+	for _, want := range []string{
+		"digraph schema {",
+		`"document" -> "user_account" [label="belongs_to user_account_id"];`,
+		`"user_account_user_account_id_has_usergroup_usergroup_id" [shape=diamond];`,
+		`"user_account_user_account_id_has_usergroup_usergroup_id" -> "usergroup" [label="usergroup_id"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in DOT output:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderRelationsMermaidWithoutJoinTables(t *testing.T) {
+	out := RenderRelationsMermaid(CollectSchemaRelations(testWorldSchemas()), false)
+	if strings.Contains(out, "_has_") {
+		t.Fatalf("expected join tables hidden:\n%s", out)
+	}
+	if !strings.Contains(out, "user_account -->|has_many usergroup_id| usergroup") {
+		t.Fatalf("unexpected mermaid output:\n%s", out)
+	}
+}
+
+func TestRenderRelationsTextShowsBothDirections(t *testing.T) {
+	out := RenderRelationsText(CollectSchemaRelations(testWorldSchemas()), true)
+	if !strings.Contains(out, "  -> belongs_to user_account (user_account_id)") {
+		t.Fatalf("expected outgoing relation:\n%s", out)
+	}
+	if !strings.Contains(out, "  <- document belongs_to (document_id)") {
+		t.Fatalf("expected incoming relation:\n%s", out)
+	}
+}
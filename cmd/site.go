@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/daptin/daptin-cli/client"
+	daptinClient "github.com/daptin/daptin-go-client"
+	"github.com/urfave/cli/v2"
+)
+
+func siteCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "site",
+		Usage: "Manage Daptin sites served from cloud stores",
+		UsageText: `daptin site <command> [options]
+   daptin site create www.example.com --store local-files --path www
+   daptin site list
+   daptin site files ls www.example.com
+   daptin site files put www.example.com ./public --recursive
+   daptin site sync-cache www.example.com`,
+		Description: "A site serves a folder of a cloud_store under a hostname. The server keeps a local cache of that folder; " +
+			"files ls/get read the cache through the site's list_files and get_file actions, while files put/rm change the " +
+			"store through cloud_store actions. Run sync-cache to refresh the cache after changing the store.",
+		Subcommands: []*cli.Command{
+			siteCreateCommand(appCtx),
+			siteListCommand(appCtx),
+			siteDescribeCommand(appCtx),
+			siteRemoveCommand(appCtx),
+			siteFilesCommand(appCtx),
+			siteSyncCacheCommand(appCtx),
+		},
+	}
+}
+
+func siteCreateCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "create",
+		Usage:     "Create a site serving a cloud_store folder",
+		ArgsUsage: "<hostname>",
+		UsageText: `daptin site create <hostname> --store <store> [flags]
+   daptin site create www.example.com --store local-files
+   daptin site create docs.example.com --store s3-prod --path sites/docs --type hugo --https
+   daptin site create files.example.com --store local-files --path shared --ftp`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "store", Usage: "cloud_store name or reference_id holding the site files", Required: true},
+			&cli.StringFlag{Name: "path", Usage: "Folder on the store, defaults to the hostname"},
+			&cli.StringFlag{Name: "type", Value: "static", Usage: "Site type: static or hugo"},
+			&cli.StringFlag{Name: "name", Usage: "Site name, defaults to the hostname"},
+			&cli.BoolFlag{Name: "ftp", Usage: "Enable FTP access to the site folder"},
+			&cli.BoolFlag{Name: "https", Usage: "Enable HTTPS for the hostname"},
+		},
+		Action: func(c *cli.Context) error {
+			hostname := c.Args().Get(0)
+			if hostname == "" {
+				return fmt.Errorf("usage: site create <hostname> --store <store>")
+			}
+			storeRef, err := cloudStoreRef(appCtx, c.String("store"))
+			if err != nil {
+				return err
+			}
+			attrs := map[string]interface{}{
+				"hostname":     hostname,
+				"name":         firstNonEmpty(c.String("name"), hostname),
+				"path":         normalizeRemoteDir(firstNonEmpty(c.String("path"), hostname)),
+				"site_type":    c.String("type"),
+				"ftp_enabled":  c.Bool("ftp"),
+				"enable_https": c.Bool("https"),
+			}
+			site, err := appCtx.Client.Create("site", jsonAPIObject("site", attrs, ""))
+			if err != nil {
+				return err
+			}
+			ref := refID(site)
+			if ref == "" {
+				return fmt.Errorf("site created but has no reference_id; link it to the store manually")
+			}
+			if err := appCtx.Client.AddRelation("site", ref, "cloud_store_id", "cloud_store", storeRef); err != nil {
+				return err
+			}
+			data, _ := site["attributes"].(map[string]interface{})
+			if data == nil {
+				data = site
+			}
+			data["cloud_store_id"] = storeRef
+			if appCtx.Quiet {
+				return printRef(data)
+			}
+			return appCtx.Renderer.RenderObject(data)
+		},
+	}
+}
+
+func siteListCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List sites with their store and folder",
+		UsageText: `daptin site list`,
+		Action: func(c *cli.Context) error {
+			sites, err := loadSites(appCtx)
+			if err != nil {
+				return err
+			}
+			stores, err := storeNames(appCtx)
+			if err != nil {
+				return err
+			}
+			rows := make([]map[string]interface{}, 0, len(sites))
+			for _, site := range sites {
+				rows = append(rows, SiteRow(site, stores))
+			}
+			return appCtx.Renderer.RenderArray(rows)
+		},
+	}
+}
+
+func siteDescribeCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "describe",
+		Usage:     "Show a site's settings, store and URL",
+		ArgsUsage: "<site>",
+		UsageText: `daptin site describe <hostname-name-or-reference-id>
+   daptin site describe www.example.com`,
+		Action: func(c *cli.Context) error {
+			site, err := siteByKey(appCtx, c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			stores, err := storeNames(appCtx)
+			if err != nil {
+				return err
+			}
+			row := SiteRow(site, stores)
+			row["url"] = SiteURL(site)
+			for _, key := range []string{"created_at", "updated_at"} {
+				if site[key] != nil {
+					row[key] = site[key]
+				}
+			}
+			return appCtx.Renderer.RenderObject(row)
+		},
+	}
+}
+
+func siteRemoveCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "remove",
+		Usage:     "Delete a site; the files on the store are kept",
+		ArgsUsage: "<site>",
+		UsageText: `daptin site remove <hostname-name-or-reference-id>
+   daptin site remove www.example.com`,
+		Action: func(c *cli.Context) error {
+			site, err := siteByKey(appCtx, c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			if err := appCtx.Client.Delete("site", refID(site)); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stdout, "Deleted")
+			return nil
+		},
+	}
+}
+
+func siteFilesCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "files",
+		Usage: "List, download, upload and delete files of a site",
+		UsageText: `daptin site files <command> <site> [args]
+   daptin site files ls www.example.com css
+   daptin site files get www.example.com index.html ./index.html
+   daptin site files put www.example.com ./public --recursive
+   daptin site files rm www.example.com old.html`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "ls",
+				Usage:     "List a site folder",
+				ArgsUsage: "<site> [path]",
+				UsageText: `daptin site files ls <site> [path] [--recursive]
+   daptin site files ls www.example.com
+   daptin site files ls www.example.com css --recursive`,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "recursive", Usage: "List files in all subfolders"},
+				},
+				Action: func(c *cli.Context) error {
+					site, err := siteByKey(appCtx, c.Args().Get(0))
+					if err != nil {
+						return err
+					}
+					return renderSiteFiles(appCtx, refID(site), normalizeRemoteDir(c.Args().Get(1)), c.Bool("recursive"))
+				},
+			},
+			{
+				Name:      "get",
+				Usage:     "Download a site file, or a folder with --recursive",
+				ArgsUsage: "<site> <path> [local-path]",
+				UsageText: `daptin site files get <site> <path> [local-path] [--recursive]
+   daptin site files get www.example.com index.html
+   daptin site files get www.example.com / ./backup --recursive`,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "recursive", Usage: "Download a folder and everything below it"},
+				},
+				Action: func(c *cli.Context) error {
+					site, err := siteByKey(appCtx, c.Args().Get(0))
+					if err != nil {
+						return err
+					}
+					if c.Args().Get(1) == "" {
+						return fmt.Errorf("usage: site files get <site> <path> [local-path]")
+					}
+					return downloadSitePath(appCtx, refID(site), normalizeRemoteDir(c.Args().Get(1)), c.Args().Get(2), c.Bool("recursive"))
+				},
+			},
+			{
+				Name:      "put",
+				Usage:     "Upload a file or directory into the site folder on its store",
+				ArgsUsage: "<site> <local-path> [path]",
+				UsageText: `daptin site files put <site> <local-path> [path] [--recursive]
+   daptin site files put www.example.com ./index.html
+   daptin site files put www.example.com ./logo.png img/
+   daptin site files put www.example.com ./public --recursive`,
+				Description: "Uploads go to the site's cloud_store like storage upload. A path ending in \"/\" (or no path) is a folder; " +
+					"otherwise a single file is renamed to it. Run site sync-cache afterwards so the site serves the new files.",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{Name: "recursive", Usage: "Upload a directory recursively"},
+				}, uploadBatchFlags()...),
+				Action: func(c *cli.Context) error {
+					site, err := siteByKey(appCtx, c.Args().Get(0))
+					if err != nil {
+						return err
+					}
+					localPath := c.Args().Get(1)
+					if localPath == "" {
+						return fmt.Errorf("usage: site files put <site> <local-path> [path]")
+					}
+					maxBytes, maxFiles, err := uploadBatchLimits(c)
+					if err != nil {
+						return err
+					}
+					storeRef, sitePath, err := siteStore(site)
+					if err != nil {
+						return err
+					}
+					files, actionPath, err := planUploadFiles(localPath, SiteStorePath(sitePath, c.Args().Get(2)), c.Bool("recursive"))
+					if err != nil {
+						return err
+					}
+					if len(files) == 0 {
+						return fmt.Errorf("nothing to upload in %q", localPath)
+					}
+					slog.Info("site files put", "site", site["hostname"], "path", actionPath, "files", len(files))
+					return uploadFiles(appCtx, storeRef, actionPath, files, maxBytes, maxFiles).finish()
+				},
+			},
+			{
+				Name:      "rm",
+				Usage:     "Delete a file or folder from the site folder on its store",
+				ArgsUsage: "<site> <path>",
+				UsageText: `daptin site files rm <site> <path>
+   daptin site files rm www.example.com old.html
+   daptin site files rm www.example.com drafts`,
+				Action: func(c *cli.Context) error {
+					site, err := siteByKey(appCtx, c.Args().Get(0))
+					if err != nil {
+						return err
+					}
+					storeRef, sitePath, err := siteStore(site)
+					if err != nil {
+						return err
+					}
+					rel := c.Args().Get(1)
+					target := path.Clean(SiteStorePath(sitePath, rel))
+					if target == path.Join("/", sitePath) {
+						return fmt.Errorf("refusing to delete the site root; give a path inside the site")
+					}
+					if _, err := executeStoreAction(appCtx, storeRef, "delete_path", map[string]interface{}{"path": target}); err != nil {
+						return err
+					}
+					if !appCtx.Quiet {
+						fmt.Fprintf(os.Stdout, "Deleted %s\n", rel)
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func siteSyncCacheCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "sync-cache",
+		Usage:     "Refresh the server's cached copy of a site from its store",
+		ArgsUsage: "[site]",
+		UsageText: `daptin site sync-cache <site>
+   daptin site sync-cache www.example.com
+   daptin site sync-cache --all`,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "all", Usage: "Refresh every site"},
+		},
+		Action: func(c *cli.Context) error {
+			var sites []map[string]interface{}
+			switch {
+			case c.Bool("all"):
+				all, err := loadSites(appCtx)
+				if err != nil {
+					return err
+				}
+				sites = all
+			case c.Args().Get(0) != "":
+				site, err := siteByKey(appCtx, c.Args().Get(0))
+				if err != nil {
+					return err
+				}
+				sites = []map[string]interface{}{site}
+			default:
+				return fmt.Errorf("usage: site sync-cache <site> or --all")
+			}
+			var failed []string
+			for _, site := range sites {
+				hostname, _ := site["hostname"].(string)
+				responses, err := appCtx.Client.Execute("sync_site_storage", "site", daptinClient.JsonApiObject{
+					"site_id": refID(site),
+					"path":    "",
+				})
+				if err == nil {
+					err = ActionFailure(responses)
+				}
+				if err != nil {
+					failed = append(failed, hostname)
+					fmt.Fprintf(os.Stderr, "%s: FAIL %v\n", hostname, err)
+					continue
+				}
+				if !appCtx.Quiet {
+					fmt.Fprintf(os.Stdout, "%s: cache refreshed\n", hostname)
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%d of %d sites failed to refresh: %s", len(failed), len(sites), strings.Join(failed, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func loadSites(appCtx *AppContext) ([]map[string]interface{}, error) {
+	result, err := appCtx.Client.FindAll("site", daptinClient.DaptinQueryParameters{"page[size]": 500})
+	if err != nil {
+		return nil, err
+	}
+	return client.MapArray(result, "attributes"), nil
+}
+
+func siteByKey(appCtx *AppContext, key string) (map[string]interface{}, error) {
+	if key == "" {
+		return nil, fmt.Errorf("site hostname, name or reference_id required")
+	}
+	sites, err := loadSites(appCtx)
+	if err != nil {
+		return nil, err
+	}
+	for _, site := range sites {
+		if SiteMatches(site, key) {
+			return site, nil
+		}
+	}
+	return nil, fmt.Errorf("site %q not found", key)
+}
+
+// storeNames maps cloud_store reference_id to name for display.
+func storeNames(appCtx *AppContext) (map[string]string, error) {
+	result, err := appCtx.Client.FindAll("cloud_store", daptinClient.DaptinQueryParameters{"page[size]": 500})
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]string{}
+	for _, store := range client.MapArray(result, "attributes") {
+		ref, _ := store["reference_id"].(string)
+		name, _ := store["name"].(string)
+		names[ref] = name
+	}
+	return names, nil
+}
+
+// siteStore returns the cloud_store reference_id and folder a site serves.
+func siteStore(site map[string]interface{}) (string, string, error) {
+	storeRef, _ := site["cloud_store_id"].(string)
+	if storeRef == "" {
+		return "", "", fmt.Errorf("site %v is not linked to a cloud_store", site["hostname"])
+	}
+	sitePath, _ := site["path"].(string)
+	return storeRef, sitePath, nil
+}
+
+// SiteStorePath turns a path inside a site into a store path for
+// planUploadFiles, keeping a trailing "/" (or an empty path) as a folder.
+// ".." cannot climb out of the site folder.
+// Pure function.
+func SiteStorePath(sitePath, rel string) string {
+	joined := path.Join("/", sitePath, path.Join("/", rel))
+	if rel == "" || strings.HasSuffix(rel, "/") {
+		return strings.TrimSuffix(joined, "/") + "/"
+	}
+	return joined
+}
+
+// SiteURL is the address the site is served on.
+// Pure function.
+func SiteURL(site map[string]interface{}) string {
+	scheme := "http"
+	if enabled, _ := site["enable_https"].(bool); enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%v/", scheme, site["hostname"])
+}
+
+// SiteRow is the display row for a site, with the store shown by name.
+// Pure function.
+func SiteRow(site map[string]interface{}, storeNames map[string]string) map[string]interface{} {
+	storeRef, _ := site["cloud_store_id"].(string)
+	store := storeNames[storeRef]
+	if store == "" {
+		store = storeRef
+	}
+	return map[string]interface{}{
+		"hostname":     site["hostname"],
+		"name":         site["name"],
+		"path":         site["path"],
+		"site_type":    site["site_type"],
+		"store":        store,
+		"ftp_enabled":  site["ftp_enabled"],
+		"enable_https": site["enable_https"],
+		"reference_id": site["reference_id"],
+	}
+}
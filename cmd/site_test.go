@@ -0,0 +1,54 @@
+package cmd
+
+import "testing"
+
+func TestSiteStorePath(t *testing.T) {
+	cases := []struct{ site, rel, want string }{
+		{"www", "", "/www/"},
+		{"", "", "/"},
+		{"www", "index.html", "/www/index.html"},
+		{"/www/", "img/", "/www/img/"},
+		{"www", "../etc/passwd", "/www/etc/passwd"},
+		{"www", "..", "/www"},
+	}
+	for _, tc := range cases {
+		if got := SiteStorePath(tc.site, tc.rel); got != tc.want {
+			t.Errorf("SiteStorePath(%q, %q) = %q, want %q", tc.site, tc.rel, got, tc.want)
+		}
+	}
+}
+
+func TestSiteURL(t *testing.T) {
+	if got := SiteURL(map[string]interface{}{"hostname": "a.example.com"}); got != "http://a.example.com/" {
+		t.Errorf("got %q", got)
+	}
+	if got := SiteURL(map[string]interface{}{"hostname": "a.example.com", "enable_https": true}); got != "https://a.example.com/" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSiteRow_StoreName(t *testing.T) {
+	site := map[string]interface{}{"hostname": "a.example.com", "cloud_store_id": "ref-1", "path": "www"}
+	row := SiteRow(site, map[string]string{"ref-1": "local-files"})
+	if row["store"] != "local-files" || row["path"] != "www" {
+		t.Errorf("row = %v", row)
+	}
+	row = SiteRow(site, nil)
+	if row["store"] != "ref-1" {
+		t.Errorf("unknown store should fall back to reference_id, got %v", row["store"])
+	}
+}
+
+func TestSiteMatches(t *testing.T) {
+	site := map[string]interface{}{"hostname": "a.example.com", "name": "blog", "reference_id": "ref-9"}
+	for _, key := range []string{"a.example.com", "blog", "ref-9"} {
+		if !SiteMatches(site, key) {
+			t.Errorf("SiteMatches(%q) = false", key)
+		}
+	}
+	for _, key := range []string{"", "b.example.com"} {
+		if SiteMatches(site, key) {
+			t.Errorf("SiteMatches(%q) = true", key)
+		}
+	}
+}
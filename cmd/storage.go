@@ -1,10 +1,8 @@
 package cmd
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io/fs"
 	"mime"
 	"os"
 	"path/filepath"
@@ -25,19 +23,27 @@ func storageCommand(appCtx *AppContext) *cli.Command {
    daptin storage add minio --type s3 --provider Minio --endpoint http://localhost:9000 --access-key minioadmin --secret-key minioadmin123 --bucket daptin-test
    daptin storage add local-files --type local --store-provider local --root-path /tmp/daptin-files
    daptin storage upload local-files:/photos ./image.jpg
+   daptin storage sync ./public local-files:/site --delete
+   daptin storage ls local-files:/photos
+   daptin storage download local-files:/photos/image.jpg
    daptin storage mkdir local-files:/photos
-   daptin storage rm local-files:/photos/old.jpg`,
-		Description: "These commands wrap Daptin's cloud_store records and supported cloud_store actions. Direct cloud_store ls/download are not exposed by Daptin; use site file actions or asset routes for those flows.",
+   daptin storage rm local-files:/photos/old.jpg
+   daptin storage shell local-files`,
+		Description: "These commands wrap Daptin's cloud_store records and supported cloud_store actions. ls, download and sync reach store paths through the list_files and get_file actions of a site bound to the store; see daptin site to manage sites.",
 		Subcommands: []*cli.Command{
 			storageAddCommand(appCtx),
 			storageListCommand(appCtx),
 			storageRemoveCommand(appCtx),
+			storageCredentialCommand(appCtx),
 			storageUploadCommand(appCtx),
+			storageSyncCommand(appCtx),
 			storageMkdirCommand(appCtx),
 			storageRemovePathCommand(appCtx),
+			storageCopyCommand(appCtx),
 			storageMoveCommand(appCtx),
-			storageUnsupportedCommand("ls", "direct cloud_store listing is not exposed by Daptin; use site list_files for site storage paths"),
-			storageUnsupportedCommand("download", "direct cloud_store download is not exposed by Daptin; use site get_file or /asset routes for asset columns"),
+			storageListPathCommand(appCtx),
+			storageDownloadCommand(appCtx),
+			storageShellCommand(appCtx),
 		},
 	}
 }
@@ -179,39 +185,6 @@ func storageRemoveCommand(appCtx *AppContext) *cli.Command {
 	}
 }
 
-func storageUploadCommand(appCtx *AppContext) *cli.Command {
-	return &cli.Command{
-		Name:      "upload",
-		Usage:     "Upload a file or recursive directory to cloud_store via upload_file",
-		ArgsUsage: "<store:/path> <local-path>",
-		UsageText: `daptin storage upload <store:/path> <local-path>
-   daptin storage upload local-files:/photos ./image.jpg
-   daptin storage upload local-files:/docs/ ./manual.pdf
-   daptin storage upload local-files:/site/ ./public --recursive`,
-		Flags: []cli.Flag{
-			&cli.BoolFlag{Name: "recursive", Usage: "Upload a directory recursively"},
-		},
-		Action: func(c *cli.Context) error {
-			address, localPath := c.Args().Get(0), c.Args().Get(1)
-			if address == "" || localPath == "" {
-				return fmt.Errorf("usage: storage upload <store:/path> <local-path>")
-			}
-			storeName, destPath, err := parseStorageAddress(address)
-			if err != nil {
-				return err
-			}
-			files, actionPath, err := buildUploadFiles(localPath, destPath, c.Bool("recursive"))
-			if err != nil {
-				return err
-			}
-			return executeCloudStoreAction(appCtx, storeName, "upload_file", map[string]interface{}{
-				"path": actionPath,
-				"file": files,
-			})
-		},
-	}
-}
-
 func storageMkdirCommand(appCtx *AppContext) *cli.Command {
 	return &cli.Command{
 		Name:      "mkdir",
@@ -253,10 +226,14 @@ func storageRemovePathCommand(appCtx *AppContext) *cli.Command {
 func storageMoveCommand(appCtx *AppContext) *cli.Command {
 	return &cli.Command{
 		Name:      "mv",
-		Usage:     "Move or rename a path in the same cloud_store",
+		Usage:     "Move or rename a path, within a cloud_store or to another one",
 		ArgsUsage: "<store:/source> <store:/destination>",
 		UsageText: `daptin storage mv <store:/source> <store:/destination>
-   daptin storage mv local-files:/old.jpg local-files:/archive/old.jpg`,
+   daptin storage mv local-files:/old.jpg local-files:/archive/old.jpg
+   daptin storage mv local-files:/photos s3-prod:/photos --recursive`,
+		Description: "Within one store this is the server-side move_path action. Between stores the files are copied as in storage cp " +
+			"and the source is deleted once they have arrived; after a partial folder copy only the copied files are deleted.",
+		Flags: storageCopyFlags(),
 		Action: func(c *cli.Context) error {
 			srcStore, source, err := parseStorageAddress(c.Args().Get(0))
 			if err != nil {
@@ -267,126 +244,19 @@ func storageMoveCommand(appCtx *AppContext) *cli.Command {
 				return err
 			}
 			if srcStore != dstStore {
-				return fmt.Errorf("mv requires source and destination in the same store")
+				return copyStorePaths(appCtx, c, true)
 			}
 			return executeCloudStoreAction(appCtx, srcStore, "move_path", map[string]interface{}{"source": source, "destination": destination})
 		},
 	}
 }
 
-func storageUnsupportedCommand(name, reason string) *cli.Command {
-	return &cli.Command{
-		Name:  name,
-		Usage: reason,
-		Action: func(c *cli.Context) error {
-			return fmt.Errorf("%s is not supported for cloud_store: %s", name, reason)
-		},
-	}
-}
-
-func assetCommand(appCtx *AppContext) *cli.Command {
-	return &cli.Command{
-		Name:  "asset",
-		Usage: "Manage file asset columns",
-		UsageText: `daptin asset <command> [options]
-   daptin asset upload product <product_reference_id> photo ./image.jpg
-   daptin asset list product <product_reference_id> photo`,
-		Description: "Asset commands use Daptin's /asset routes for file.* columns on normal entity rows.",
-		Subcommands: []*cli.Command{
-			assetUploadCommand(appCtx),
-			assetListCommand(appCtx),
-		},
-	}
-}
-
-func assetUploadCommand(appCtx *AppContext) *cli.Command {
-	return &cli.Command{
-		Name:      "upload",
-		Usage:     "Stream a file into a file.* asset column",
-		ArgsUsage: "<entity> <reference_id> <column> <local-file>",
-		UsageText: `daptin asset upload <entity> <reference_id> <column> <local-file>
-   daptin asset upload product <product_reference_id> photo ./image.jpg`,
-		Action: func(c *cli.Context) error {
-			entityName, referenceID, columnName, localPath := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2), c.Args().Get(3)
-			if entityName == "" || referenceID == "" || columnName == "" || localPath == "" {
-				return fmt.Errorf("usage: asset upload <entity> <reference_id> <column> <local-file>")
-			}
-			info, err := os.Stat(localPath)
-			if err != nil {
-				return err
-			}
-			if info.IsDir() {
-				return fmt.Errorf("asset upload expects a file, got directory %q", localPath)
-			}
-			file, err := os.Open(localPath)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-
-			filename := filepath.Base(localPath)
-			contentType := contentTypeForPath(localPath)
-			upload, err := appCtx.Client.UploadAssetStream(entityName, referenceID, columnName, filename, contentType, info.Size(), file)
-			if err != nil {
-				return err
-			}
-			uploadID, _ := upload["upload_id"].(string)
-			complete, err := appCtx.Client.CompleteAssetUpload(entityName, referenceID, columnName, filename, uploadID, contentType, info.Size())
-			if err != nil {
-				return err
-			}
-			return appCtx.Renderer.RenderObject(complete)
-		},
-	}
-}
-
-func assetListCommand(appCtx *AppContext) *cli.Command {
-	return &cli.Command{
-		Name:      "list",
-		Usage:     "List files recorded in a file.* asset column",
-		ArgsUsage: "<entity> <reference_id> <column>",
-		UsageText: `daptin asset list <entity> <reference_id> <column>
-   daptin asset list product <product_reference_id> photo`,
-		Action: func(c *cli.Context) error {
-			entityName, referenceID, columnName := c.Args().Get(0), c.Args().Get(1), c.Args().Get(2)
-			if entityName == "" || referenceID == "" || columnName == "" {
-				return fmt.Errorf("usage: asset list <entity> <reference_id> <column>")
-			}
-			row, err := appCtx.Client.FindOne(entityName, referenceID, nil)
-			if err != nil {
-				return err
-			}
-			attrs, _ := row["attributes"].(map[string]interface{})
-			if attrs == nil {
-				return fmt.Errorf("no attributes returned")
-			}
-			files, ok := attrs[columnName].([]interface{})
-			if !ok {
-				if fileMaps, ok := attrs[columnName].([]map[string]interface{}); ok {
-					rows := make([]map[string]interface{}, 0, len(fileMaps))
-					rows = append(rows, fileMaps...)
-					return appCtx.Renderer.RenderArray(rows)
-				}
-				return fmt.Errorf("column %q has no file list", columnName)
-			}
-			rows := make([]map[string]interface{}, 0, len(files))
-			for _, file := range files {
-				if row, ok := file.(map[string]interface{}); ok {
-					rows = append(rows, row)
-				}
-			}
-			return appCtx.Renderer.RenderArray(rows)
-		},
-	}
-}
-
 func executeCloudStoreAction(appCtx *AppContext, storeName, actionName string, attrs map[string]interface{}) error {
 	ref, err := cloudStoreRef(appCtx, storeName)
 	if err != nil {
 		return err
 	}
-	attrs["cloud_store_id"] = ref
-	responses, err := appCtx.Client.Execute(actionName, "cloud_store", daptinClient.JsonApiObject(attrs))
+	responses, err := executeStoreAction(appCtx, ref, actionName, attrs)
 	if err != nil {
 		return err
 	}
@@ -397,6 +267,18 @@ func executeCloudStoreAction(appCtx *AppContext, storeName, actionName string, a
 	return applyEffects(effects, appCtx)
 }
 
+// executeStoreAction runs a cloud_store action on a store reference and
+// returns its responses, or an error when the server reports a failure as
+// an error notification.
+func executeStoreAction(appCtx *AppContext, storeRef, actionName string, attrs map[string]interface{}) ([]daptinClient.DaptinActionResponse, error) {
+	attrs["cloud_store_id"] = storeRef
+	responses, err := appCtx.Client.Execute(actionName, "cloud_store", daptinClient.JsonApiObject(attrs))
+	if err != nil {
+		return nil, err
+	}
+	return responses, ActionFailure(responses)
+}
+
 func cloudStoreRef(appCtx *AppContext, nameOrRef string) (string, error) {
 	if nameOrRef == "" {
 		return "", fmt.Errorf("cloud store name or reference_id required")
@@ -547,66 +429,6 @@ func splitRemoteParentName(targetPath string) (string, string) {
 	return strings.TrimPrefix(parent, "/"), name
 }
 
-func buildUploadFiles(localPath, destPath string, recursive bool) ([]map[string]interface{}, string, error) {
-	info, err := os.Stat(localPath)
-	if err != nil {
-		return nil, "", err
-	}
-	if info.IsDir() {
-		if !recursive {
-			return nil, "", fmt.Errorf("directory upload requires --recursive")
-		}
-		files := make([]map[string]interface{}, 0)
-		err := filepath.WalkDir(localPath, func(path string, entry fs.DirEntry, walkErr error) error {
-			if walkErr != nil {
-				return walkErr
-			}
-			if entry.IsDir() {
-				return nil
-			}
-			rel, err := filepath.Rel(localPath, path)
-			if err != nil {
-				return err
-			}
-			file, err := fileUploadObject(path, filepath.ToSlash(rel))
-			if err != nil {
-				return err
-			}
-			files = append(files, file)
-			return nil
-		})
-		return files, normalizeRemoteDir(destPath), err
-	}
-
-	actionPath := normalizeRemoteDir(destPath)
-	filename := filepath.Base(localPath)
-	if !strings.HasSuffix(destPath, "/") {
-		base := filepath.Base(destPath)
-		if base != "." && base != "/" && base != "" {
-			filename = base
-			actionPath = normalizeRemoteDir(filepath.Dir(destPath))
-		}
-	}
-	file, err := fileUploadObject(localPath, filename)
-	if err != nil {
-		return nil, "", err
-	}
-	return []map[string]interface{}{file}, actionPath, nil
-}
-
-func fileUploadObject(localPath, name string) (map[string]interface{}, error) {
-	data, err := os.ReadFile(localPath)
-	if err != nil {
-		return nil, err
-	}
-	contentType := contentTypeForPath(localPath)
-	return map[string]interface{}{
-		"name": name,
-		"type": contentType,
-		"file": "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data),
-	}, nil
-}
-
 func normalizeRemoteDir(path string) string {
 	if path == "" || path == "." || path == "/" {
 		return ""
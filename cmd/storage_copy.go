@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+func storageCopyFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.BoolFlag{Name: "recursive", Usage: "Copy a folder and everything below it"},
+		&cli.StringFlag{Name: "site", Usage: "Site hostname, name or reference_id to read the source through"},
+	}, uploadBatchFlags()...)
+}
+
+func storageCopyCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "cp",
+		Usage:     "Copy a file or folder within or between cloud stores",
+		ArgsUsage: "<store:/source> <store:/destination>",
+		UsageText: `daptin storage cp <store:/source> <store:/destination> [flags]
+   daptin storage cp local-files:/photos/a.jpg s3-prod:/photos/
+   daptin storage cp local-files:/photos/a.jpg s3-prod:/photos/renamed.jpg
+   daptin storage cp local-files:/site s3-prod:/site --recursive`,
+		Description: "Daptin has no copy action, so files are downloaded through the site serving the source path (see storage download) " +
+			"into a temporary directory and uploaded to the destination. Relative paths are kept and content types come from file extensions.",
+		Flags: storageCopyFlags(),
+		Action: func(c *cli.Context) error {
+			return copyStorePaths(appCtx, c, false)
+		},
+	}
+}
+
+// copyStorePaths copies source to destination through a temporary
+// directory. With move, source files that arrived are deleted afterwards.
+func copyStorePaths(appCtx *AppContext, c *cli.Context, move bool) error {
+	source, destination := c.Args().Get(0), c.Args().Get(1)
+	if source == "" || destination == "" {
+		return fmt.Errorf("usage: storage %s <store:/source> <store:/destination>", c.Command.Name)
+	}
+	srcStore, srcPath, err := parseStorageAddress(source)
+	if err != nil {
+		return err
+	}
+	dstStore, dstPath, err := parseStorageAddress(destination)
+	if err != nil {
+		return err
+	}
+	maxBytes, maxFiles, err := uploadBatchLimits(c)
+	if err != nil {
+		return err
+	}
+	recursive := c.Bool("recursive")
+	if move && normalizeRemoteDir(srcPath) == "" {
+		return fmt.Errorf("refusing to move the root of %s", srcStore)
+	}
+
+	srcRef, err := cloudStoreRef(appCtx, srcStore)
+	if err != nil {
+		return err
+	}
+	site, sitePath, err := findStoreSite(appCtx, srcRef, normalizeRemoteDir(srcPath), c.String("site"))
+	if err != nil {
+		return err
+	}
+	if !recursive && sitePath == "" {
+		return fmt.Errorf("copying a folder requires --recursive")
+	}
+	dstRef, err := cloudStoreRef(appCtx, dstStore)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp("", "daptin-cp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	progress := func(format string, args ...interface{}) {
+		if !appCtx.Quiet {
+			fmt.Fprintf(os.Stderr, format, args...)
+		}
+	}
+
+	// Download into tmp/<name> for a file or tmp/<rel> for a folder, so the
+	// upload plan below sees the same layout as storage upload would
+	var names []string
+	localRoot := tmp
+	if recursive {
+		files, err := listRemoteFiles(appCtx, refID(site), sitePath)
+		if err != nil {
+			return fmt.Errorf("list source files: %w", err)
+		}
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		names = []string{path.Base(sitePath)}
+		localRoot = filepath.Join(tmp, names[0])
+	}
+	var failed []string
+	for i, name := range names {
+		remote := sitePath
+		if recursive {
+			remote = path.Join(sitePath, name)
+		}
+		target, err := SafeLocalPath(tmp, name)
+		if err == nil {
+			_, err = downloadSiteFile(appCtx, refID(site), remote, target)
+		}
+		if err != nil {
+			failed = append(failed, name)
+			progress("[%d/%d] FAIL download %s: %v\n", i+1, len(names), name, err)
+		}
+	}
+	if len(failed) == len(names) {
+		return fmt.Errorf("nothing copied: %d of %d files failed to download", len(failed), len(names))
+	}
+
+	files, actionPath, err := planUploadFiles(localRoot, dstPath, recursive)
+	if err != nil {
+		return err
+	}
+	slog.Info("storage copy", "from", source, "to", destination, "files", len(files), "move", move)
+	up := uploadFiles(appCtx, dstRef, actionPath, files, maxBytes, maxFiles)
+	for _, f := range up.failed {
+		failed = append(failed, fmt.Sprint(f["name"]))
+	}
+
+	if move {
+		// Only files that were copied are deleted. The listing comes from
+		// the site's cache, so deleting the whole folder could remove files
+		// the cache never showed and that were never copied.
+		var targets []string
+		switch {
+		case recursive:
+			targets = CopiedSourcePaths(srcPath, names, failed)
+		case len(failed) == 0:
+			targets = []string{srcPath}
+		}
+		for _, target := range targets {
+			if _, err := executeStoreAction(appCtx, srcRef, "delete_path", map[string]interface{}{"path": target}); err != nil {
+				return fmt.Errorf("copied but could not delete source %s: %w", target, err)
+			}
+		}
+	}
+
+	verb := "Copied"
+	if move {
+		verb = "Moved"
+	}
+	switch {
+	case appCtx.Quiet:
+	case appCtx.StructuredOutput():
+		if err := appCtx.Renderer.RenderObject(map[string]interface{}{
+			"copied": up.uploaded,
+			"bytes":  up.bytes,
+			"failed": len(failed),
+			"moved":  move,
+		}); err != nil {
+			return err
+		}
+	default:
+		fmt.Fprintf(os.Stdout, "%s %d files (%s) from %s to %s, %d failed\n", verb, up.uploaded, FormatBytes(up.bytes), source, destination, len(failed))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d files failed to copy", len(failed), len(names))
+	}
+	return nil
+}
+
+// CopiedSourcePaths lists the source store paths of files that were copied,
+// i.e. names minus failed, for deleting after a partial move.
+// Pure function.
+func CopiedSourcePaths(srcPath string, names, failed []string) []string {
+	skip := make(map[string]bool, len(failed))
+	for _, name := range failed {
+		skip[name] = true
+	}
+	var paths []string
+	for _, name := range names {
+		if !skip[name] {
+			paths = append(paths, path.Join("/", srcPath, name))
+		}
+	}
+	return paths
+}
@@ -0,0 +1,497 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	daptinClient "github.com/daptin/daptin-go-client"
+	"github.com/urfave/cli/v2"
+)
+
+func storageCredentialCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "credential",
+		Usage: "Inspect, update, rotate and test the rclone credentials behind cloud stores",
+		UsageText: `daptin storage credential <command> <name> [flags]
+   daptin storage credential show minio
+   daptin storage credential update minio --endpoint http://minio:9000 --param region=eu-west-1
+   daptin storage credential rotate minio --access-key NEWKEY --secret-key-env NEW_SECRET
+   daptin storage credential test minio`,
+		Subcommands: []*cli.Command{
+			storageCredentialShowCommand(appCtx),
+			storageCredentialUpdateCommand(appCtx),
+			storageCredentialRotateCommand(appCtx),
+			storageCredentialTestCommand(appCtx),
+		},
+	}
+}
+
+func storageCredentialShowCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "show",
+		Usage:     "Show a credential with secrets redacted, and the stores using it",
+		ArgsUsage: "<name>",
+		UsageText: `daptin storage credential show <name>
+   daptin storage credential show minio
+   daptin storage credential show minio -o json --reveal`,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "reveal", Usage: "Print secret values in full"},
+		},
+		Action: func(c *cli.Context) error {
+			cred, content, err := loadStorageCredential(appCtx, c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			stores, err := storesUsingCredential(appCtx, c.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			if !c.Bool("reveal") {
+				content = RedactCredential(content)
+			}
+			usedBy := make([]string, 0, len(stores))
+			for _, store := range stores {
+				name, _ := store["name"].(string)
+				usedBy = append(usedBy, name)
+			}
+			if appCtx.StructuredOutput() {
+				return appCtx.Renderer.RenderObject(map[string]interface{}{
+					"name":         c.Args().Get(0),
+					"reference_id": refID(cred),
+					"used_by":      usedBy,
+					"content":      content,
+				})
+			}
+			row := map[string]interface{}{
+				"name":         c.Args().Get(0),
+				"reference_id": refID(cred),
+				"used_by":      strings.Join(usedBy, ", "),
+			}
+			for key, value := range content {
+				row["content."+key] = value
+			}
+			return appCtx.Renderer.RenderObject(row)
+		},
+	}
+}
+
+// credentialInputFlags are the storage add credential flags, reused for
+// update and rotate.
+func credentialInputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "access-key", Usage: "Access key id"},
+		&cli.StringFlag{Name: "secret-key", Usage: "Secret access key (prefer --secret-key-env)"},
+		&cli.StringFlag{Name: "secret-key-env", Usage: "Read the secret access key from this environment variable"},
+	}
+}
+
+func storageCredentialUpdateCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "update",
+		Usage:     "Change fields of a credential, keeping the rest",
+		ArgsUsage: "<name>",
+		UsageText: `daptin storage credential update <name> [flags]
+   daptin storage credential update minio --endpoint http://minio:9000
+   daptin storage credential update minio --param region=eu-west-1 --unset session_token`,
+		Flags: append(credentialInputFlags(),
+			&cli.StringFlag{Name: "endpoint", Usage: "Storage endpoint"},
+			&cli.StringFlag{Name: "provider", Usage: "Rclone provider name"},
+			&cli.StringSliceFlag{Name: "param", Usage: "Rclone credential key=value (repeatable)"},
+			&cli.StringSliceFlag{Name: "unset", Usage: "Remove this key (repeatable)"},
+		),
+		Action: func(c *cli.Context) error {
+			name := c.Args().Get(0)
+			cred, content, err := loadStorageCredential(appCtx, name)
+			if err != nil {
+				return err
+			}
+			set, err := credentialFlagValues(c)
+			if err != nil {
+				return err
+			}
+			for _, param := range c.StringSlice("param") {
+				key, value, ok := strings.Cut(param, "=")
+				if !ok || key == "" {
+					return fmt.Errorf("invalid --param %q, expected key=value", param)
+				}
+				set[key] = value
+			}
+			if c.String("endpoint") != "" {
+				set["endpoint"] = c.String("endpoint")
+			}
+			if c.String("provider") != "" {
+				set["provider"] = c.String("provider")
+			}
+			if len(set) == 0 && len(c.StringSlice("unset")) == 0 {
+				return fmt.Errorf("nothing to update: pass --access-key, --secret-key, --endpoint, --provider, --param or --unset")
+			}
+			updated := MergeCredentialContent(content, set, c.StringSlice("unset"))
+			if err := saveStorageCredential(appCtx, refID(cred), updated); err != nil {
+				return err
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stdout, "Updated credential %s: %s\n", name, strings.Join(CredentialChanges(content, updated), ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func storageCredentialRotateCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "rotate",
+		Usage:     "Replace the access key pair, test it, and roll back if the test fails",
+		ArgsUsage: "<name>",
+		UsageText: `daptin storage credential rotate <name> --access-key <id> --secret-key-env <VAR>
+   daptin storage credential rotate minio --access-key NEWKEY --secret-key-env NEW_SECRET
+   daptin storage credential rotate minio --access-key NEWKEY --secret-key NEWSECRET --no-test`,
+		Description: "The new key pair is saved, then every store using the credential is tested as in `storage credential test`. " +
+			"If any test fails the previous content is restored.",
+		Flags: append(credentialInputFlags(),
+			&cli.BoolFlag{Name: "no-test", Usage: "Save the new keys without testing them"},
+		),
+		Action: func(c *cli.Context) error {
+			name := c.Args().Get(0)
+			cred, content, err := loadStorageCredential(appCtx, name)
+			if err != nil {
+				return err
+			}
+			set, err := credentialFlagValues(c)
+			if err != nil {
+				return err
+			}
+			if set["access_key_id"] == nil || set["secret_access_key"] == nil {
+				return fmt.Errorf("rotate needs --access-key and one of --secret-key or --secret-key-env")
+			}
+			var stores []map[string]interface{}
+			if !c.Bool("no-test") {
+				if stores, err = storesUsingCredential(appCtx, name); err != nil {
+					return err
+				}
+				if len(stores) == 0 {
+					return fmt.Errorf("no cloud_store uses credential %q, nothing to test the new keys against (use --no-test)", name)
+				}
+			}
+			if err := saveStorageCredential(appCtx, refID(cred), MergeCredentialContent(content, set, nil)); err != nil {
+				return err
+			}
+			if c.Bool("no-test") {
+				if !appCtx.Quiet {
+					fmt.Fprintf(os.Stdout, "Rotated credential %s (not tested)\n", name)
+				}
+				return nil
+			}
+			err = testStores(appCtx, stores)
+			if err != nil {
+				if restoreErr := saveStorageCredential(appCtx, refID(cred), content); restoreErr != nil {
+					return fmt.Errorf("%v; restoring the previous credential also failed: %v", err, restoreErr)
+				}
+				return fmt.Errorf("rotation rolled back: %w", err)
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stdout, "Rotated credential %s\n", name)
+			}
+			return nil
+		},
+	}
+}
+
+func storageCredentialTestCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "test",
+		Usage:     "Create a folder, upload a small file and delete both on each store using a credential",
+		ArgsUsage: "<name>",
+		UsageText: `daptin storage credential test <name> [--store <store>]
+   daptin storage credential test minio
+   daptin storage credential test minio --store minio-backups`,
+		Description: "Each step runs through the store's cloud_store actions, so a failure names the step " +
+			"(create_folder, upload_file or delete_path) that the credential could not perform. " +
+			"<name> may also be a cloud_store name, in which case that store is tested.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "store", Usage: "Only test this cloud_store"},
+		},
+		Action: func(c *cli.Context) error {
+			name := c.Args().Get(0)
+			if name == "" {
+				return fmt.Errorf("usage: storage credential test <name>")
+			}
+			stores, err := storesUsingCredential(appCtx, name)
+			if err != nil {
+				return err
+			}
+			if len(stores) == 0 {
+				if store, err := findOneByName(appCtx, "cloud_store", name); err == nil {
+					stores = client.MapArray([]daptinClient.JsonApiObject{store}, "attributes")
+				}
+			}
+			if storeName := c.String("store"); storeName != "" {
+				var selected []map[string]interface{}
+				for _, store := range stores {
+					if store["name"] == storeName || store["reference_id"] == storeName {
+						selected = append(selected, store)
+					}
+				}
+				if len(selected) == 0 {
+					return fmt.Errorf("cloud_store %q does not use credential %q", storeName, name)
+				}
+				stores = selected
+			}
+			if len(stores) == 0 {
+				return fmt.Errorf("no cloud_store uses credential %q", name)
+			}
+			return testStores(appCtx, stores)
+		},
+	}
+}
+
+// CredentialTestStep is one row of the credential test report.
+type CredentialTestStep struct {
+	Store    string
+	Step     string
+	Status   string
+	Duration time.Duration
+	Error    string
+}
+
+// Row renders the step for table or structured output.
+// Pure function.
+func (s CredentialTestStep) Row() map[string]interface{} {
+	return map[string]interface{}{
+		"store":    s.Store,
+		"step":     s.Step,
+		"status":   s.Status,
+		"duration": formatMillis(s.Duration),
+		"error":    s.Error,
+	}
+}
+
+// testStores runs the round trip on each store and renders one report.
+func testStores(appCtx *AppContext, stores []map[string]interface{}) error {
+	var steps []CredentialTestStep
+	var failures []string
+	for _, store := range stores {
+		storeName, _ := store["name"].(string)
+		storeSteps := credentialRoundTrip(appCtx, refID(store), storeName)
+		steps = append(steps, storeSteps...)
+		if failed := FirstFailedStep(storeSteps); failed != "" {
+			failures = append(failures, fmt.Sprintf("%s at %s", storeName, failed))
+		}
+	}
+	if !appCtx.Quiet {
+		rows := make([]map[string]interface{}, 0, len(steps))
+		for _, step := range steps {
+			rows = append(rows, step.Row())
+		}
+		if err := appCtx.Renderer.RenderArray(rows); err != nil {
+			return err
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("credential test failed: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// credentialRoundTrip creates a scratch folder, uploads a small file into it
+// and deletes the folder. Steps after a failure are skipped, except that a
+// created folder is always cleaned up.
+func credentialRoundTrip(appCtx *AppContext, storeRef, storeName string) []CredentialTestStep {
+	folder := fmt.Sprintf(".daptin-cli-test-%d", time.Now().UnixNano())
+	probe := "daptin-cli credential test " + time.Now().UTC().Format(time.RFC3339)
+	var steps []CredentialTestStep
+	run := func(step string, attrs map[string]interface{}) bool {
+		started := time.Now()
+		_, err := executeStoreAction(appCtx, storeRef, step, attrs)
+		result := CredentialTestStep{Store: storeName, Step: step, Status: "OK", Duration: time.Since(started)}
+		if err != nil {
+			result.Status, result.Error = "FAIL", err.Error()
+		}
+		slog.Debug("credential test step", "store", storeName, "step", step, "status", result.Status, "error", result.Error)
+		steps = append(steps, result)
+		return err == nil
+	}
+	skip := func(step string) {
+		steps = append(steps, CredentialTestStep{Store: storeName, Step: step, Status: "skipped"})
+	}
+
+	if !run("create_folder", map[string]interface{}{"path": "", "name": folder}) {
+		skip("upload_file")
+		skip("delete_path")
+		return steps
+	}
+	run("upload_file", map[string]interface{}{
+		"path": folder,
+		"file": []map[string]interface{}{{
+			"name": "probe.txt",
+			"type": "text/plain",
+			"file": "data:text/plain;base64," + base64.StdEncoding.EncodeToString([]byte(probe)),
+		}},
+	})
+	run("delete_path", map[string]interface{}{"path": "/" + folder})
+	return steps
+}
+
+// FirstFailedStep names the first failed step, or "" when all passed.
+// Pure function.
+func FirstFailedStep(steps []CredentialTestStep) string {
+	for _, step := range steps {
+		if step.Status == "FAIL" {
+			return step.Step
+		}
+	}
+	return ""
+}
+
+func loadStorageCredential(appCtx *AppContext, name string) (map[string]interface{}, map[string]interface{}, error) {
+	if name == "" {
+		return nil, nil, fmt.Errorf("credential name required")
+	}
+	cred, err := findOneByName(appCtx, "credential", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if refID(cred) == "" {
+		return nil, nil, fmt.Errorf("credential %q has no reference_id", name)
+	}
+	attrs, _ := cred["attributes"].(map[string]interface{})
+	content := map[string]interface{}{}
+	if raw, _ := attrs["content"].(string); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &content); err != nil {
+			return nil, nil, fmt.Errorf("credential %q content is not a JSON object: %w", name, err)
+		}
+	}
+	return cred, content, nil
+}
+
+func saveStorageCredential(appCtx *AppContext, ref string, content map[string]interface{}) error {
+	contentJSON, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	_, err = appCtx.Client.Update("credential", ref, jsonAPIObject("credential", map[string]interface{}{
+		"content": string(contentJSON),
+	}, ref))
+	return err
+}
+
+func storesUsingCredential(appCtx *AppContext, credentialName string) ([]map[string]interface{}, error) {
+	result, err := appCtx.Client.FindAll("cloud_store", daptinClient.DaptinQueryParameters{"page[size]": 500})
+	if err != nil {
+		return nil, err
+	}
+	var stores []map[string]interface{}
+	for _, store := range client.MapArray(result, "attributes") {
+		if store["credential_name"] == credentialName {
+			stores = append(stores, store)
+		}
+	}
+	return stores, nil
+}
+
+// credentialFlagValues maps --access-key and --secret-key(-env) to rclone keys.
+func credentialFlagValues(c *cli.Context) (map[string]interface{}, error) {
+	set := map[string]interface{}{}
+	if c.String("access-key") != "" {
+		set["access_key_id"] = c.String("access-key")
+	}
+	if c.String("secret-key") != "" && c.String("secret-key-env") != "" {
+		return nil, fmt.Errorf("provide only one of --secret-key or --secret-key-env")
+	}
+	if c.String("secret-key") != "" {
+		set["secret_access_key"] = c.String("secret-key")
+	}
+	if envName := c.String("secret-key-env"); envName != "" {
+		value := os.Getenv(envName)
+		if value == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", envName)
+		}
+		set["secret_access_key"] = value
+	}
+	return set, nil
+}
+
+// MergeCredentialContent returns a copy of content with set applied and
+// unset keys removed.
+// Pure function.
+func MergeCredentialContent(content, set map[string]interface{}, unset []string) map[string]interface{} {
+	merged := make(map[string]interface{}, len(content)+len(set))
+	for key, value := range content {
+		merged[key] = value
+	}
+	for key, value := range set {
+		merged[key] = value
+	}
+	for _, key := range unset {
+		delete(merged, key)
+	}
+	return merged
+}
+
+// CredentialChanges lists "key added|changed|removed" between two contents,
+// without values, sorted by key.
+// Pure function.
+func CredentialChanges(before, after map[string]interface{}) []string {
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	var changes []string
+	for _, key := range sorted {
+		old, hadOld := before[key]
+		value, hasNew := after[key]
+		switch {
+		case !hadOld:
+			changes = append(changes, key+" added")
+		case !hasNew:
+			changes = append(changes, key+" removed")
+		case fmt.Sprint(old) != fmt.Sprint(value):
+			changes = append(changes, key+" changed")
+		}
+	}
+	if len(changes) == 0 {
+		return []string{"no changes"}
+	}
+	return changes
+}
+
+// RedactCredential masks secret-looking values, keeping the last four
+// characters of long ones so keys can still be told apart. Identifiers
+// ending in _id (access_key_id, client_id) are left alone.
+// Pure function.
+func RedactCredential(content map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(content))
+	for key, value := range content {
+		redacted[key] = value
+		lower := strings.ToLower(key)
+		if strings.HasSuffix(lower, "_id") {
+			continue
+		}
+		for _, marker := range []string{"secret", "password", "pass", "token", "key", "private"} {
+			if strings.Contains(lower, marker) {
+				s := fmt.Sprint(value)
+				if len(s) > 12 {
+					redacted[key] = "****" + s[len(s)-4:]
+				} else {
+					redacted[key] = "****"
+				}
+				break
+			}
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactCredential(t *testing.T) {
+	content := map[string]interface{}{
+		"type":              "s3",
+		"access_key_id":     "AKIAEXAMPLE",
+		"secret_access_key": "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+		"session_token":     "short",
+		"endpoint":          "http://localhost:9000",
+	}
+	redacted := RedactCredential(content)
+	if redacted["secret_access_key"] != "****EKEY" {
+		t.Fatalf("secret not masked: %v", redacted["secret_access_key"])
+	}
+	if redacted["session_token"] != "****" {
+		t.Fatalf("short token not fully masked: %v", redacted["session_token"])
+	}
+	if redacted["access_key_id"] != "AKIAEXAMPLE" || redacted["endpoint"] != "http://localhost:9000" || redacted["type"] != "s3" {
+		t.Fatalf("non-secret values changed: %#v", redacted)
+	}
+	if content["secret_access_key"] == redacted["secret_access_key"] {
+		t.Fatal("input was mutated")
+	}
+}
+
+func TestMergeCredentialContent(t *testing.T) {
+	content := map[string]interface{}{"type": "s3", "region": "us-east-1", "session_token": "x"}
+	merged := MergeCredentialContent(content, map[string]interface{}{"region": "eu-west-1", "endpoint": "http://minio"}, []string{"session_token"})
+	if merged["region"] != "eu-west-1" || merged["endpoint"] != "http://minio" || merged["type"] != "s3" {
+		t.Fatalf("unexpected merge: %#v", merged)
+	}
+	if _, ok := merged["session_token"]; ok {
+		t.Fatal("unset key still present")
+	}
+	if content["region"] != "us-east-1" {
+		t.Fatal("input was mutated")
+	}
+}
+
+func TestCredentialChanges(t *testing.T) {
+	before := map[string]interface{}{"a": "1", "b": "2", "c": "3"}
+	after := map[string]interface{}{"a": "1", "b": "20", "d": "4"}
+	if got := strings.Join(CredentialChanges(before, after), ", "); got != "b changed, c removed, d added" {
+		t.Fatalf("unexpected changes: %s", got)
+	}
+	if got := CredentialChanges(before, before); len(got) != 1 || got[0] != "no changes" {
+		t.Fatalf("unexpected changes: %v", got)
+	}
+}
+
+func TestFirstFailedStep(t *testing.T) {
+	steps := []CredentialTestStep{
+		{Step: "create_folder", Status: "OK", Duration: time.Millisecond},
+		{Step: "upload_file", Status: "FAIL", Error: "AccessDenied"},
+		{Step: "delete_path", Status: "OK"},
+	}
+	if got := FirstFailedStep(steps); got != "upload_file" {
+		t.Fatalf("FirstFailedStep = %q", got)
+	}
+	if got := FirstFailedStep(steps[:1]); got != "" {
+		t.Fatalf("FirstFailedStep = %q, want empty", got)
+	}
+	if row := steps[1].Row(); row["status"] != "FAIL" || row["error"] != "AccessDenied" {
+		t.Fatalf("unexpected row: %#v", row)
+	}
+}
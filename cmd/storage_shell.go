@@ -0,0 +1,619 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daptin/daptin-cli/client"
+	daptinClient "github.com/daptin/daptin-go-client"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+func storageShellCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "shell",
+		Usage:     "Browse and change a cloud store in an interactive FTP-like shell",
+		ArgsUsage: "<store>[:/path]",
+		UsageText: `daptin storage shell <store>[:/path] [--site <site>]
+   daptin storage shell local-files
+   daptin storage shell local-files:/site/blog
+   printf 'cd photos\nls\nget a.jpg\n' | daptin storage shell local-files`,
+		Description: "Commands: ls, cd, pwd, get, put, rm, mkdir, mv, lcd, lpwd, help, exit. Paths are relative to the current " +
+			"folder unless they start with \"/\". Tab completes command names and remote paths (local paths for put and lcd). " +
+			"Listing and get go through the site serving the folder, as in storage ls; put, rm, mkdir and mv use the " +
+			"cloud_store actions. Without a terminal, commands are read one per line from stdin.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "site", Usage: "Site hostname, name or reference_id to list and download through"},
+		},
+		Action: func(c *cli.Context) error {
+			address := c.Args().Get(0)
+			if address == "" {
+				return fmt.Errorf("usage: storage shell <store>[:/path]")
+			}
+			if !strings.Contains(address, ":") {
+				address += ":/"
+			}
+			storeName, storePath, err := parseStorageAddress(address)
+			if err != nil {
+				return err
+			}
+			storeRef, err := cloudStoreRef(appCtx, storeName)
+			if err != nil {
+				return err
+			}
+			sh := &storageShell{
+				appCtx:   appCtx,
+				store:    storeName,
+				storeRef: storeRef,
+				siteFlag: c.String("site"),
+				listings: map[string][]RemoteFile{},
+			}
+			if err := sh.cd([]string{"/" + normalizeRemoteDir(storePath)}); err != nil {
+				return err
+			}
+			if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+				return sh.interactive()
+			}
+			return sh.script(os.Stdin)
+		},
+	}
+}
+
+// storageShell is the state of one storage shell session: the store, the
+// current folder, and cached sites and listings for completion.
+type storageShell struct {
+	appCtx   *AppContext
+	store    string
+	storeRef string
+	siteFlag string
+	cwd      string
+	sites    []map[string]interface{}
+	listings map[string][]RemoteFile
+}
+
+type shellCommand struct {
+	name  string
+	args  string
+	usage string
+	local bool
+	run   func(sh *storageShell, args []string) error
+}
+
+// storageShellCommands lists the shell's commands, for dispatch, help and
+// completion.
+func storageShellCommands() []shellCommand {
+	return []shellCommand{
+		{"ls", "[path]", "List a folder", false, (*storageShell).ls},
+		{"cd", "<path>", "Change the current folder", false, (*storageShell).cd},
+		{"pwd", "", "Show the current folder", false, (*storageShell).pwd},
+		{"get", "<path> [local-path]", "Download a file, or a folder recursively", false, (*storageShell).get},
+		{"put", "<local-path> [path]", "Upload a file, or a directory into a folder of the same name", true, (*storageShell).put},
+		{"rm", "<path>", "Delete a file or folder", false, (*storageShell).rm},
+		{"mkdir", "<path>", "Create a folder", false, (*storageShell).mkdir},
+		{"mv", "<path> <new-path>", "Move or rename within the store", false, (*storageShell).mv},
+		{"lcd", "<local-dir>", "Change the local directory", true, (*storageShell).lcd},
+		{"lpwd", "", "Show the local directory", true, (*storageShell).lpwd},
+		{"help", "", "List commands", false, (*storageShell).help},
+	}
+}
+
+// errShellExit ends the session.
+var errShellExit = fmt.Errorf("exit")
+
+// interactive reads lines from a raw-mode terminal with history and tab
+// completion. The terminal is restored while each command runs so its
+// output is written normally.
+func (sh *storageShell) interactive() error {
+	fd := int(os.Stdin.Fd())
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return sh.complete(line, pos)
+	}
+	fmt.Fprintf(os.Stdout, "Connected to %s. Type help for commands, exit to leave.\n", sh.store)
+	for {
+		t.SetPrompt(sh.prompt())
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := t.ReadLine()
+		term.Restore(fd, state)
+		if err == io.EOF {
+			fmt.Fprintln(os.Stdout)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if sh.exec(line) == errShellExit {
+			return nil
+		}
+	}
+}
+
+// script runs one command per input line, for piped use. The session
+// fails if any command failed.
+func (sh *storageShell) script(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	failed := 0
+	for scanner.Scan() {
+		err := sh.exec(scanner.Text())
+		if err == errShellExit {
+			break
+		}
+		if err != nil {
+			failed++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d commands failed", failed)
+	}
+	return nil
+}
+
+// exec runs one input line, printing any error.
+func (sh *storageShell) exec(line string) error {
+	args, err := SplitShellLine(line)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return err
+	}
+	if len(args) == 0 || strings.HasPrefix(args[0], "#") {
+		return nil
+	}
+	if args[0] == "exit" || args[0] == "quit" {
+		return errShellExit
+	}
+	for _, cmd := range storageShellCommands() {
+		if cmd.name == args[0] {
+			slog.Debug("storage shell", "command", args[0], "args", args[1:])
+			if err := cmd.run(sh, args[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return err
+			}
+			return nil
+		}
+	}
+	err = fmt.Errorf("unknown command %q, type help for commands", args[0])
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	return err
+}
+
+func (sh *storageShell) prompt() string {
+	return fmt.Sprintf("%s:/%s> ", sh.store, sh.cwd)
+}
+
+func (sh *storageShell) ls(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: ls [path]")
+	}
+	target := sh.cwd
+	if len(args) == 1 {
+		target = ResolveShellPath(sh.cwd, args[0])
+	}
+	entries, err := sh.list(target)
+	if err != nil {
+		return err
+	}
+	sorted := append([]RemoteFile(nil), entries...)
+	SortRemoteFiles(sorted)
+	if sh.appCtx.Quiet {
+		for _, entry := range sorted {
+			fmt.Fprintln(os.Stdout, entry.Name)
+		}
+		return nil
+	}
+	return sh.appCtx.Renderer.RenderArray(RemoteFileRows(sorted, !sh.appCtx.StructuredOutput()))
+}
+
+func (sh *storageShell) cd(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: cd <path>")
+	}
+	target := ResolveShellPath(sh.cwd, args[0])
+	isDir, err := sh.isDir(target)
+	if err != nil {
+		return fmt.Errorf("cd %s: %w", args[0], err)
+	}
+	if !isDir {
+		return fmt.Errorf("cd %s: not a folder", args[0])
+	}
+	sh.cwd = target
+	return nil
+}
+
+func (sh *storageShell) pwd(args []string) error {
+	fmt.Fprintf(os.Stdout, "%s:/%s\n", sh.store, sh.cwd)
+	return nil
+}
+
+func (sh *storageShell) get(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: get <path> [local-path]")
+	}
+	target := ResolveShellPath(sh.cwd, args[0])
+	isDir, err := sh.isDir(target)
+	if err != nil {
+		return err
+	}
+	siteRef, sitePath, err := sh.site(target)
+	if err != nil {
+		return err
+	}
+	localPath := ""
+	if len(args) == 2 {
+		localPath = args[1]
+	}
+	return downloadSitePath(sh.appCtx, siteRef, sitePath, localPath, isDir)
+}
+
+func (sh *storageShell) put(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: put <local-path> [path]")
+	}
+	localPath := args[0]
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	// A directory goes into a folder of its own name, like scp -r; a file
+	// keeps its name unless a new path is given
+	dest := "/" + sh.cwd + "/"
+	if info.IsDir() {
+		dest = "/" + path.Join(sh.cwd, filepath.Base(filepath.Clean(localPath))) + "/"
+	}
+	if len(args) == 2 {
+		dest = "/" + ResolveShellPath(sh.cwd, args[1])
+		if isDir, _ := sh.isDir(strings.TrimPrefix(dest, "/")); isDir || info.IsDir() || strings.HasSuffix(args[1], "/") {
+			dest += "/"
+		}
+	}
+	files, actionPath, err := planUploadFiles(localPath, dest, info.IsDir())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("nothing to upload in %q", localPath)
+	}
+	defer sh.invalidate()
+	return uploadFiles(sh.appCtx, sh.storeRef, actionPath, files, defaultBatchBytes, defaultBatchFiles).finish()
+}
+
+func (sh *storageShell) rm(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: rm <path>")
+	}
+	target := ResolveShellPath(sh.cwd, args[0])
+	if target == "" {
+		return fmt.Errorf("refusing to delete the root of %s", sh.store)
+	}
+	defer sh.invalidate()
+	return sh.action("delete_path", map[string]interface{}{"path": "/" + target})
+}
+
+func (sh *storageShell) mkdir(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: mkdir <path>")
+	}
+	parent, name := splitRemoteParentName("/" + ResolveShellPath(sh.cwd, args[0]))
+	if name == "" {
+		return fmt.Errorf("folder name required")
+	}
+	defer sh.invalidate()
+	return sh.action("create_folder", map[string]interface{}{"path": parent, "name": name})
+}
+
+func (sh *storageShell) mv(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: mv <path> <new-path>")
+	}
+	source := ResolveShellPath(sh.cwd, args[0])
+	if source == "" {
+		return fmt.Errorf("refusing to move the root of %s", sh.store)
+	}
+	destination := ResolveShellPath(sh.cwd, args[1])
+	if isDir, _ := sh.isDir(destination); isDir || strings.HasSuffix(args[1], "/") {
+		destination = path.Join(destination, path.Base(source))
+	}
+	defer sh.invalidate()
+	return sh.action("move_path", map[string]interface{}{"source": "/" + source, "destination": "/" + destination})
+}
+
+func (sh *storageShell) lcd(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: lcd <local-dir>")
+	}
+	return os.Chdir(args[0])
+}
+
+func (sh *storageShell) lpwd(args []string) error {
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, dir)
+	return nil
+}
+
+func (sh *storageShell) help(args []string) error {
+	for _, cmd := range storageShellCommands() {
+		fmt.Fprintf(os.Stdout, "  %-28s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.usage)
+	}
+	fmt.Fprintf(os.Stdout, "  %-28s %s\n", "exit", "Leave the shell")
+	return nil
+}
+
+// action runs a cloud_store action on the session's store.
+func (sh *storageShell) action(actionName string, attrs map[string]interface{}) error {
+	_, err := executeStoreAction(sh.appCtx, sh.storeRef, actionName, attrs)
+	return err
+}
+
+// site returns the site serving a store path and the path inside it. The
+// site list is fetched once per session.
+func (sh *storageShell) site(storePath string) (string, string, error) {
+	if sh.sites == nil {
+		result, err := sh.appCtx.Client.FindAll("site", daptinClient.DaptinQueryParameters{"page[size]": 500})
+		if err != nil {
+			return "", "", err
+		}
+		sh.sites = client.MapArray(result, "attributes")
+	}
+	site, sitePath, err := PickStoreSite(sh.sites, sh.storeRef, storePath, sh.siteFlag)
+	if err != nil {
+		return "", "", err
+	}
+	return refID(site), sitePath, nil
+}
+
+// list returns the entries of a store folder, cached until the next change.
+func (sh *storageShell) list(storePath string) ([]RemoteFile, error) {
+	if entries, ok := sh.listings[storePath]; ok {
+		return entries, nil
+	}
+	siteRef, sitePath, err := sh.site(storePath)
+	if err != nil {
+		// Above every site (often the store root) the folders leading to
+		// the sites are still worth showing
+		if folders := SiteFolders(sh.sites, sh.storeRef, storePath); len(folders) > 0 && sh.siteFlag == "" {
+			sh.listings[storePath] = folders
+			return folders, nil
+		}
+		return nil, err
+	}
+	entries, err := listSiteDir(sh.appCtx, siteRef, sitePath)
+	if err != nil {
+		return nil, err
+	}
+	sh.listings[storePath] = entries
+	return entries, nil
+}
+
+// isDir reports whether a store path is a folder, going by its parent's
+// listing. The store root and a site's own folder are always folders.
+func (sh *storageShell) isDir(storePath string) (bool, error) {
+	if storePath == "" {
+		return true, nil
+	}
+	if _, sitePath, err := sh.site(storePath); err == nil && sitePath == "" {
+		return true, nil
+	}
+	parent, name := path.Split(storePath)
+	entries, err := sh.list(strings.TrimSuffix(parent, "/"))
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Name == name {
+			return entry.IsDir, nil
+		}
+	}
+	return false, fmt.Errorf("%s: no such file or folder", "/"+storePath)
+}
+
+func (sh *storageShell) invalidate() {
+	sh.listings = map[string][]RemoteFile{}
+}
+
+// complete is the tab completion callback: command names for the first
+// word, local paths for put's first argument and lcd, remote paths otherwise.
+func (sh *storageShell) complete(line string, pos int) (string, int, bool) {
+	commands := storageShellCommands()
+	names := make([]string, 0, len(commands)+1)
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	names = append(names, "exit")
+	words := strings.Fields(line[:pos])
+	argIndex := len(words) - 1
+	if strings.HasSuffix(line[:pos], " ") {
+		argIndex++
+	}
+	local := false
+	for _, cmd := range commands {
+		if len(words) > 0 && cmd.name == words[0] && cmd.local && argIndex == 1 {
+			local = true
+		}
+	}
+	entries := func(dir string) []string {
+		if local {
+			return localEntries(dir)
+		}
+		files, err := sh.list(ResolveShellPath(sh.cwd, dir))
+		if err != nil {
+			return nil
+		}
+		out := make([]string, 0, len(files))
+		for _, file := range files {
+			if file.IsDir {
+				out = append(out, file.Name+"/")
+			} else {
+				out = append(out, file.Name)
+			}
+		}
+		return out
+	}
+	completed, ok := CompleteLine(line[:pos], names, entries)
+	if !ok {
+		return "", 0, false
+	}
+	return completed + line[pos:], len(completed), true
+}
+
+func localEntries(dir string) []string {
+	entries, err := os.ReadDir(firstNonEmpty(dir, "."))
+	if err != nil {
+		return nil
+	}
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			out = append(out, entry.Name()+"/")
+		} else {
+			out = append(out, entry.Name())
+		}
+	}
+	return out
+}
+
+// SiteFolders lists the folders directly below storePath that lead to a
+// site on the store, for folders no site covers.
+// Pure function.
+func SiteFolders(sites []map[string]interface{}, storeRef, storePath string) []RemoteFile {
+	seen := map[string]bool{}
+	var folders []RemoteFile
+	for _, site := range sites {
+		if site["cloud_store_id"] != storeRef {
+			continue
+		}
+		sitePath, _ := site["path"].(string)
+		rel, ok := SiteRelativePath(storePath, sitePath)
+		if !ok || rel == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(rel, "/")
+		if !seen[name] {
+			seen[name] = true
+			folders = append(folders, RemoteFile{Name: name, IsDir: true})
+		}
+	}
+	return folders
+}
+
+// ResolveShellPath resolves arg against the current folder into a store
+// path without leading "/". ".." stops at the store root.
+// Pure function.
+func ResolveShellPath(cwd, arg string) string {
+	if strings.HasPrefix(arg, "/") {
+		return normalizeRemoteDir(path.Clean(arg))
+	}
+	return normalizeRemoteDir(path.Clean("/" + path.Join(cwd, arg)))
+}
+
+// SplitShellLine splits a command line into words, honouring single and
+// double quotes and backslash escapes.
+// Pure function.
+func SplitShellLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("line ends with a backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// CompleteLine completes the last word of line: a command name when it is
+// the first word, otherwise a path whose candidates come from entries(dir)
+// for the word's directory part. It extends the word to the longest common
+// prefix of the matches, adding a space after a unique file or command.
+// Pure function (entries supplies the listings).
+func CompleteLine(line string, commands []string, entries func(dir string) []string) (string, bool) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	var candidates []string
+	prefix := word
+	dir := ""
+	if strings.TrimSpace(line[:start]) == "" {
+		candidates = commands
+	} else {
+		if i := strings.LastIndex(word, "/"); i >= 0 {
+			dir, prefix = word[:i+1], word[i+1:]
+		}
+		candidates = entries(dir)
+	}
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+	sort.Strings(matches)
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(matches) == 1 && !strings.HasSuffix(common, "/") {
+		common += " "
+	}
+	if dir+common == word {
+		return "", false
+	}
+	return line[:start] + dir + common, true
+}
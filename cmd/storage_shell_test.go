@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveShellPath(t *testing.T) {
+	cases := []struct{ cwd, arg, want string }{
+		{"", "photos", "photos"},
+		{"photos", "2024/a.jpg", "photos/2024/a.jpg"},
+		{"photos/2024", "..", "photos"},
+		{"photos", "../../..", ""},
+		{"photos", "/site/./blog/", "site/blog"},
+		{"photos", "/", ""},
+		{"photos", ".", "photos"},
+	}
+	for _, tc := range cases {
+		if got := ResolveShellPath(tc.cwd, tc.arg); got != tc.want {
+			t.Errorf("ResolveShellPath(%q, %q) = %q, want %q", tc.cwd, tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestSplitShellLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{"  ls   photos ", []string{"ls", "photos"}},
+		{`get "my file.jpg" 'local copy.jpg'`, []string{"get", "my file.jpg", "local copy.jpg"}},
+		{`rm my\ file.jpg`, []string{"rm", "my file.jpg"}},
+		{`put '' x`, []string{"put", "", "x"}},
+		{"", nil},
+	}
+	for _, tc := range cases {
+		got, err := SplitShellLine(tc.line)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SplitShellLine(%q) = %q, %v; want %q", tc.line, got, err, tc.want)
+		}
+	}
+	for _, line := range []string{`get "open`, `rm trailing\`} {
+		if _, err := SplitShellLine(line); err == nil {
+			t.Errorf("SplitShellLine(%q) should fail", line)
+		}
+	}
+}
+
+func TestCompleteLine(t *testing.T) {
+	commands := []string{"ls", "lcd", "lpwd", "get", "put", "exit"}
+	listings := map[string][]string{
+		"":        {"photos/", "photo.jpg", "index.html"},
+		"photos/": {"2024/", "a.jpg"},
+	}
+	entries := func(dir string) []string { return listings[dir] }
+	cases := []struct {
+		line, want string
+		ok         bool
+	}{
+		{"g", "get ", true},
+		{"l", "l", false},
+		{"lp", "lpwd ", true},
+		{"get ph", "get photo", true},
+		{"get photos", "get photos/", true},
+		{"get photos/a", "get photos/a.jpg ", true},
+		{"get photos/2", "get photos/2024/", true},
+		{"get in", "get index.html ", true},
+		{"get zz", "", false},
+		{"get ", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := CompleteLine(tc.line, commands, entries)
+		if ok != tc.ok || ok && got != tc.want {
+			t.Errorf("CompleteLine(%q) = %q, %v; want %q, %v", tc.line, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPickStoreSite(t *testing.T) {
+	sites := []map[string]interface{}{
+		{"hostname": "www.test", "path": "web", "cloud_store_id": "s1", "reference_id": "a"},
+		{"hostname": "blog.test", "path": "web/blog", "cloud_store_id": "s1", "reference_id": "b"},
+		{"hostname": "other.test", "path": "", "cloud_store_id": "s2", "reference_id": "c"},
+	}
+	site, rel, err := PickStoreSite(sites, "s1", "web/blog/post", "")
+	if err != nil || site["reference_id"] != "b" || rel != "post" {
+		t.Errorf("deepest site: %v %q %v", site["reference_id"], rel, err)
+	}
+	site, rel, err = PickStoreSite(sites, "s1", "web/blog/post", "www.test")
+	if err != nil || site["reference_id"] != "a" || rel != "blog/post" {
+		t.Errorf("--site: %v %q %v", site["reference_id"], rel, err)
+	}
+	if _, _, err := PickStoreSite(sites, "s1", "docs", ""); err == nil || !strings.Contains(err.Error(), "pass --site") {
+		t.Errorf("uncovered path err = %v", err)
+	}
+	if _, _, err := PickStoreSite(sites, "s1", "docs", "nope"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unknown site err = %v", err)
+	}
+}
+
+func TestSiteFolders(t *testing.T) {
+	sites := []map[string]interface{}{
+		{"path": "sites/www", "cloud_store_id": "s1"},
+		{"path": "sites/blog", "cloud_store_id": "s1"},
+		{"path": "docs", "cloud_store_id": "s1"},
+		{"path": "elsewhere", "cloud_store_id": "s2"},
+	}
+	names := func(files []RemoteFile) []string {
+		var out []string
+		for _, f := range files {
+			if !f.IsDir {
+				t.Errorf("%s should be a folder", f.Name)
+			}
+			out = append(out, f.Name)
+		}
+		return out
+	}
+	if got := names(SiteFolders(sites, "s1", "")); !reflect.DeepEqual(got, []string{"sites", "docs"}) {
+		t.Errorf("root = %v", got)
+	}
+	if got := names(SiteFolders(sites, "s1", "sites")); !reflect.DeepEqual(got, []string{"www", "blog"}) {
+		t.Errorf("sites = %v", got)
+	}
+	if got := SiteFolders(sites, "s1", "docs"); len(got) != 0 {
+		t.Errorf("a site's own folder has no site folders below it, got %v", got)
+	}
+}
@@ -0,0 +1,505 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	daptinClient "github.com/daptin/daptin-go-client"
+	"github.com/urfave/cli/v2"
+)
+
+// Daptin has no cloud_store listing or download action; both go through
+// the list_files and get_file actions of a site that serves the store path.
+
+func storageListPathCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "ls",
+		Usage:     "List files under a cloud_store path through the site that serves it",
+		ArgsUsage: "<store:/path>",
+		UsageText: `daptin storage ls <store:/path> [flags]
+   daptin storage ls local-files:/site
+   daptin storage ls local-files:/site/css --site www.example.com
+   daptin storage ls local-files:/site --recursive -o json`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "site", Usage: "Site hostname, name or reference_id to list through"},
+			&cli.BoolFlag{Name: "recursive", Usage: "List files in all subfolders"},
+		},
+		Action: func(c *cli.Context) error {
+			site, sitePath, err := resolveStoreSite(appCtx, c.Args().Get(0), c.String("site"))
+			if err != nil {
+				return err
+			}
+			return renderSiteFiles(appCtx, refID(site), sitePath, c.Bool("recursive"))
+		},
+	}
+}
+
+func storageDownloadCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "download",
+		Usage:     "Download a file or folder from a cloud_store path through the site that serves it",
+		ArgsUsage: "<store:/path> [local-path]",
+		UsageText: `daptin storage download <store:/path> [local-path] [flags]
+   daptin storage download local-files:/site/index.html
+   daptin storage download local-files:/site/index.html ./backup/index.html
+   daptin storage download local-files:/site ./site-backup --recursive`,
+		Description: "A file is written to local-path, into local-path when it is an existing directory, or to its own name in the current directory. " +
+			"With --recursive the remote folder is mirrored under local-path (default: the folder's name); the download continues past " +
+			"individual failures and exits non-zero listing them.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "site", Usage: "Site hostname, name or reference_id to download through"},
+			&cli.BoolFlag{Name: "recursive", Usage: "Download a folder and everything below it"},
+		},
+		Action: func(c *cli.Context) error {
+			site, sitePath, err := resolveStoreSite(appCtx, c.Args().Get(0), c.String("site"))
+			if err != nil {
+				return err
+			}
+			return downloadSitePath(appCtx, refID(site), sitePath, c.Args().Get(1), c.Bool("recursive"))
+		},
+	}
+}
+
+// renderSiteFiles lists one site directory, or every file below it with
+// recursive, as a table, JSON, or bare names with -q.
+func renderSiteFiles(appCtx *AppContext, siteRef, sitePath string, recursive bool) error {
+	var entries []RemoteFile
+	var err error
+	if recursive {
+		files, err := listRemoteFiles(appCtx, siteRef, sitePath)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			entries = append(entries, file)
+		}
+	} else if entries, err = listSiteDir(appCtx, siteRef, sitePath); err != nil {
+		return err
+	}
+	SortRemoteFiles(entries)
+	if appCtx.Quiet {
+		for _, entry := range entries {
+			fmt.Println(entry.Name)
+		}
+		return nil
+	}
+	return appCtx.Renderer.RenderArray(RemoteFileRows(entries, !appCtx.StructuredOutput()))
+}
+
+// downloadSitePath downloads one file, or with recursive a folder mirrored
+// under localPath, continuing past individual failures.
+func downloadSitePath(appCtx *AppContext, siteRef, sitePath, localPath string, recursive bool) error {
+	progress := func(format string, args ...interface{}) {
+		if !appCtx.Quiet {
+			fmt.Fprintf(os.Stderr, format, args...)
+		}
+	}
+
+	if !recursive {
+		if sitePath == "" {
+			return fmt.Errorf("download of a folder requires --recursive")
+		}
+		target := DownloadTarget(localPath, path.Base(sitePath), isDir(localPath))
+		size, err := downloadSiteFile(appCtx, siteRef, sitePath, target)
+		if err != nil {
+			return err
+		}
+		progress("%s -> %s (%s)\n", sitePath, target, FormatBytes(size))
+		return nil
+	}
+
+	root := localPath
+	if root == "" {
+		root = path.Base("/" + sitePath)
+		if root == "/" {
+			root = "."
+		}
+	}
+	files, err := listRemoteFiles(appCtx, siteRef, sitePath)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var downloaded int
+	var bytes int64
+	var failed []string
+	for i, name := range names {
+		target, err := SafeLocalPath(root, name)
+		if err == nil {
+			var size int64
+			size, err = downloadSiteFile(appCtx, siteRef, path.Join(sitePath, name), target)
+			bytes += size
+		}
+		if err != nil {
+			failed = append(failed, name)
+			progress("[%d/%d] FAIL %s: %v\n", i+1, len(names), name, err)
+			continue
+		}
+		downloaded++
+		progress("[%d/%d] %s\n", i+1, len(names), name)
+	}
+	if !appCtx.Quiet {
+		fmt.Fprintf(os.Stdout, "Downloaded %d files (%s) to %s, %d failed\n", downloaded, FormatBytes(bytes), root, len(failed))
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d files failed to download: %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// resolveStoreSite turns a store:/path address into the site serving it and
+// the path relative to that site.
+func resolveStoreSite(appCtx *AppContext, address, siteFlag string) (map[string]interface{}, string, error) {
+	storeName, storePath, err := parseStorageAddress(address)
+	if err != nil {
+		return nil, "", err
+	}
+	storeRef, err := cloudStoreRef(appCtx, storeName)
+	if err != nil {
+		return nil, "", err
+	}
+	return findStoreSite(appCtx, storeRef, normalizeRemoteDir(storePath), siteFlag)
+}
+
+// downloadSiteFile fetches one file with get_file and writes it to target,
+// creating parent directories.
+func downloadSiteFile(appCtx *AppContext, siteRef, sitePath, target string) (int64, error) {
+	responses, err := appCtx.Client.Execute("get_file", "site", daptinClient.JsonApiObject{
+		"site_id": siteRef,
+		"path":    sitePath,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := ActionFailure(responses); err != nil {
+		return 0, err
+	}
+	data, err := DecodeFileDownload(responses)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", sitePath, err)
+	}
+	if dir := filepath.Dir(target); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	slog.Debug("writing download", "path", sitePath, "target", target, "bytes", len(data))
+	return int64(len(data)), os.WriteFile(target, data, 0o644)
+}
+
+func isDir(localPath string) bool {
+	if localPath == "" {
+		return false
+	}
+	info, err := os.Stat(localPath)
+	return err == nil && info.IsDir()
+}
+
+// listSiteDir lists one site directory with list_files.
+func listSiteDir(appCtx *AppContext, siteRef, dir string) ([]RemoteFile, error) {
+	responses, err := appCtx.Client.Execute("list_files", "site", daptinClient.JsonApiObject{
+		"site_id": siteRef,
+		"path":    dir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ActionFailure(responses); err != nil {
+		return nil, err
+	}
+	entries := ParseRemoteFiles(responses)
+	for i := range entries {
+		entries[i].Name = path.Base(entries[i].Name)
+	}
+	return entries, nil
+}
+
+// DecodeFileDownload returns the file contents from a get_file response:
+// base64 in the "content" attribute, optionally as a data URI.
+// Pure function.
+func DecodeFileDownload(responses []daptinClient.DaptinActionResponse) ([]byte, error) {
+	for _, r := range responses {
+		if r.ResponseType != "client.file.download" {
+			continue
+		}
+		content, ok := firstValue(r.Attributes, "content", "file", "data").(string)
+		if !ok {
+			return nil, fmt.Errorf("file download response has no content")
+		}
+		if strings.HasPrefix(content, "data:") {
+			if _, encoded, found := strings.Cut(content, ","); found {
+				content = encoded
+			}
+		}
+		return base64.StdEncoding.DecodeString(content)
+	}
+	return nil, fmt.Errorf("no file in response")
+}
+
+// DownloadTarget picks where a single downloaded file goes: localPath
+// itself, inside it when it is a directory, or name in the current
+// directory when localPath is empty.
+// Pure function.
+func DownloadTarget(localPath, name string, localIsDir bool) string {
+	switch {
+	case localPath == "":
+		return name
+	case localIsDir || strings.HasSuffix(localPath, "/") || strings.HasSuffix(localPath, string(filepath.Separator)):
+		return filepath.Join(localPath, name)
+	}
+	return localPath
+}
+
+// SafeLocalPath joins a remote relative path onto root. Cleaning it as an
+// absolute path first clamps any ".." at root, so the result never escapes.
+// Pure function.
+func SafeLocalPath(root, rel string) (string, error) {
+	cleaned := path.Clean("/" + rel)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid remote path %q", rel)
+	}
+	return filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(cleaned, "/"))), nil
+}
+
+// SortRemoteFiles orders directories first, then by name.
+// Pure function (sorts in place).
+func SortRemoteFiles(files []RemoteFile) {
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].IsDir != files[j].IsDir {
+			return files[i].IsDir
+		}
+		return files[i].Name < files[j].Name
+	})
+}
+
+// RemoteFileRows renders listing entries. human formats size and time for
+// the table; otherwise size is bytes and modified is RFC 3339.
+// Pure function.
+func RemoteFileRows(files []RemoteFile, human bool) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(files))
+	for _, file := range files {
+		kind := "file"
+		if file.IsDir {
+			kind = "dir"
+		}
+		row := map[string]interface{}{"name": file.Name, "type": kind, "size": file.Size, "modified": ""}
+		if !file.ModTime.IsZero() {
+			row["modified"] = file.ModTime.UTC().Format(time.RFC3339)
+		}
+		if human {
+			row["size"] = ""
+			if !file.IsDir {
+				row["size"] = FormatBytes(file.Size)
+			}
+			if !file.ModTime.IsZero() {
+				row["modified"] = file.ModTime.Local().Format("2006-01-02 15:04")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// RemoteFile is one entry from a site list_files response.
+type RemoteFile struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+	Hash    string
+}
+
+// findStoreSite picks the site to reach a store path through; see PickStoreSite.
+func findStoreSite(appCtx *AppContext, storeRef, storePath, siteFlag string) (map[string]interface{}, string, error) {
+	result, err := appCtx.Client.FindAll("site", daptinClient.DaptinQueryParameters{"page[size]": 500})
+	if err != nil {
+		return nil, "", err
+	}
+	return PickStoreSite(client.MapArray(result, "attributes"), storeRef, storePath, siteFlag)
+}
+
+// PickStoreSite picks the site to reach a store path through: the siteFlag
+// site, otherwise the site on this store whose path is the longest prefix
+// of the target path. It also returns the target path relative to the site.
+// Pure function.
+func PickStoreSite(sites []map[string]interface{}, storeRef, storePath, siteFlag string) (map[string]interface{}, string, error) {
+	var best map[string]interface{}
+	bestListPath := ""
+	for _, site := range sites {
+		if siteFlag != "" {
+			if !SiteMatches(site, siteFlag) {
+				continue
+			}
+		} else if site["cloud_store_id"] != storeRef {
+			continue
+		}
+		sitePath, _ := site["path"].(string)
+		listPath, ok := SiteRelativePath(sitePath, storePath)
+		if !ok {
+			if siteFlag != "" {
+				return nil, "", fmt.Errorf("site %q serves %q, which does not contain %q", siteFlag, sitePath, storePath)
+			}
+			continue
+		}
+		if best == nil || len(listPath) < len(bestListPath) {
+			best, bestListPath = site, listPath
+		}
+	}
+	if best == nil {
+		if siteFlag != "" {
+			return nil, "", fmt.Errorf("site %q not found", siteFlag)
+		}
+		return nil, "", fmt.Errorf("no site on this cloud store serves %q; files are listed and downloaded through a site, pass --site", "/"+storePath)
+	}
+	return best, bestListPath, nil
+}
+
+// listRemoteFiles walks a site directory tree with list_files, returning
+// files keyed by their path relative to root.
+func listRemoteFiles(appCtx *AppContext, siteRef, root string) (map[string]RemoteFile, error) {
+	files := map[string]RemoteFile{}
+	pending := []string{""}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		entries, err := listSiteDir(appCtx, siteRef, path.Join(root, dir))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			rel := path.Join(dir, entry.Name)
+			if entry.IsDir {
+				pending = append(pending, rel)
+				continue
+			}
+			entry.Name = rel
+			files[rel] = entry
+		}
+	}
+	slog.Debug("remote files listed", "count", len(files))
+	return files, nil
+}
+
+// SiteMatches reports whether key is the site's hostname, name or
+// reference_id.
+// Pure function.
+func SiteMatches(site map[string]interface{}, key string) bool {
+	return key != "" && (site["hostname"] == key || site["name"] == key || site["reference_id"] == key)
+}
+
+// SiteRelativePath returns storePath relative to a site's path within the
+// store, and false when the site does not cover storePath.
+// Pure function.
+func SiteRelativePath(sitePath, storePath string) (string, bool) {
+	sitePath = strings.Trim(sitePath, "/")
+	storePath = strings.Trim(storePath, "/")
+	switch {
+	case sitePath == "":
+		return storePath, true
+	case storePath == sitePath:
+		return "", true
+	case strings.HasPrefix(storePath, sitePath+"/"):
+		return strings.TrimPrefix(storePath, sitePath+"/"), true
+	}
+	return "", false
+}
+
+// ParseRemoteFiles extracts file entries from list_files responses. The
+// list may be the attributes themselves or the first array of objects
+// inside them; field names vary between storage backends.
+// Pure function.
+func ParseRemoteFiles(responses []daptinClient.DaptinActionResponse) []RemoteFile {
+	var files []RemoteFile
+	for _, r := range responses {
+		for _, entry := range remoteFileEntries(r.Attributes) {
+			name, _ := firstValue(entry, "name", "Name", "path", "Path").(string)
+			if name == "" {
+				continue
+			}
+			file := RemoteFile{Name: name}
+			switch v := firstValue(entry, "is_dir", "IsDir", "isDir", "dir").(type) {
+			case bool:
+				file.IsDir = v
+			case string:
+				file.IsDir = v == "true"
+			}
+			switch size := firstValue(entry, "size", "Size").(type) {
+			case float64:
+				file.Size = int64(size)
+			case string:
+				file.Size, _ = strconv.ParseInt(size, 10, 64)
+			}
+			file.ModTime = parseRemoteTime(firstValue(entry, "mod_time", "ModTime", "modified", "updated_at", "last_modified"))
+			if hash, ok := firstValue(entry, "md5", "hash", "etag", "ETag").(string); ok {
+				file.Hash = strings.Trim(hash, `"`)
+			}
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func remoteFileEntries(attrs map[string]interface{}) []map[string]interface{} {
+	if _, ok := firstValue(attrs, "name", "Name").(string); ok {
+		return []map[string]interface{}{attrs}
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		list, ok := attrs[key].([]interface{})
+		if !ok {
+			continue
+		}
+		var entries []map[string]interface{}
+		for _, item := range list {
+			if entry, ok := item.(map[string]interface{}); ok {
+				entries = append(entries, entry)
+			}
+		}
+		if len(entries) > 0 {
+			return entries
+		}
+	}
+	return nil
+}
+
+func firstValue(m map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if v, ok := m[key]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func parseRemoteTime(v interface{}) time.Time {
+	switch t := v.(type) {
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return parsed
+		}
+		if seconds, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return time.Unix(seconds, 0)
+		}
+	case float64:
+		return time.Unix(int64(t), 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	daptinClient "github.com/daptin/daptin-go-client"
+)
+
+func TestSiteRelativePath(t *testing.T) {
+	cases := []struct {
+		site, store, want string
+		ok                bool
+	}{
+		{"", "site/blog", "site/blog", true},
+		{"/site", "site", "", true},
+		{"site", "/site/blog/", "blog", true},
+		{"site", "sitemap", "", false},
+		{"other", "site", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := SiteRelativePath(tc.site, tc.store)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("SiteRelativePath(%q, %q) = %q, %v; want %q, %v", tc.site, tc.store, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseRemoteFiles(t *testing.T) {
+	responses := []daptinClient.DaptinActionResponse{
+		{ResponseType: "client.notify", Attributes: map[string]interface{}{"type": "success", "message": "ok"}},
+		{ResponseType: "list", Attributes: map[string]interface{}{
+			"files": []interface{}{
+				map[string]interface{}{"name": "index.html", "is_dir": false, "size": float64(120), "mod_time": "2026-01-02T03:04:05Z", "md5": "abc"},
+				map[string]interface{}{"name": "css", "is_dir": true},
+			},
+		}},
+	}
+	files := ParseRemoteFiles(responses)
+	if len(files) != 2 {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+	if files[0].Name != "index.html" || files[0].Size != 120 || files[0].Hash != "abc" || files[0].ModTime.Year() != 2026 {
+		t.Fatalf("unexpected file: %#v", files[0])
+	}
+	if !files[1].IsDir {
+		t.Fatalf("css should be a directory: %#v", files[1])
+	}
+}
+
+func TestDecodeFileDownload(t *testing.T) {
+	responses := []daptinClient.DaptinActionResponse{{ResponseType: "client.file.download", Attributes: map[string]interface{}{"name": "a.txt", "content": "aGVsbG8="}}}
+	data, err := DecodeFileDownload(responses)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("DecodeFileDownload = %q, %v", data, err)
+	}
+	responses[0].Attributes["content"] = "data:text/plain;base64,aGk="
+	if data, err := DecodeFileDownload(responses); err != nil || string(data) != "hi" {
+		t.Fatalf("data uri: %q, %v", data, err)
+	}
+	if _, err := DecodeFileDownload(nil); err == nil {
+		t.Fatal("expected error without a download response")
+	}
+}
+
+func TestDownloadTarget(t *testing.T) {
+	if got := DownloadTarget("", "a.txt", false); got != "a.txt" {
+		t.Fatalf("empty local path: %q", got)
+	}
+	if got := DownloadTarget("out", "a.txt", true); got != filepath.Join("out", "a.txt") {
+		t.Fatalf("directory: %q", got)
+	}
+	if got := DownloadTarget("renamed.txt", "a.txt", false); got != "renamed.txt" {
+		t.Fatalf("rename: %q", got)
+	}
+}
+
+func TestSafeLocalPath(t *testing.T) {
+	got, err := SafeLocalPath("out", "css/site.css")
+	if err != nil || got != filepath.Join("out", "css", "site.css") {
+		t.Fatalf("SafeLocalPath = %q, %v", got, err)
+	}
+	// Leading ".." is clamped to the root rather than escaping it
+	if got, err := SafeLocalPath("out", "../../etc/passwd"); err != nil || got != filepath.Join("out", "etc", "passwd") {
+		t.Fatalf("SafeLocalPath clamp = %q, %v", got, err)
+	}
+	if _, err := SafeLocalPath("out", ".."); err == nil {
+		t.Fatal("expected error for empty path")
+	}
+}
+
+func TestRemoteFileRows(t *testing.T) {
+	files := []RemoteFile{
+		{Name: "b.html", Size: 2048, ModTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{Name: "css", IsDir: true},
+		{Name: "a.html", Size: 1},
+	}
+	SortRemoteFiles(files)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "css,a.html,b.html" {
+		t.Fatalf("unexpected order: %v", names)
+	}
+	rows := RemoteFileRows(files, false)
+	if rows[0]["type"] != "dir" || rows[2]["size"] != int64(2048) || rows[2]["modified"] != "2026-01-02T03:04:05Z" {
+		t.Fatalf("unexpected rows: %#v", rows)
+	}
+	human := RemoteFileRows(files, true)
+	if human[0]["size"] != "" || human[2]["size"] != "2.0 KB" {
+		t.Fatalf("unexpected human rows: %#v", human)
+	}
+}
+
+func TestCopiedSourcePaths(t *testing.T) {
+	got := CopiedSourcePaths("/site", []string{"a.html", "css/site.css", "b.html"}, []string{"b.html"})
+	if strings.Join(got, ",") != "/site/a.html,/site/css/site.css" {
+		t.Fatalf("unexpected paths: %v", got)
+	}
+}
@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// ignoreFileName is read from the root of the local directory being synced.
+const ignoreFileName = ".daptinignore"
+
+func storageSyncCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "sync",
+		Usage:     "Upload new and changed files from a local directory, optionally deleting remote extras",
+		ArgsUsage: "<local-dir> <store:/path>",
+		UsageText: `daptin storage sync <local-dir> <store:/path> [flags]
+   daptin storage sync ./public local-files:/site --dry-run
+   daptin storage sync ./public local-files:/site --delete
+   daptin storage sync ./public local-files:/site --exclude '*.map' --exclude drafts/
+   daptin storage sync ./public local-files:/site --include '*.html' --include 'css/*'
+   daptin storage sync ./public local-files:/site --site www.example.com`,
+		Description: "Remote state comes from the list_files action of the site that serves the store path (pick one with --site). " +
+			"A file is uploaded when it is missing remotely, its size differs, its md5 differs (when the listing has a hash), " +
+			"or it was modified locally after the remote copy. Patterns follow .gitignore: a pattern without \"/\" matches any path " +
+			"component, a trailing \"/\" matches a directory, and \"!\" in " + ignoreFileName + " re-includes. " +
+			"Remote files that are excluded are never deleted.",
+		Flags: append([]cli.Flag{
+			&cli.StringSliceFlag{Name: "include", Usage: "Only sync paths matching this glob (repeatable)"},
+			&cli.StringSliceFlag{Name: "exclude", Usage: "Skip paths matching this glob (repeatable, applied after " + ignoreFileName + ")"},
+			&cli.BoolFlag{Name: "delete", Usage: "Delete remote files that do not exist locally"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Print the plan without uploading or deleting"},
+			&cli.StringFlag{Name: "site", Usage: "Site hostname, name or reference_id to list remote files through"},
+		}, uploadBatchFlags()...),
+		Action: func(c *cli.Context) error {
+			localDir, address := c.Args().Get(0), c.Args().Get(1)
+			if localDir == "" || address == "" {
+				return fmt.Errorf("usage: storage sync <local-dir> <store:/path>")
+			}
+			info, err := os.Stat(localDir)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("storage sync expects a directory, got %q; use storage upload for single files", localDir)
+			}
+			storeName, destPath, err := parseStorageAddress(address)
+			if err != nil {
+				return err
+			}
+			maxBytes, maxFiles, err := uploadBatchLimits(c)
+			if err != nil {
+				return err
+			}
+
+			rules, err := readIgnoreFile(filepath.Join(localDir, ignoreFileName))
+			if err != nil {
+				return err
+			}
+			for _, pattern := range c.StringSlice("exclude") {
+				rules = append(rules, IgnoreRule{Pattern: pattern})
+			}
+			includes := c.StringSlice("include")
+
+			files, actionPath, err := planUploadFiles(localDir, destPath+"/", true)
+			if err != nil {
+				return err
+			}
+			local := make([]UploadFile, 0, len(files))
+			for _, file := range files {
+				if file.Name != ignoreFileName && SyncSelected(file.Name, includes, rules) {
+					local = append(local, file)
+				}
+			}
+
+			storeRef, err := cloudStoreRef(appCtx, storeName)
+			if err != nil {
+				return err
+			}
+			site, listPath, err := findStoreSite(appCtx, storeRef, actionPath, c.String("site"))
+			if err != nil {
+				return err
+			}
+			slog.Info("storage sync", "store", storeName, "path", actionPath, "site", refID(site), "list_path", listPath, "local_files", len(local))
+			remote, err := listRemoteFiles(appCtx, refID(site), listPath)
+			if err != nil {
+				return fmt.Errorf("list remote files: %w", err)
+			}
+			if err := hashForComparison(local, remote); err != nil {
+				return err
+			}
+
+			steps := PlanSync(local, remote, c.Bool("delete"), includes, rules)
+			if c.Bool("dry-run") {
+				return renderSyncPlan(appCtx, steps, len(local))
+			}
+			return runSync(appCtx, storeRef, actionPath, steps, len(local), maxBytes, maxFiles)
+		},
+	}
+}
+
+// SyncStep is one planned change: "upload" with a reason, or "delete".
+type SyncStep struct {
+	Action string
+	Path   string
+	Reason string
+	Size   int64
+	File   UploadFile
+}
+
+// IgnoreRule is one .daptinignore line or --exclude pattern.
+type IgnoreRule struct {
+	Pattern string
+	Negate  bool
+}
+
+func runSync(appCtx *AppContext, storeRef, actionPath string, steps []SyncStep, localCount int, maxBytes int64, batchFiles int) error {
+	var uploads []UploadFile
+	var deletes []string
+	for _, step := range steps {
+		if step.Action == "upload" {
+			uploads = append(uploads, step.File)
+		} else {
+			deletes = append(deletes, step.Path)
+		}
+	}
+	up := uploadFiles(appCtx, storeRef, actionPath, uploads, maxBytes, batchFiles)
+
+	deleted := 0
+	for _, name := range deletes {
+		remotePath := path.Join("/", actionPath, name)
+		if _, err := executeStoreAction(appCtx, storeRef, "delete_path", map[string]interface{}{"path": remotePath}); err != nil {
+			up.failed = append(up.failed, map[string]interface{}{"name": name, "error": err.Error()})
+			up.progress("delete FAIL %s: %v\n", name, err)
+			continue
+		}
+		deleted++
+		up.progress("deleted %s\n", name)
+	}
+
+	unchanged := localCount - len(uploads)
+	switch {
+	case appCtx.Quiet:
+	case appCtx.StructuredOutput():
+		failed := up.failed
+		if failed == nil {
+			failed = []map[string]interface{}{}
+		}
+		if err := appCtx.Renderer.RenderObject(map[string]interface{}{
+			"uploaded":  up.uploaded,
+			"deleted":   deleted,
+			"unchanged": unchanged,
+			"bytes":     up.bytes,
+			"failed":    failed,
+		}); err != nil {
+			return err
+		}
+	default:
+		fmt.Fprintf(os.Stdout, "Uploaded %d files (%s), deleted %d, %d unchanged, %d failed\n", up.uploaded, FormatBytes(up.bytes), deleted, unchanged, len(up.failed))
+	}
+	if len(up.failed) > 0 {
+		return fmt.Errorf("%d of %d changes failed", len(up.failed), len(steps))
+	}
+	return nil
+}
+
+func renderSyncPlan(appCtx *AppContext, steps []SyncStep, localCount int) error {
+	if appCtx.Quiet {
+		for _, step := range steps {
+			fmt.Printf("%s %s\n", step.Action, step.Path)
+		}
+		return nil
+	}
+	rows := make([]map[string]interface{}, 0, len(steps))
+	var uploads int
+	var bytes int64
+	for _, step := range steps {
+		row := map[string]interface{}{"action": step.Action, "path": step.Path, "reason": step.Reason, "size": step.Size}
+		if !appCtx.StructuredOutput() {
+			row["size"] = FormatBytes(step.Size)
+		}
+		if step.Action == "upload" {
+			uploads++
+			bytes += step.Size
+		}
+		rows = append(rows, row)
+	}
+	if appCtx.StructuredOutput() {
+		return appCtx.Renderer.RenderArray(rows)
+	}
+	if len(rows) > 0 {
+		if err := appCtx.Renderer.RenderArray(rows); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stdout, "Would upload %d files (%s), delete %d, %d unchanged\n", uploads, FormatBytes(bytes), len(steps)-uploads, localCount-uploads)
+	return nil
+}
+
+// hashForComparison fills in local md5 sums where the remote listing has a
+// hash and the sizes match, which is the only case where a hash decides.
+func hashForComparison(local []UploadFile, remote map[string]RemoteFile) error {
+	for i, file := range local {
+		r, ok := remote[file.Name]
+		if !ok || r.Hash == "" || r.Size != file.Size {
+			continue
+		}
+		sum, err := md5File(file.LocalPath)
+		if err != nil {
+			return err
+		}
+		local[i].Hash = sum
+	}
+	return nil
+}
+
+func md5File(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readIgnoreFile(name string) ([]IgnoreRule, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ParseIgnoreRules(lines), nil
+}
+
+// ParseIgnoreRules parses .daptinignore lines, skipping blanks and # comments.
+// Pure function.
+func ParseIgnoreRules(lines []string) []IgnoreRule {
+	var rules []IgnoreRule
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "!") {
+			rules = append(rules, IgnoreRule{Pattern: line[1:], Negate: true})
+			continue
+		}
+		rules = append(rules, IgnoreRule{Pattern: line})
+	}
+	return rules
+}
+
+// MatchGlob matches a slash-separated relative path against a .gitignore
+// style pattern. A pattern without "/" matches any component, otherwise it
+// is anchored at the root; either way a match on a directory covers
+// everything below it. A leading "**/" is the same as no slash.
+// Pure function.
+func MatchGlob(pattern, rel string) bool {
+	pattern = strings.TrimPrefix(pattern, "**/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	if !strings.Contains(pattern, "/") {
+		for _, part := range parts {
+			if ok, _ := path.Match(pattern, part); ok {
+				return true
+			}
+		}
+		return false
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 1; i <= len(parts); i++ {
+		if ok, _ := path.Match(pattern, strings.Join(parts[:i], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncSelected reports whether a path takes part in the sync: it matches
+// an include (when any are given) and is not ignored. The last matching
+// rule wins, so "!" rules re-include.
+// Pure function.
+func SyncSelected(rel string, includes []string, rules []IgnoreRule) bool {
+	if len(includes) > 0 {
+		included := false
+		for _, pattern := range includes {
+			if MatchGlob(pattern, rel) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	ignored := false
+	for _, rule := range rules {
+		if MatchGlob(rule.Pattern, rel) {
+			ignored = !rule.Negate
+		}
+	}
+	return !ignored
+}
+
+// SyncReason says why a local file needs uploading, or "" when the remote
+// copy is current. A matching hash wins over a newer local mtime.
+// Pure function.
+func SyncReason(local UploadFile, remote RemoteFile, exists bool) string {
+	switch {
+	case !exists:
+		return "new"
+	case local.Size != remote.Size:
+		return "size"
+	case local.Hash != "" && remote.Hash != "":
+		if !strings.EqualFold(local.Hash, remote.Hash) {
+			return "hash"
+		}
+		return ""
+	case !remote.ModTime.IsZero() && local.ModTime.After(remote.ModTime):
+		return "modified"
+	}
+	return ""
+}
+
+// PlanSync lists uploads in local order, then, with deleteExtra, deletes of
+// selected remote files that are missing locally, sorted by path.
+// Pure function.
+func PlanSync(local []UploadFile, remote map[string]RemoteFile, deleteExtra bool, includes []string, rules []IgnoreRule) []SyncStep {
+	var steps []SyncStep
+	seen := make(map[string]bool, len(local))
+	for _, file := range local {
+		seen[file.Name] = true
+		r, exists := remote[file.Name]
+		if reason := SyncReason(file, r, exists); reason != "" {
+			steps = append(steps, SyncStep{Action: "upload", Path: file.Name, Reason: reason, Size: file.Size, File: file})
+		}
+	}
+	if !deleteExtra {
+		return steps
+	}
+	var extras []string
+	for name := range remote {
+		if !seen[name] && name != ignoreFileName && SyncSelected(name, includes, rules) {
+			extras = append(extras, name)
+		}
+	}
+	sort.Strings(extras)
+	for _, name := range extras {
+		steps = append(steps, SyncStep{Action: "delete", Path: name, Reason: "not local", Size: remote[name].Size})
+	}
+	return steps
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.map", "js/app.js.map", true},
+		{"*.map", "js/app.js", false},
+		{"drafts/", "drafts/post.html", true},
+		{"drafts", "blog/drafts/post.html", true},
+		{"css/*", "css/site.css", true},
+		{"css/*", "theme/css/site.css", false},
+		{"/index.html", "index.html", true},
+		{"/index.html", "blog/index.html", false},
+		{"**/*.tmp", "a/b/c.tmp", true},
+		{"blog/drafts", "blog/drafts/x.html", true},
+	}
+	for _, tc := range cases {
+		if got := MatchGlob(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestParseIgnoreRules(t *testing.T) {
+	rules := ParseIgnoreRules([]string{"# comment", "", "  *.log  ", "!keep.log"})
+	if len(rules) != 2 || rules[0].Pattern != "*.log" || rules[0].Negate || rules[1].Pattern != "keep.log" || !rules[1].Negate {
+		t.Fatalf("unexpected rules: %#v", rules)
+	}
+}
+
+func TestSyncSelected(t *testing.T) {
+	rules := ParseIgnoreRules([]string{"*.log", "!keep.log"})
+	if SyncSelected("debug.log", nil, rules) {
+		t.Fatal("debug.log should be ignored")
+	}
+	if !SyncSelected("keep.log", nil, rules) {
+		t.Fatal("keep.log should be re-included")
+	}
+	if SyncSelected("img/a.png", []string{"*.html"}, nil) {
+		t.Fatal("include should restrict to html")
+	}
+	if !SyncSelected("blog/index.html", []string{"*.html"}, nil) {
+		t.Fatal("html should be included")
+	}
+}
+
+func TestSyncReason(t *testing.T) {
+	now := time.Now()
+	local := UploadFile{Name: "a", Size: 10, ModTime: now}
+	cases := []struct {
+		name   string
+		local  UploadFile
+		remote RemoteFile
+		exists bool
+		want   string
+	}{
+		{"missing", local, RemoteFile{}, false, "new"},
+		{"size", local, RemoteFile{Size: 11}, true, "size"},
+		{"newer locally", local, RemoteFile{Size: 10, ModTime: now.Add(-time.Hour)}, true, "modified"},
+		{"older locally", local, RemoteFile{Size: 10, ModTime: now.Add(time.Hour)}, true, ""},
+		{"no remote mtime", local, RemoteFile{Size: 10}, true, ""},
+		{"hash differs", UploadFile{Size: 10, Hash: "aa"}, RemoteFile{Size: 10, Hash: "bb"}, true, "hash"},
+		{"hash wins over mtime", UploadFile{Size: 10, Hash: "AA", ModTime: now}, RemoteFile{Size: 10, Hash: "aa", ModTime: now.Add(-time.Hour)}, true, ""},
+	}
+	for _, tc := range cases {
+		if got := SyncReason(tc.local, tc.remote, tc.exists); got != tc.want {
+			t.Errorf("%s: SyncReason = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	old := time.Now().Add(-time.Hour)
+	local := []UploadFile{
+		{Name: "index.html", Size: 5, ModTime: old},
+		{Name: "css/site.css", Size: 7},
+		{Name: "new.html", Size: 3},
+	}
+	remote := map[string]RemoteFile{
+		"index.html":   {Name: "index.html", Size: 5, ModTime: time.Now()},
+		"css/site.css": {Name: "css/site.css", Size: 6},
+		"stale.html":   {Name: "stale.html", Size: 1},
+		"debug.log":    {Name: "debug.log", Size: 1},
+	}
+	rules := []IgnoreRule{{Pattern: "*.log"}}
+
+	steps := PlanSync(local, remote, false, nil, rules)
+	if got := syncStepsString(steps); got != "upload css/site.css size,upload new.html new" {
+		t.Fatalf("unexpected plan: %s", got)
+	}
+	steps = PlanSync(local, remote, true, nil, rules)
+	if got := syncStepsString(steps); got != "upload css/site.css size,upload new.html new,delete stale.html not local" {
+		t.Fatalf("unexpected plan with --delete: %s", got)
+	}
+}
+
+func syncStepsString(steps []SyncStep) string {
+	parts := make([]string, 0, len(steps))
+	for _, step := range steps {
+		parts = append(parts, step.Action+" "+step.Path+" "+step.Reason)
+	}
+	return strings.Join(parts, ",")
+}
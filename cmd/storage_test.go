@@ -1,9 +1,16 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	daptinClient "github.com/daptin/daptin-go-client"
 )
 
 func TestParseStorageAddress(t *testing.T) {
@@ -33,31 +40,167 @@ func TestSplitRemoteParentName(t *testing.T) {
 	}
 }
 
-func TestBuildUploadFiles_RenameSingleFile(t *testing.T) {
+func TestPlanUploadFiles_RenameSingleFile(t *testing.T) {
 	dir := t.TempDir()
 	localPath := filepath.Join(dir, "input.txt")
 	if err := os.WriteFile(localPath, []byte("hello"), 0600); err != nil {
 		t.Fatal(err)
 	}
 
-	files, actionPath, err := buildUploadFiles(localPath, "/docs/output.txt", false)
+	files, actionPath, err := planUploadFiles(localPath, "/docs/output.txt", false)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if actionPath != "docs" {
 		t.Fatalf("expected action path docs, got %q", actionPath)
 	}
-	if len(files) != 1 || files[0]["name"] != "output.txt" {
+	if len(files) != 1 || files[0].Name != "output.txt" || files[0].Size != 5 {
 		t.Fatalf("unexpected files: %#v", files)
 	}
-	if files[0]["type"] != "text/plain; charset=utf-8" {
-		t.Fatalf("unexpected content type: %#v", files[0]["type"])
+	if files[0].ContentType != "text/plain; charset=utf-8" {
+		t.Fatalf("unexpected content type: %#v", files[0].ContentType)
 	}
 }
 
-func TestBuildUploadFiles_DirectoryRequiresRecursive(t *testing.T) {
-	_, _, err := buildUploadFiles(t.TempDir(), "/docs/", false)
+func TestPlanUploadFiles_DirectoryRequiresRecursive(t *testing.T) {
+	_, _, err := planUploadFiles(t.TempDir(), "/docs/", false)
 	if err == nil {
 		t.Fatal("expected recursive error")
 	}
 }
+
+func TestPlanUploadFiles_RecursiveWalk(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "css"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{"index.html": "<html>", "css/site.css": "body{}"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, actionPath, err := planUploadFiles(dir, "/site/", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actionPath != "site" || len(files) != 2 {
+		t.Fatalf("unexpected plan: %q %#v", actionPath, files)
+	}
+	if files[0].Name != "css/site.css" || files[0].Size != 6 || files[1].Name != "index.html" {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+}
+
+func TestPlanUploadBatches(t *testing.T) {
+	files := []UploadFile{
+		{Name: "a", Size: 40}, {Name: "b", Size: 40}, {Name: "c", Size: 40},
+		{Name: "huge", Size: 500}, {Name: "d", Size: 10},
+	}
+	batches := PlanUploadBatches(files, 100, 10)
+	var got []string
+	for _, batch := range batches {
+		var names []string
+		for _, f := range batch {
+			names = append(names, f.Name)
+		}
+		got = append(got, strings.Join(names, "+"))
+	}
+	want := []string{"a+b", "c", "huge", "d"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("batches = %v, want %v", got, want)
+	}
+}
+
+func TestPlanUploadBatches_MaxFiles(t *testing.T) {
+	files := []UploadFile{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	batches := PlanUploadBatches(files, 1<<20, 2)
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %#v", batches)
+	}
+}
+
+func TestWriteUploadBody(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.bin")
+	if err := os.WriteFile(a, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte{0, 1, 2, 255}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	batch := []UploadFile{
+		{LocalPath: a, Name: "docs/a.txt", ContentType: "text/plain; charset=utf-8", Size: 5},
+		{LocalPath: b, Name: "b.bin", ContentType: "application/octet-stream", Size: 4},
+	}
+	var buf bytes.Buffer
+	var reported []int64
+	if err := writeUploadBody(&buf, "store-ref", "site", batch, func(_ UploadFile, sent int64) { reported = append(reported, sent) }); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Name       string
+		OnType     string
+		Attributes struct {
+			StoreID string `json:"cloud_store_id"`
+			Path    string `json:"path"`
+			File    []map[string]string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("body is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if doc.Name != "upload_file" || doc.OnType != "cloud_store" || doc.Attributes.StoreID != "store-ref" || doc.Attributes.Path != "site" {
+		t.Fatalf("unexpected envelope: %+v", doc)
+	}
+	if len(doc.Attributes.File) != 2 {
+		t.Fatalf("unexpected files: %#v", doc.Attributes.File)
+	}
+	if got := doc.Attributes.File[0]["file"]; got != "data:text/plain; charset=utf-8;base64,"+base64.StdEncoding.EncodeToString([]byte("hello")) {
+		t.Fatalf("unexpected data uri: %q", got)
+	}
+	if got := doc.Attributes.File[1]["file"]; got != "data:application/octet-stream;base64,AAEC/w==" {
+		t.Fatalf("unexpected data uri: %q", got)
+	}
+	if len(reported) == 0 || reported[len(reported)-1] != 4 {
+		t.Fatalf("progress not reported: %v", reported)
+	}
+}
+
+func TestWriteUploadBody_MissingFile(t *testing.T) {
+	batch := []UploadFile{{LocalPath: filepath.Join(t.TempDir(), "gone"), Name: "gone"}}
+	if err := writeUploadBody(io.Discard, "ref", "", batch, nil); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestActionFailure(t *testing.T) {
+	ok := []daptinClient.DaptinActionResponse{{ResponseType: "client.notify", Attributes: map[string]interface{}{"type": "success", "message": "done"}}}
+	if err := ActionFailure(ok); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	failed := []daptinClient.DaptinActionResponse{{ResponseType: "client.notify", Attributes: map[string]interface{}{"type": "error", "message": "quota exceeded"}}}
+	if err := ActionFailure(failed); err == nil || err.Error() != "quota exceeded" {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for input, want := range map[string]int64{"512": 512, "64KB": 64 << 10, "8mb": 8 << 20, "1GiB": 1 << 30, "1.5M": 3 << 19} {
+		got, err := ParseByteSize(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseByteSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	if _, err := ParseByteSize("lots"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{12: "12 B", 2048: "2.0 KB", 5 << 20: "5.0 MB", 3 << 30: "3.0 GB"} {
+		if got := FormatBytes(n); got != want {
+			t.Fatalf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	daptinClient "github.com/daptin/daptin-go-client"
+	"github.com/urfave/cli/v2"
+)
+
+func storageUploadCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "upload",
+		Usage:     "Upload a file or recursive directory to cloud_store via upload_file",
+		ArgsUsage: "<store:/path> <local-path>",
+		UsageText: `daptin storage upload <store:/path> <local-path>
+   daptin storage upload local-files:/photos ./image.jpg
+   daptin storage upload local-files:/docs/ ./manual.pdf
+   daptin storage upload local-files:/site/ ./public --recursive
+   daptin storage upload local-files:/site/ ./public --recursive --batch-bytes 8MB --batch-files 50`,
+		Description: "Files are streamed from disk and base64-encoded on the fly, grouped into requests of at most --batch-bytes and --batch-files. " +
+			"A file larger than --batch-bytes is sent alone in its own request. When a batch fails its files are retried one at a time, " +
+			"and the upload continues past individual failures. cloud_store has no stream/complete route like asset columns do, so " +
+			"even a large file travels as one base64 JSON request that the server holds in memory; use asset upload for files too " +
+			"large for that.",
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{Name: "recursive", Usage: "Upload a directory recursively"},
+		}, uploadBatchFlags()...),
+		Action: func(c *cli.Context) error {
+			address, localPath := c.Args().Get(0), c.Args().Get(1)
+			if address == "" || localPath == "" {
+				return fmt.Errorf("usage: storage upload <store:/path> <local-path>")
+			}
+			storeName, destPath, err := parseStorageAddress(address)
+			if err != nil {
+				return err
+			}
+			maxBytes, maxFiles, err := uploadBatchLimits(c)
+			if err != nil {
+				return err
+			}
+			files, actionPath, err := planUploadFiles(localPath, destPath, c.Bool("recursive"))
+			if err != nil {
+				return err
+			}
+			if len(files) == 0 {
+				return fmt.Errorf("nothing to upload in %q", localPath)
+			}
+			ref, err := cloudStoreRef(appCtx, storeName)
+			if err != nil {
+				return err
+			}
+
+			slog.Info("storage upload", "store", storeName, "path", actionPath, "files", len(files))
+			return uploadFiles(appCtx, ref, actionPath, files, maxBytes, maxFiles).finish()
+		},
+	}
+}
+
+// uploadBatchFlags are shared by every command that uploads through
+// storageUploader.
+// Default upload batch limits, for the flags and for callers without them.
+const (
+	defaultBatchBytes int64 = 32 << 20
+	defaultBatchFiles       = 100
+)
+
+func uploadBatchFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "batch-bytes", Value: fmt.Sprintf("%dMB", defaultBatchBytes>>20), Usage: "Upper bound on file bytes per upload request, e.g. 512KB, 8MB, 1GB"},
+		&cli.IntFlag{Name: "batch-files", Value: defaultBatchFiles, Usage: "Upper bound on files per upload request"},
+	}
+}
+
+func uploadBatchLimits(c *cli.Context) (int64, int, error) {
+	maxBytes, err := ParseByteSize(c.String("batch-bytes"))
+	if err != nil {
+		return 0, 0, fmt.Errorf("--batch-bytes: %w", err)
+	}
+	if maxBytes < 1 || c.Int("batch-files") < 1 {
+		return 0, 0, fmt.Errorf("--batch-bytes and --batch-files must be positive")
+	}
+	return maxBytes, c.Int("batch-files"), nil
+}
+
+// uploadFiles sends files to a store directory in planned batches. The
+// returned uploader holds the tally for the caller's summary.
+func uploadFiles(appCtx *AppContext, storeRef, actionPath string, files []UploadFile, maxBytes int64, maxFiles int) *storageUploader {
+	up := &storageUploader{
+		appCtx:     appCtx,
+		storeRef:   storeRef,
+		actionPath: actionPath,
+		maxBytes:   maxBytes,
+		total:      len(files),
+	}
+	for _, batch := range PlanUploadBatches(files, maxBytes, maxFiles) {
+		up.send(batch)
+	}
+	return up
+}
+
+// UploadFile is one local file queued for upload_file. Hash is only
+// filled in by storage sync when it needs to compare contents.
+type UploadFile struct {
+	LocalPath   string
+	Name        string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+	Hash        string
+}
+
+// storageUploader sends batches and keeps the running tally for the summary.
+type storageUploader struct {
+	appCtx     *AppContext
+	storeRef   string
+	actionPath string
+	maxBytes   int64
+	total      int
+
+	done     int
+	uploaded int
+	bytes    int64
+	requests int
+	failed   []map[string]interface{}
+}
+
+// send uploads one batch. A failed multi-file batch is retried file by file
+// so one bad file does not take the rest of the batch down with it.
+func (u *storageUploader) send(batch []UploadFile) {
+	err := u.post(batch)
+	if err != nil && len(batch) > 1 {
+		slog.Warn("storage upload batch failed, retrying files individually", "files", len(batch), "error", err)
+		for _, file := range batch {
+			u.send([]UploadFile{file})
+		}
+		return
+	}
+	for _, file := range batch {
+		u.done++
+		if err != nil {
+			u.failed = append(u.failed, map[string]interface{}{"name": file.Name, "error": err.Error()})
+			u.progress("[%d/%d] FAIL %s: %v\n", u.done, u.total, file.Name, err)
+			continue
+		}
+		u.uploaded++
+		u.bytes += file.Size
+		u.progress("[%d/%d] %s (%s)\n", u.done, u.total, file.Name, FormatBytes(file.Size))
+	}
+}
+
+func (u *storageUploader) post(batch []UploadFile) error {
+	u.requests++
+	var onProgress func(file UploadFile, sent int64)
+	if len(batch) == 1 && batch[0].Size > u.maxBytes {
+		// Oversized files get a progress line every 10% while streaming
+		next := int64(10)
+		onProgress = func(file UploadFile, sent int64) {
+			for file.Size > 0 && sent*100/file.Size >= next && next < 100 {
+				u.progress("    %s %d%% (%s / %s)\n", file.Name, next, FormatBytes(sent), FormatBytes(file.Size))
+				next += 10
+			}
+		}
+	}
+	body, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeUploadBody(writer, u.storeRef, u.actionPath, batch, onProgress))
+	}()
+	responses, err := u.appCtx.Client.ExecuteStream("upload_file", "cloud_store", body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	return ActionFailure(responses)
+}
+
+func (u *storageUploader) progress(format string, args ...interface{}) {
+	if !u.appCtx.Quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// finish prints the summary and fails when any file did not upload.
+func (u *storageUploader) finish() error {
+	switch {
+	case u.appCtx.Quiet:
+	case u.appCtx.StructuredOutput():
+		failed := u.failed
+		if failed == nil {
+			failed = []map[string]interface{}{}
+		}
+		if err := u.appCtx.Renderer.RenderObject(map[string]interface{}{
+			"uploaded": u.uploaded,
+			"failed":   failed,
+			"bytes":    u.bytes,
+			"requests": u.requests,
+		}); err != nil {
+			return err
+		}
+	default:
+		fmt.Fprintf(os.Stdout, "Uploaded %d files (%s) in %d requests, %d failed\n", u.uploaded, FormatBytes(u.bytes), u.requests, len(u.failed))
+	}
+	if len(u.failed) > 0 {
+		names := make([]string, 0, len(u.failed))
+		for _, f := range u.failed {
+			names = append(names, fmt.Sprint(f["name"]))
+		}
+		return fmt.Errorf("%d of %d files failed to upload: %s", len(u.failed), u.total, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// writeUploadBody writes the upload_file action document for a batch,
+// base64-encoding each file straight from disk into w.
+func writeUploadBody(w io.Writer, storeRef, actionPath string, batch []UploadFile, onProgress func(file UploadFile, sent int64)) error {
+	prefix, err := json.Marshal(map[string]interface{}{
+		"Name":   "upload_file",
+		"OnType": "cloud_store",
+	})
+	if err != nil {
+		return err
+	}
+	attrs, err := json.Marshal(map[string]interface{}{
+		"cloud_store_id": storeRef,
+		"path":           actionPath,
+	})
+	if err != nil {
+		return err
+	}
+	// {"Name":..,"OnType":..,"Attributes":{"cloud_store_id":..,"path":..,"file":[...]}}
+	head := string(prefix[:len(prefix)-1]) + `,"Attributes":` + string(attrs[:len(attrs)-1]) + `,"file":[`
+	if _, err := io.WriteString(w, head); err != nil {
+		return err
+	}
+	for i, file := range batch {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := writeUploadFile(w, file, onProgress); err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]}}")
+	return err
+}
+
+func writeUploadFile(w io.Writer, file UploadFile, onProgress func(file UploadFile, sent int64)) error {
+	meta, err := json.Marshal(map[string]interface{}{
+		"name": file.Name,
+		"type": file.ContentType,
+	})
+	if err != nil {
+		return err
+	}
+	dataPrefix, err := json.Marshal("data:" + file.ContentType + ";base64,")
+	if err != nil {
+		return err
+	}
+	// Open the data URI string; the base64 payload and closing quote follow
+	if _, err := io.WriteString(w, string(meta[:len(meta)-1])+`,"file":`+string(dataPrefix[:len(dataPrefix)-1])); err != nil {
+		return err
+	}
+	f, err := os.Open(file.LocalPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.Name, err)
+	}
+	defer f.Close()
+	encoder := base64.NewEncoder(base64.StdEncoding, w)
+	var reader io.Reader = f
+	if onProgress != nil {
+		reader = &progressReader{reader: f, onRead: func(sent int64) { onProgress(file, sent) }}
+	}
+	if _, err := io.Copy(encoder, reader); err != nil {
+		return fmt.Errorf("%s: %w", file.Name, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, `"}`)
+	return err
+}
+
+// progressReader reports the running byte count after every read.
+type progressReader struct {
+	reader io.Reader
+	sent   int64
+	onRead func(sent int64)
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.sent += int64(n)
+	p.onRead(p.sent)
+	return n, err
+}
+
+// planUploadFiles lists the files to upload and the remote directory they
+// go into. Nothing is read yet; a single file may be renamed by giving a
+// destination that does not end in "/".
+func planUploadFiles(localPath, destPath string, recursive bool) ([]UploadFile, string, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if info.IsDir() {
+		if !recursive {
+			return nil, "", fmt.Errorf("directory upload requires --recursive")
+		}
+		files := make([]UploadFile, 0)
+		err := filepath.WalkDir(localPath, func(path string, entry fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if entry.IsDir() {
+				return nil
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				slog.Debug("skipping non-regular file", "path", path)
+				return nil
+			}
+			rel, err := filepath.Rel(localPath, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, UploadFile{
+				LocalPath:   path,
+				Name:        filepath.ToSlash(rel),
+				ContentType: contentTypeForPath(path),
+				Size:        info.Size(),
+				ModTime:     info.ModTime(),
+			})
+			return nil
+		})
+		return files, normalizeRemoteDir(destPath), err
+	}
+
+	actionPath := normalizeRemoteDir(destPath)
+	filename := filepath.Base(localPath)
+	if !strings.HasSuffix(destPath, "/") {
+		base := filepath.Base(destPath)
+		if base != "." && base != "/" && base != "" {
+			filename = base
+			actionPath = normalizeRemoteDir(filepath.Dir(destPath))
+		}
+	}
+	return []UploadFile{{
+		LocalPath:   localPath,
+		Name:        filename,
+		ContentType: contentTypeForPath(localPath),
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+	}}, actionPath, nil
+}
+
+// PlanUploadBatches groups files, in order, into requests of at most
+// maxBytes and maxFiles. A file larger than maxBytes gets a batch to itself.
+// Pure function.
+func PlanUploadBatches(files []UploadFile, maxBytes int64, maxFiles int) [][]UploadFile {
+	var batches [][]UploadFile
+	var current []UploadFile
+	var currentBytes int64
+	for _, file := range files {
+		if len(current) > 0 && (currentBytes+file.Size > maxBytes || len(current) >= maxFiles) {
+			batches = append(batches, current)
+			current, currentBytes = nil, 0
+		}
+		current = append(current, file)
+		currentBytes += file.Size
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// ActionFailure returns an error for an error notification in an action
+// response, which Daptin sends with a 200 status for some failures.
+// Pure function.
+func ActionFailure(responses []daptinClient.DaptinActionResponse) error {
+	for _, r := range responses {
+		if r.ResponseType != "client.notify" {
+			continue
+		}
+		if kind, _ := r.Attributes["type"].(string); kind == "error" {
+			message, _ := r.Attributes["message"].(string)
+			if message == "" {
+				message, _ = r.Attributes["title"].(string)
+			}
+			return fmt.Errorf("%s", message)
+		}
+	}
+	return nil
+}
+
+// ParseByteSize parses sizes like "512", "64KB", "8MB" or "1GB" (powers of
+// 1024; a trailing "iB" is accepted too).
+// Pure function.
+func ParseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.Replace(s, "IB", "B", 1), "B")
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			multiplier = unit.size
+			s = strings.TrimSuffix(s, unit.suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatBytes renders a byte count as B, KB, MB, GB or TB (powers of 1024).
+// Pure function.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
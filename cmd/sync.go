@@ -0,0 +1,530 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	daptinClient "github.com/daptin/daptin-go-client"
+	"github.com/urfave/cli/v2"
+)
+
+// SyncColumn is one column of the local SQLite copy of an entity.
+type SyncColumn struct {
+	Name string
+	Type string
+}
+
+func syncCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "sync",
+		Usage:     "Copy an entity into a local SQLite table and keep it up to date",
+		ArgsUsage: "<entity>",
+		UsageText: `daptin sync <entity> --db <file> [flags]
+   daptin sync document --db local.sqlite
+   daptin sync document --db local.sqlite --follow none
+   daptin sync document --db local.sqlite --follow poll --interval 1m
+   daptin sync user_account --db local.sqlite --table users --sqlite3 /usr/local/bin/sqlite3`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "db",
+				Usage:    "SQLite database `FILE` (created if missing)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "table",
+				Usage: "Local table name (default: the entity name)",
+			},
+			&cli.StringFlag{
+				Name:  "follow",
+				Usage: "Keep the copy updated after the initial pull: ws (table events), poll (created_at/updated_at), or none",
+				Value: "ws",
+			},
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "Polling interval for --follow poll",
+				Value: 30 * time.Second,
+			},
+			&cli.IntFlag{
+				Name:  "page-size",
+				Usage: "Rows per request during the pull",
+				Value: 500,
+			},
+			&cli.StringFlag{
+				Name:  "sqlite3",
+				Usage: "Path to the sqlite3 executable",
+				Value: "sqlite3",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			entityName := c.Args().Get(0)
+			if entityName == "" {
+				return fmt.Errorf("entity name required")
+			}
+			follow := c.String("follow")
+			if follow != "ws" && follow != "poll" && follow != "none" {
+				return fmt.Errorf("--follow must be ws, poll or none, got %q", follow)
+			}
+			if c.Int("page-size") < 1 {
+				return fmt.Errorf("--page-size must be at least 1")
+			}
+			if follow == "poll" && c.Duration("interval") <= 0 {
+				return fmt.Errorf("--interval must be positive with --follow poll")
+			}
+			table := c.String("table")
+			if table == "" {
+				table = entityName
+			}
+			slog.Info("sync", "entity", entityName, "db", c.String("db"), "table", table, "follow", follow)
+
+			worlds, err := loadWorldSchemas(appCtx)
+			if err != nil {
+				return err
+			}
+			var world *worldSchema
+			for i := range worlds {
+				if worlds[i].TableName == entityName {
+					world = &worlds[i]
+					break
+				}
+			}
+			if world == nil {
+				return fmt.Errorf("entity %q not found", entityName)
+			}
+			rawColumns, _ := world.Schema["Columns"].([]interface{})
+			schemaColumns := make([]map[string]interface{}, 0, len(rawColumns))
+			for _, col := range rawColumns {
+				if cm, ok := col.(map[string]interface{}); ok {
+					schemaColumns = append(schemaColumns, cm)
+				}
+			}
+			columns := SyncColumns(schemaColumns)
+
+			db, err := openSQLite(c.String("sqlite3"), c.String("db"))
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			s := &entitySync{
+				appCtx:   appCtx,
+				db:       db,
+				entity:   entityName,
+				table:    table,
+				columns:  columns,
+				pageSize: c.Int("page-size"),
+			}
+			if err := s.initialPull(); err != nil {
+				return err
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Synced %d rows of %s into %s:%s\n", s.rows, entityName, c.String("db"), table)
+			}
+
+			switch follow {
+			case "ws":
+				if !appCtx.Quiet {
+					fmt.Fprintf(os.Stderr, "Following table events (Ctrl-C to stop)\n")
+				}
+				err = s.followEvents()
+			case "poll":
+				if !appCtx.Quiet {
+					fmt.Fprintf(os.Stderr, "Polling every %s (Ctrl-C to stop)\n", c.Duration("interval"))
+				}
+				err = s.followPoll(c.Duration("interval"))
+			}
+			if err != nil {
+				return err
+			}
+			return db.Close()
+		},
+	}
+}
+
+// entitySync holds the state of one sync run.
+type entitySync struct {
+	appCtx   *AppContext
+	db       *sqliteShell
+	entity   string
+	table    string
+	columns  []SyncColumn
+	pageSize int
+
+	rows      int
+	watermark string
+}
+
+// initialPull loads every row into a staging table and swaps it in with a
+// single transaction, so readers never see a half-filled copy.
+func (s *entitySync) initialPull() error {
+	staging := s.table + "__sync"
+	if err := s.db.Exec("PRAGMA journal_mode=WAL;\n" + syncStateTableSQL + "\n" + CreateTableSQL(staging, s.columns)); err != nil {
+		return err
+	}
+	count, err := s.pull(staging, nil)
+	if err != nil {
+		return err
+	}
+	s.rows = count
+	return s.db.Exec(fmt.Sprintf("BEGIN;\nDROP TABLE IF EXISTS %s;\nALTER TABLE %s RENAME TO %s;\n%s\nCOMMIT;",
+		quoteIdent(s.table), quoteIdent(staging), quoteIdent(s.table), s.stateSQL()))
+}
+
+// pull fetches every page matching the clauses and upserts it into table,
+// one transaction per page. It returns the number of rows written. Pages are
+// ordered by id so rows added during the pull do not shift earlier pages.
+func (s *entitySync) pull(table string, clauses []FilterClause) (int, error) {
+	total := 0
+	for page := 1; ; page++ {
+		params := daptinClient.DaptinQueryParameters{
+			"page[size]":   s.pageSize,
+			"page[number]": page,
+			"sort":         "id",
+		}
+		if len(clauses) > 0 {
+			params["query"] = FilterToJSON(clauses)
+		}
+		result, err := s.appCtx.Client.FindAll(s.entity, params)
+		if err != nil {
+			return total, err
+		}
+		rows := client.MapArray(result, "attributes")
+		if len(rows) > 0 {
+			var sql strings.Builder
+			sql.WriteString("BEGIN;\n")
+			for _, row := range rows {
+				if statement := UpsertSQL(table, s.columns, row); statement != "" {
+					sql.WriteString(statement + "\n")
+				}
+				s.watermark = RowWatermark(row, s.watermark)
+			}
+			sql.WriteString("COMMIT;")
+			if err := s.db.Exec(sql.String()); err != nil {
+				return total, err
+			}
+			total += len(rows)
+			slog.Debug("sync page", "entity", s.entity, "page", page, "rows", len(rows))
+			if !s.appCtx.Quiet && clauses == nil {
+				fmt.Fprintf(os.Stderr, "\rPulled %d rows", total)
+			}
+		}
+		if len(rows) < s.pageSize {
+			break
+		}
+	}
+	if !s.appCtx.Quiet && clauses == nil && total > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	return total, nil
+}
+
+// catchUp pulls rows created or updated at or after the watermark. Daptin
+// leaves updated_at empty until the first update, so both columns are
+// queried. Rows already in the table are upserted again, which is harmless.
+func (s *entitySync) catchUp() error {
+	if s.watermark == "" {
+		return nil
+	}
+	since := CatchUpSince(s.watermark)
+	changed := 0
+	for _, column := range []string{"created_at", "updated_at"} {
+		count, err := s.pull(s.table, []FilterClause{{Column: column, Operator: "after", Value: since}})
+		if err != nil {
+			return err
+		}
+		changed += count
+	}
+	if changed > 0 {
+		slog.Info("sync caught up", "entity", s.entity, "rows", changed, "since", since)
+	}
+	return s.db.Exec(s.stateSQL())
+}
+
+// followEvents applies table events as they arrive. Once subscribed it
+// catches up on anything changed during the initial pull.
+func (s *entitySync) followEvents() error {
+	return watchEntity(s.appCtx, s.entity, nil, s.catchUp, func(action string, row map[string]interface{}) (bool, error) {
+		statement := UpsertSQL(s.table, s.columns, row)
+		if action == "delete" {
+			ref, _ := row["reference_id"].(string)
+			statement = DeleteSQL(s.table, ref)
+		}
+		if statement == "" {
+			slog.Warn("skipping event without reference_id", "entity", s.entity, "event", action)
+			return false, nil
+		}
+		s.watermark = RowWatermark(row, s.watermark)
+		return false, s.db.Exec(statement + "\n" + s.stateSQL())
+	})
+}
+
+// followPoll re-queries changed rows every interval. Deletes cannot be seen
+// this way; use --follow ws to mirror them.
+func (s *entitySync) followPoll(interval time.Duration) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sigCh:
+			return nil
+		case <-ticker.C:
+		}
+		if err := s.catchUp(); err != nil {
+			return err
+		}
+	}
+}
+
+const syncStateTableSQL = `CREATE TABLE IF NOT EXISTS "_daptin_sync" (
+  "table_name" TEXT PRIMARY KEY,
+  "entity" TEXT,
+  "synced_at" TEXT,
+  "watermark" TEXT
+);`
+
+func (s *entitySync) stateSQL() string {
+	return fmt.Sprintf(`INSERT INTO "_daptin_sync" ("table_name", "entity", "synced_at", "watermark") VALUES (%s, %s, %s, %s) `+
+		`ON CONFLICT("table_name") DO UPDATE SET "entity" = excluded."entity", "synced_at" = excluded."synced_at", "watermark" = excluded."watermark";`,
+		SQLiteLiteral(s.table), SQLiteLiteral(s.entity), SQLiteLiteral(time.Now().UTC().Format(time.RFC3339)), SQLiteLiteral(s.watermark))
+}
+
+// sqliteShell feeds SQL to a long-running sqlite3 process. Each Exec is
+// followed by a marker query so errors surface on the statement that caused
+// them rather than on the next write.
+type sqliteShell struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr bytes.Buffer
+	seq    int
+	closed bool
+}
+
+func openSQLite(binary, path string) (*sqliteShell, error) {
+	s := &sqliteShell{}
+	s.cmd = exec.Command(binary, "-bail", "-batch", path)
+	s.cmd.Stderr = &s.stderr
+	stdin, err := s.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := s.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", binary, err)
+	}
+	s.stdin = stdin
+	s.stdout = bufio.NewReader(stdout)
+	return s, nil
+}
+
+func (s *sqliteShell) Exec(sql string) error {
+	s.seq++
+	marker := fmt.Sprintf("daptin-sync-%d", s.seq)
+	if _, err := io.WriteString(s.stdin, sql+"\nSELECT '"+marker+"';\n"); err != nil {
+		return s.failure(err)
+	}
+	for {
+		line, err := s.stdout.ReadString('\n')
+		if err != nil {
+			return s.failure(err)
+		}
+		if strings.TrimSpace(line) == marker {
+			return nil
+		}
+	}
+}
+
+// failure waits for the exited process and reports what sqlite3 printed.
+func (s *sqliteShell) failure(err error) error {
+	s.Close()
+	if message := strings.TrimSpace(s.stderr.String()); message != "" {
+		return fmt.Errorf("sqlite3: %s", message)
+	}
+	return fmt.Errorf("sqlite3: %w", err)
+}
+
+func (s *sqliteShell) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.stdin.Close()
+	if err := s.cmd.Wait(); err != nil {
+		return fmt.Errorf("sqlite3: %s", strings.TrimSpace(s.stderr.String()))
+	}
+	return nil
+}
+
+// SyncColumns maps world schema columns to SQLite columns in schema order.
+// The internal integer id is skipped; reference_id is always present and
+// becomes the primary key.
+// Pure function.
+func SyncColumns(schemaColumns []map[string]interface{}) []SyncColumn {
+	columns := []SyncColumn{{Name: "reference_id", Type: "TEXT"}}
+	for _, col := range schemaColumns {
+		name, _ := col["ColumnName"].(string)
+		if name == "" || name == "id" || name == "reference_id" {
+			continue
+		}
+		dataType, _ := col["DataType"].(string)
+		columnType, _ := col["ColumnType"].(string)
+		columns = append(columns, SyncColumn{Name: name, Type: SQLiteType(dataType, columnType)})
+	}
+	return columns
+}
+
+// SQLiteType picks a SQLite type from Daptin's SQL data type, falling back
+// to the Daptin column type when the data type is missing.
+// Pure function.
+func SQLiteType(dataType, columnType string) string {
+	dataType = strings.ToLower(dataType)
+	switch {
+	case dataType == "":
+	case strings.Contains(dataType, "int"), strings.Contains(dataType, "bool"):
+		return "INTEGER"
+	case strings.Contains(dataType, "float"), strings.Contains(dataType, "double"),
+		strings.Contains(dataType, "real"), strings.Contains(dataType, "decimal"), strings.Contains(dataType, "numeric"):
+		return "REAL"
+	case strings.Contains(dataType, "blob"), strings.Contains(dataType, "binary"):
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+	switch {
+	case columnType == "truefalse", columnType == "measurement":
+		return "INTEGER"
+	case columnType == "float", strings.HasPrefix(columnType, "location"):
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+// CreateTableSQL drops and recreates a table with reference_id as primary key.
+// Pure function.
+func CreateTableSQL(table string, columns []SyncColumn) string {
+	definitions := make([]string, 0, len(columns))
+	for _, column := range columns {
+		definition := quoteIdent(column.Name) + " " + column.Type
+		if column.Name == "reference_id" {
+			definition += " PRIMARY KEY"
+		}
+		definitions = append(definitions, "  "+definition)
+	}
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s;\nCREATE TABLE %s (\n%s\n);",
+		quoteIdent(table), quoteIdent(table), strings.Join(definitions, ",\n"))
+}
+
+// UpsertSQL inserts a row or updates the columns present in it. Columns the
+// row does not carry keep their stored value, so partial event payloads are
+// safe. It returns "" for rows without a reference_id.
+// Pure function.
+func UpsertSQL(table string, columns []SyncColumn, row map[string]interface{}) string {
+	if ref, _ := row["reference_id"].(string); ref == "" {
+		return ""
+	}
+	var names, values, updates []string
+	for _, column := range columns {
+		value, ok := row[column.Name]
+		if !ok {
+			continue
+		}
+		name := quoteIdent(column.Name)
+		names = append(names, name)
+		values = append(values, SQLiteLiteral(value))
+		if column.Name != "reference_id" {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", name, name))
+		}
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdent(table), strings.Join(names, ", "), strings.Join(values, ", "))
+	if len(updates) == 0 {
+		return statement + ` ON CONFLICT("reference_id") DO NOTHING;`
+	}
+	return statement + ` ON CONFLICT("reference_id") DO UPDATE SET ` + strings.Join(updates, ", ") + ";"
+}
+
+// DeleteSQL deletes a row by reference_id; "" when the id is empty.
+// Pure function.
+func DeleteSQL(table, referenceId string) string {
+	if referenceId == "" {
+		return ""
+	}
+	return fmt.Sprintf(`DELETE FROM %s WHERE "reference_id" = %s;`, quoteIdent(table), SQLiteLiteral(referenceId))
+}
+
+// SQLiteLiteral formats a JSON value as a SQL literal. Booleans become 0/1
+// and objects or arrays are stored as JSON text.
+// Pure function.
+func SQLiteLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "NULL"
+		}
+		return SQLiteLiteral(string(data))
+	}
+}
+
+// RowWatermark returns the later of current and the row's created_at and
+// updated_at. Daptin timestamps share one format, so they compare as strings.
+// Pure function.
+func RowWatermark(row map[string]interface{}, current string) string {
+	for _, key := range []string{"created_at", "updated_at"} {
+		if value, ok := row[key].(string); ok && value > current {
+			current = value
+		}
+	}
+	return current
+}
+
+// CatchUpSince returns the value for an "after" filter that also matches
+// rows stamped in the same second as the watermark: timestamps have
+// one-second resolution and Daptin has no inclusive comparison. A watermark
+// that does not parse as a timestamp is returned unchanged.
+// Pure function.
+func CatchUpSince(watermark string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, watermark); err == nil {
+			if layout == time.RFC3339Nano {
+				layout = time.RFC3339
+			}
+			return t.Add(-time.Second).Format(layout)
+		}
+	}
+	return watermark
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteType(t *testing.T) {
+	cases := []struct {
+		dataType, columnType, expected string
+	}{
+		{"int(11)", "", "INTEGER"},
+		{"bool", "truefalse", "INTEGER"},
+		{"float(7,4)", "", "REAL"},
+		{"decimal(10,2)", "", "REAL"},
+		{"blob", "", "BLOB"},
+		{"varchar(100)", "label", "TEXT"},
+		{"timestamp", "datetime", "TEXT"},
+		{"", "measurement", "INTEGER"},
+		{"", "location.latitude", "REAL"},
+		{"", "json", "TEXT"},
+	}
+	for _, tc := range cases {
+		if got := SQLiteType(tc.dataType, tc.columnType); got != tc.expected {
+			t.Errorf("SQLiteType(%q, %q): expected %s, got %s", tc.dataType, tc.columnType, tc.expected, got)
+		}
+	}
+}
+
+func TestSyncColumns(t *testing.T) {
+	columns := SyncColumns([]map[string]interface{}{
+		{"ColumnName": "id", "DataType": "int(11)"},
+		{"ColumnName": "name", "DataType": "varchar(100)"},
+		{"ColumnName": "reference_id", "DataType": "varchar(40)"},
+		{"ColumnName": "count", "DataType": "int(11)"},
+	})
+	expected := []SyncColumn{{"reference_id", "TEXT"}, {"name", "TEXT"}, {"count", "INTEGER"}}
+	if !reflect.DeepEqual(columns, expected) {
+		t.Fatalf("expected %v, got %v", expected, columns)
+	}
+}
+
+func TestCreateTableSQL(t *testing.T) {
+	sql := CreateTableSQL("doc", []SyncColumn{{"reference_id", "TEXT"}, {"size", "INTEGER"}})
+	for _, expected := range []string{`DROP TABLE IF EXISTS "doc";`, `CREATE TABLE "doc"`, `"reference_id" TEXT PRIMARY KEY`, `"size" INTEGER`} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("expected %q in:\n%s", expected, sql)
+		}
+	}
+}
+
+func TestUpsertSQL(t *testing.T) {
+	columns := []SyncColumn{{"reference_id", "TEXT"}, {"name", "TEXT"}, {"price", "REAL"}}
+	sql := UpsertSQL("doc", columns, map[string]interface{}{"reference_id": "d1", "name": "it's", "extra": 1})
+	expected := `INSERT INTO "doc" ("reference_id", "name") VALUES ('d1', 'it''s') ON CONFLICT("reference_id") DO UPDATE SET "name" = excluded."name";`
+	if sql != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, sql)
+	}
+	if got := UpsertSQL("doc", columns, map[string]interface{}{"reference_id": "d1"}); !strings.HasSuffix(got, "DO NOTHING;") {
+		t.Fatalf("expected DO NOTHING for id-only row, got %s", got)
+	}
+	if got := UpsertSQL("doc", columns, map[string]interface{}{"name": "x"}); got != "" {
+		t.Fatalf("expected empty statement without reference_id, got %s", got)
+	}
+}
+
+func TestDeleteSQL(t *testing.T) {
+	if got := DeleteSQL(`we"ird`, "d1"); got != `DELETE FROM "we""ird" WHERE "reference_id" = 'd1';` {
+		t.Fatalf("unexpected delete: %s", got)
+	}
+	if DeleteSQL("doc", "") != "" {
+		t.Fatal("expected empty statement without reference_id")
+	}
+}
+
+func TestSQLiteLiteral(t *testing.T) {
+	cases := map[string]interface{}{
+		"NULL":        nil,
+		"1":           true,
+		"0":           false,
+		"42":          float64(42),
+		"1.5":         1.5,
+		"'a''b'":      "a'b",
+		`'["x",1]'`:   []interface{}{"x", 1},
+		`'{"k":"v"}'`: map[string]interface{}{"k": "v"},
+	}
+	for expected, value := range cases {
+		if got := SQLiteLiteral(value); got != expected {
+			t.Errorf("SQLiteLiteral(%v): expected %s, got %s", value, expected, got)
+		}
+	}
+}
+
+func TestRowWatermark(t *testing.T) {
+	row := map[string]interface{}{"created_at": "2024-01-02T00:00:00Z", "updated_at": "2024-03-01T00:00:00Z"}
+	if got := RowWatermark(row, "2024-02-01T00:00:00Z"); got != "2024-03-01T00:00:00Z" {
+		t.Fatalf("unexpected watermark %s", got)
+	}
+	if got := RowWatermark(map[string]interface{}{"updated_at": nil}, "2024-02-01T00:00:00Z"); got != "2024-02-01T00:00:00Z" {
+		t.Fatalf("expected current watermark to be kept, got %s", got)
+	}
+}
+
+func TestCatchUpSince(t *testing.T) {
+	cases := map[string]string{
+		"2024-03-01T00:00:00Z":      "2024-02-29T23:59:59Z",
+		"2024-03-01T10:00:05+02:00": "2024-03-01T10:00:04+02:00",
+		"2024-03-01T00:00:00.5Z":    "2024-02-29T23:59:59Z",
+		"2024-03-01 00:00:00":       "2024-02-29 23:59:59",
+		"not a time":                "not a time",
+	}
+	for watermark, expected := range cases {
+		if got := CatchUpSince(watermark); got != expected {
+			t.Errorf("CatchUpSince(%q): expected %q, got %q", watermark, expected, got)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+func tablesCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "tables",
+		Usage: "List all entities with column/action counts, kind and default permission",
+		UsageText: `daptin tables [flags]
+   daptin tables
+   daptin tables --filter user
+   daptin tables --filter "*_audit"
+   daptin -q tables`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "Only show tables whose name contains this text or matches this glob",
+			},
+			&cli.BoolFlag{
+				Name:  "no-join-tables",
+				Usage: "Hide generated *_has_* join tables",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			slog.Info("tables", "filter", c.String("filter"))
+			worlds, err := loadWorldSchemas(appCtx)
+			if err != nil {
+				return err
+			}
+			actionCounts := map[string]int{}
+			actions, err := appCtx.Client.FindAllPages("action", nil, 500)
+			if err != nil {
+				slog.Warn("could not list actions", "error", err)
+			}
+			for _, a := range client.MapArray(actions, "attributes") {
+				if worldID, ok := a["world_id"].(string); ok {
+					actionCounts[worldID]++
+				}
+			}
+
+			rows := TableSummaryRows(worlds, actionCounts, c.String("filter"), !c.Bool("no-join-tables"))
+			slog.Debug("tables results", "count", len(rows))
+			if appCtx.Quiet {
+				for _, row := range rows {
+					fmt.Println(row["table_name"])
+				}
+				return nil
+			}
+			if len(rows) == 0 {
+				fmt.Println("No tables found")
+				return nil
+			}
+			return appCtx.Renderer.RenderArray(rows)
+		},
+	}
+}
+
+// TableSummaryRows builds one summary row per world, sorted by table name.
+// actionCounts is keyed by world reference_id.
+// Pure function.
+func TableSummaryRows(worlds []worldSchema, actionCounts map[string]int, filter string, includeJoin bool) []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(worlds))
+	for _, w := range worlds {
+		if !tableNameMatches(w.TableName, filter) {
+			continue
+		}
+		kind := tableKind(w)
+		if !includeJoin && strings.Contains(kind, "join") {
+			continue
+		}
+		columns, _ := w.Schema["Columns"].([]interface{})
+		refID, _ := w.Attrs["reference_id"].(string)
+
+		row := map[string]interface{}{
+			"table_name":         w.TableName,
+			"columns":            len(columns),
+			"actions":            actionCounts[refID],
+			"kind":               kind,
+			"default_permission": "",
+		}
+		defaults := &tableDefaults{Schema: w.Schema}
+		if permission, ok := defaults.defaultPermission(); ok {
+			row["default_permission"] = permission
+		} else if value, ok := w.Attrs["default_permission"].(float64); ok {
+			row["default_permission"] = int64(value)
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i]["table_name"].(string) < rows[j]["table_name"].(string)
+	})
+	return rows
+}
+
+func tableKind(w worldSchema) string {
+	var kinds []string
+	if boolValue(w.Attrs["is_join_table"]) || boolValue(w.Schema["IsJoinTable"]) || strings.Contains(w.TableName, "_has_") {
+		kinds = append(kinds, "join")
+	} else if boolValue(w.Attrs["is_top_level"]) || boolValue(w.Schema["IsTopLevel"]) {
+		kinds = append(kinds, "top-level")
+	}
+	if boolValue(w.Attrs["is_state_tracking_enabled"]) || boolValue(w.Schema["IsStateTrackingEnabled"]) {
+		kinds = append(kinds, "state-machine")
+	}
+	if boolValue(w.Attrs["is_hidden"]) || boolValue(w.Schema["IsHidden"]) {
+		kinds = append(kinds, "hidden")
+	}
+	return strings.Join(kinds, ",")
+}
+
+func tableNameMatches(name, filter string) bool {
+	if filter == "" {
+		return true
+	}
+	if strings.ContainsAny(filter, "*?[") {
+		matched, err := path.Match(filter, name)
+		return err == nil && matched
+	}
+	return strings.Contains(name, filter)
+}
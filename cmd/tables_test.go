@@ -0,0 +1,38 @@
+package cmd
+
+import "testing"
+
+func testTableWorlds() []worldSchema {
+	return decodeWorldSchemas([]map[string]interface{}{
+		{"table_name": "user_account", "reference_id": "w-1", "is_top_level": true, "world_schema_json": `{"Columns":[{"ColumnName":"email"},{"ColumnName":"name"}],"DefaultPermission":561441}`},
+		{"table_name": "document", "reference_id": "w-2", "is_top_level": true, "is_state_tracking_enabled": true, "default_permission": float64(2), "world_schema_json": `{"Columns":[{"ColumnName":"title"}]}`},
+		{"table_name": "user_account_user_account_id_has_usergroup_usergroup_id", "reference_id": "w-3", "is_join_table": true, "world_schema_json": `{}`},
+	})
+}
+
+func TestTableSummaryRows(t *testing.T) {
+	rows := TableSummaryRows(testTableWorlds(), map[string]int{"w-1": 4}, "", true)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	if rows[0]["table_name"] != "document" || rows[0]["kind"] != "top-level,state-machine" || rows[0]["default_permission"] != int64(2) {
+		t.Fatalf("unexpected document row: %#v", rows[0])
+	}
+	if rows[1]["table_name"] != "user_account" || rows[1]["columns"] != 2 || rows[1]["actions"] != 4 || rows[1]["default_permission"] != int64(561441) {
+		t.Fatalf("unexpected user_account row: %#v", rows[1])
+	}
+	if rows[2]["kind"] != "join" {
+		t.Fatalf("expected join kind, got %#v", rows[2])
+	}
+}
+
+func TestTableSummaryRowsFilter(t *testing.T) {
+	rows := TableSummaryRows(testTableWorlds(), nil, "user", false)
+	if len(rows) != 1 || rows[0]["table_name"] != "user_account" {
+		t.Fatalf("unexpected filtered rows: %#v", rows)
+	}
+	rows = TableSummaryRows(testTableWorlds(), nil, "doc*", true)
+	if len(rows) != 1 || rows[0]["table_name"] != "document" {
+		t.Fatalf("unexpected glob rows: %#v", rows)
+	}
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+// watchFlag is shared by list and get.
+func watchFlag() cli.Flag {
+	return &cli.BoolFlag{
+		Name:  "watch",
+		Usage: "After the initial result, stream create/update/delete changes from the entity's topic (Ctrl-C to stop)",
+	}
+}
+
+// watchEntity subscribes to an entity's table topic and calls handle for
+// every create/update/delete event whose row matches the clauses. handle
+// returns true to stop watching. ready, if set, runs once the subscription
+// is active. Ctrl-C stops cleanly.
+func watchEntity(appCtx *AppContext, entityName string, clauses []FilterClause, ready func() error, handle func(action string, row map[string]interface{}) (bool, error)) error {
+	serverFilters, localFilters := SplitWSFilters(clauses)
+	ws, err := client.DialReconnecting(appCtx.Client.Endpoint, appCtx.Client.AuthToken, client.DefaultReconnectOptions())
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	if err := ws.Subscribe(entityName, serverFilters); err != nil {
+		return err
+	}
+	slog.Info("watching", "entity", entityName, "server_filters", serverFilters)
+	if ready != nil {
+		if err := ready(); err != nil {
+			return err
+		}
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+	go func() {
+		if _, ok := <-sigCh; ok {
+			ws.Unsubscribe()
+			ws.Close()
+		}
+	}()
+
+	for {
+		msg, err := ws.Next()
+		if err != nil {
+			if errors.Is(err, client.ErrWSClosed) {
+				return nil
+			}
+			return err
+		}
+		if msg["type"] == "reconnected" {
+			// Changes made while disconnected were not delivered
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Reconnected (attempt %v); changes during the outage may be missing\n", msg["attempt"])
+			}
+			continue
+		}
+		action, row, ok := WatchChange(msg)
+		if !ok || !MatchEventFilters(msg, localFilters) {
+			continue
+		}
+		slog.Debug("watch change", "entity", entityName, "event", action, "reference_id", row["reference_id"])
+		done, err := handle(action, row)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// watchRows runs initial, which fetches and renders the current rows, once
+// the subscription is active so no change falls between the two, then
+// streams list changes: a fixed-width table row per change, a compact JSON
+// line per change for structured output, or "event ref" with -q. Table
+// columns default to WatchColumns of the initial rows; JSON lines carry the
+// whole row unless --columns was given.
+func watchRows(appCtx *AppContext, entityName string, clauses []FilterClause, columnsFlag string, initial func() ([]map[string]interface{}, error)) error {
+	var columns []string
+	if columnsFlag != "" {
+		columns = splitCSV(columnsFlag)
+	}
+	var header []string
+	ready := func() error {
+		rows, err := initial()
+		if err != nil {
+			return err
+		}
+		header = append([]string{"event"}, WatchColumns(columnsFlag, rows)...)
+		return nil
+	}
+	headerPrinted := false
+	return watchEntity(appCtx, entityName, clauses, ready, func(action string, row map[string]interface{}) (bool, error) {
+		switch {
+		case appCtx.Quiet:
+			fmt.Printf("%s %v\n", action, row["reference_id"])
+		case appCtx.StructuredOutput():
+			line, err := json.Marshal(map[string]interface{}{"event": action, "row": WatchRow(row, columns)})
+			if err != nil {
+				return false, err
+			}
+			fmt.Println(string(line))
+		default:
+			if !headerPrinted {
+				fmt.Println(EventTableHeader(header))
+				headerPrinted = true
+			}
+			cells := WatchRow(row, header[1:])
+			cells["event"] = action
+			fmt.Println(EventTableRow(cells, header))
+		}
+		return false, nil
+	})
+}
+
+// WatchChange extracts the event name and row from a table event. ok is
+// false for anything that is not a create, update or delete.
+// Pure function.
+func WatchChange(event map[string]interface{}) (action string, row map[string]interface{}, ok bool) {
+	action, _ = event["event"].(string)
+	switch action {
+	case "create", "update", "delete":
+	default:
+		return "", nil, false
+	}
+	message, _ := event["message"].(map[string]interface{})
+	row, _ = message["event_data"].(map[string]interface{})
+	if row == nil {
+		row = map[string]interface{}{}
+	}
+	return action, row, true
+}
+
+// WatchRow returns a copy of the row restricted to columns (all columns
+// when empty).
+// Pure function.
+func WatchRow(row map[string]interface{}, columns []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(row)+1)
+	if len(columns) == 0 {
+		for key, value := range row {
+			result[key] = value
+		}
+	} else {
+		for _, column := range columns {
+			result[column] = row[column]
+		}
+	}
+	return result
+}
+
+// WatchColumns picks the columns for streamed list changes: the --columns
+// value when given, otherwise reference_id plus the first of
+// name/title/label/email present in the initial rows.
+// Pure function.
+func WatchColumns(columnsFlag string, rows []map[string]interface{}) []string {
+	if columnsFlag != "" {
+		return splitCSV(columnsFlag)
+	}
+	columns := []string{"reference_id"}
+	for _, key := range []string{"name", "title", "label", "email"} {
+		for _, row := range rows {
+			if _, ok := row[key]; ok {
+				return append(columns, key)
+			}
+		}
+	}
+	return columns
+}
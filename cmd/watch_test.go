@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWatchChange(t *testing.T) {
+	action, row, ok := WatchChange(testWSEvent())
+	if !ok || action != "update" || row["reference_id"] != "d1" {
+		t.Fatalf("unexpected change: %v %v %v", action, row, ok)
+	}
+	if _, _, ok := WatchChange(map[string]interface{}{"type": "response", "ok": true}); ok {
+		t.Fatal("expected responses to be ignored")
+	}
+	action, row, ok = WatchChange(map[string]interface{}{"event": "delete"})
+	if !ok || action != "delete" || len(row) != 0 {
+		t.Fatalf("expected empty row for delete without data: %v %v %v", action, row, ok)
+	}
+}
+
+func TestWatchRow(t *testing.T) {
+	row := map[string]interface{}{"reference_id": "d1", "name": "a", "price": 3}
+	got := WatchRow(row, []string{"reference_id", "missing"})
+	expected := map[string]interface{}{"reference_id": "d1", "missing": nil}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	all := WatchRow(row, nil)
+	if !reflect.DeepEqual(all, row) {
+		t.Fatalf("unexpected full row: %v", all)
+	}
+	all["name"] = "b"
+	if row["name"] != "a" {
+		t.Fatal("WatchRow must return a copy")
+	}
+}
+
+func TestWatchColumns(t *testing.T) {
+	if got := WatchColumns("name, price", nil); !reflect.DeepEqual(got, []string{"name", "price"}) {
+		t.Fatalf("unexpected columns from flag: %v", got)
+	}
+	rows := []map[string]interface{}{{"reference_id": "1", "title": "t", "email": "e"}}
+	if got := WatchColumns("", rows); !reflect.DeepEqual(got, []string{"reference_id", "title"}) {
+		t.Fatalf("unexpected default columns: %v", got)
+	}
+	if got := WatchColumns("", nil); !reflect.DeepEqual(got, []string{"reference_id"}) {
+		t.Fatalf("unexpected columns without rows: %v", got)
+	}
+}
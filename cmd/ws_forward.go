@@ -0,0 +1,435 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+func wsForwardCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "forward",
+		Usage:     "Subscribe to topics and POST each event (or batch) to an HTTP endpoint",
+		ArgsUsage: "[topic ...]",
+		UsageText: `daptin ws forward [topic ...] --to <url> [flags]
+   daptin ws forward document --to http://localhost:8080/hook
+   daptin ws forward document user_account --to http://localhost:8080/hook --secret "$HOOK_SECRET"
+   daptin ws forward document --to http://localhost:8080/hook --batch-size 50 --batch-interval 2s
+   daptin ws forward document --to http://localhost:8080/hook --filter event=create --dead-letter failed.ndjson`,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:     "to",
+				Usage:    "URL to POST events to",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "secret",
+				Usage:   "Sign each request with HMAC-SHA256 using this key",
+				EnvVars: []string{"DAPTIN_FORWARD_SECRET"},
+			},
+			&cli.StringSliceFlag{
+				Name:  "header",
+				Usage: "Extra request header as \"Name: value\" (repeatable)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "filter",
+				Usage: "Filter expression, e.g. event=create (repeatable)",
+			},
+			&cli.IntFlag{
+				Name:  "batch-size",
+				Usage: "Events per request; above 1 the body is a JSON array",
+				Value: 1,
+			},
+			&cli.DurationFlag{
+				Name:  "batch-interval",
+				Usage: "Send a partial batch after this long",
+				Value: time.Second,
+			},
+			&cli.IntFlag{
+				Name:  "retries",
+				Usage: "Retries per request on network errors, 429 and 5xx",
+				Value: 3,
+			},
+			&cli.DurationFlag{
+				Name:  "retry-delay",
+				Usage: "Initial retry delay, doubled per attempt up to 30s",
+				Value: time.Second,
+			},
+			&cli.DurationFlag{
+				Name:  "request-timeout",
+				Usage: "Timeout for each HTTP request",
+				Value: 10 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:  "dead-letter",
+				Usage: "Append batches that could not be delivered to this NDJSON `FILE`",
+			},
+			&cli.IntFlag{
+				Name:  "max-backlog",
+				Usage: "Events held in memory while the endpoint is slow or down; later events are dropped (0 for no limit)",
+				Value: 100000,
+			},
+		}, wsReconnectFlags()...),
+		Action: func(c *cli.Context) error {
+			target, err := url.Parse(c.String("to"))
+			if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+				return fmt.Errorf("--to must be an http(s) URL, got %q", c.String("to"))
+			}
+			if c.Int("batch-size") < 1 {
+				return fmt.Errorf("--batch-size must be at least 1")
+			}
+			if c.Duration("batch-interval") <= 0 {
+				return fmt.Errorf("--batch-interval must be positive")
+			}
+			if c.Int("retries") < 0 {
+				return fmt.Errorf("--retries must not be negative")
+			}
+			if c.Int("max-backlog") < 0 {
+				return fmt.Errorf("--max-backlog must not be negative")
+			}
+			headers, err := ParseHeaders(c.StringSlice("header"))
+			if err != nil {
+				return err
+			}
+			var clauses []FilterClause
+			for _, expr := range c.StringSlice("filter") {
+				parsed, err := ParseFilter(expr)
+				if err != nil {
+					return err
+				}
+				clauses = append(clauses, parsed...)
+			}
+			serverFilters, _ := SplitWSFilters(clauses)
+			topics := c.Args().Slice()
+			slog.Info("ws forward", "topics", topics, "to", target.Redacted(), "batch_size", c.Int("batch-size"))
+
+			fw := &webhookForwarder{
+				url:        target.String(),
+				secret:     c.String("secret"),
+				headers:    headers,
+				retries:    c.Int("retries"),
+				retryDelay: c.Duration("retry-delay"),
+				deadLetter: c.String("dead-letter"),
+				http:       &http.Client{Timeout: c.Duration("request-timeout")},
+			}
+
+			ws, err := client.DialReconnecting(appCtx.Client.Endpoint, appCtx.Client.AuthToken, wsReconnectOptions(c))
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+			for _, topic := range topics {
+				if err := ws.Subscribe(topic, serverFilters); err != nil {
+					return err
+				}
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Forwarding %s to %s (Ctrl-C to stop)\n", describeTopics(topics), target.Redacted())
+			}
+
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt)
+			defer signal.Stop(sigCh)
+			go func() {
+				if _, ok := <-sigCh; ok {
+					ws.Unsubscribe()
+					ws.Close()
+				}
+			}()
+
+			// The reader feeds a single sender so requests go out in event
+			// order. Up to --max-backlog events queue in memory while a batch
+			// is retried, so the reader keeps draining the socket.
+			events := make(chan map[string]interface{}, 1024)
+			readErr := make(chan error, 1)
+			go func() {
+				defer close(events)
+				for {
+					msg, err := ws.Next()
+					if err != nil {
+						if !errors.Is(err, client.ErrWSClosed) {
+							readErr <- err
+						}
+						return
+					}
+					if msg["type"] == "reconnected" {
+						if !appCtx.Quiet {
+							fmt.Fprintf(os.Stderr, "Reconnected (attempt %v)\n", msg["attempt"])
+						}
+						continue
+					}
+					// Every clause is re-checked here; the server only applies equality filters
+					if msg["type"] == "response" || !MatchEventFilters(msg, clauses) {
+						continue
+					}
+					events <- msg
+				}
+			}()
+
+			fw.run(queueEvents(events, c.Int("max-backlog"), fw.overflow), c.Int("batch-size"), c.Duration("batch-interval"))
+			lost := ws.Dropped()
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Forwarded %d events in %d requests, %d events failed, %d dropped\n",
+					fw.forwarded, fw.requests, fw.failed, fw.dropped+int(lost))
+			}
+			select {
+			case err := <-readErr:
+				return err
+			default:
+			}
+			if lost > 0 {
+				// These never reached the forwarder, so they are not in the
+				// dead-letter file either
+				return fmt.Errorf("%d events were dropped before they could be forwarded", lost)
+			}
+			if (fw.failed > 0 || fw.dropped > 0) && fw.deadLetter == "" {
+				return fmt.Errorf("%d events could not be delivered", fw.failed+fw.dropped)
+			}
+			return nil
+		},
+	}
+}
+
+// webhookForwarder batches events and POSTs them with retries.
+type webhookForwarder struct {
+	url        string
+	secret     string
+	headers    http.Header
+	retries    int
+	retryDelay time.Duration
+	deadLetter string
+	http       *http.Client
+
+	forwarded int
+	requests  int
+	failed    int
+
+	// mu serializes dead-letter writes and guards dropped, which the
+	// backlog updates from its own goroutine
+	mu      sync.Mutex
+	dropped int
+}
+
+// queueEvents relays in to the returned channel, holding up to max events
+// (no limit when max is 0) in memory so a slow webhook never blocks the
+// sender of in. Events arriving while the backlog is full go to overflow.
+func queueEvents(in <-chan map[string]interface{}, max int, overflow func(map[string]interface{})) <-chan map[string]interface{} {
+	out := make(chan map[string]interface{})
+	go func() {
+		defer close(out)
+		var backlog []map[string]interface{}
+		for in != nil || len(backlog) > 0 {
+			// A nil channel disables the send case while the backlog is empty
+			var send chan map[string]interface{}
+			var next map[string]interface{}
+			if len(backlog) > 0 {
+				send, next = out, backlog[0]
+			}
+			select {
+			case msg, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				if max > 0 && len(backlog) >= max {
+					overflow(msg)
+					continue
+				}
+				backlog = append(backlog, msg)
+			case send <- next:
+				backlog[0] = nil
+				backlog = backlog[1:]
+			}
+		}
+	}()
+	return out
+}
+
+// run sends batches until events is closed, flushing a partial batch after
+// interval and whatever is left at the end.
+func (f *webhookForwarder) run(events <-chan map[string]interface{}, batchSize int, interval time.Duration) {
+	var batch []map[string]interface{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	flush := func() {
+		if len(batch) > 0 {
+			f.deliver(batch)
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (f *webhookForwarder) deliver(batch []map[string]interface{}) {
+	body, err := ForwardBody(batch)
+	if err == nil {
+		err = f.post(body, len(batch))
+	}
+	f.requests++
+	if err == nil {
+		f.forwarded += len(batch)
+		return
+	}
+	f.failed += len(batch)
+	slog.Warn("ws forward failed", "events", len(batch), "error", err)
+	fmt.Fprintf(os.Stderr, "forward failed (%d events): %v\n", len(batch), err)
+	f.writeDeadLetter(batch, err)
+}
+
+// overflow records an event the backlog had no room for.
+func (f *webhookForwarder) overflow(event map[string]interface{}) {
+	f.mu.Lock()
+	f.dropped++
+	first := f.dropped == 1
+	f.mu.Unlock()
+	if first {
+		slog.Warn("ws forward backlog full, dropping events until it drains")
+	}
+	f.writeDeadLetter([]map[string]interface{}{event}, fmt.Errorf("backlog full"))
+}
+
+func (f *webhookForwarder) writeDeadLetter(batch []map[string]interface{}, cause error) {
+	if f.deadLetter == "" {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := appendDeadLetter(f.deadLetter, batch, cause); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write dead letter: %v\n", err)
+	}
+}
+
+// post sends one body, retrying network errors, 429 and 5xx with backoff.
+func (f *webhookForwarder) post(body []byte, count int) error {
+	var lastErr error
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			delay := client.BackoffDelay(attempt, f.retryDelay, 30*time.Second)
+			slog.Info("ws forward retry", "attempt", attempt, "delay", delay, "error", lastErr)
+			time.Sleep(delay)
+		}
+		req, err := http.NewRequest(http.MethodPost, f.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for name, values := range f.headers {
+			req.Header[name] = values
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Daptin-Event-Count", strconv.Itoa(count))
+		req.Header.Set("X-Daptin-Timestamp", timestamp)
+		if f.secret != "" {
+			req.Header.Set("X-Daptin-Signature", SignPayload(f.secret, timestamp, body))
+		}
+
+		resp, err := f.http.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(snippet)))
+		if !RetryableStatus(resp.StatusCode) {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", f.retries+1, lastErr)
+}
+
+// DeadLetterEntry is one line of the --dead-letter file.
+type DeadLetterEntry struct {
+	Time   time.Time                `json:"ts"`
+	Error  string                   `json:"error"`
+	Events []map[string]interface{} `json:"events"`
+}
+
+func appendDeadLetter(path string, batch []map[string]interface{}, cause error) error {
+	line, err := json.Marshal(DeadLetterEntry{Time: time.Now().UTC(), Error: cause.Error(), Events: batch})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// ForwardBody is the request body: the event itself for a batch of one,
+// otherwise a JSON array of events.
+// Pure function.
+func ForwardBody(batch []map[string]interface{}) ([]byte, error) {
+	if len(batch) == 1 {
+		return json.Marshal(batch[0])
+	}
+	return json.Marshal(batch)
+}
+
+// SignPayload returns "sha256=<hex>" of HMAC-SHA256 over "timestamp.body",
+// so receivers can reject replayed requests by checking the timestamp.
+// Pure function.
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryableStatus reports whether a response status is worth retrying.
+// Pure function.
+func RetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// ParseHeaders parses "Name: value" pairs.
+// Pure function.
+func ParseHeaders(values []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", value)
+		}
+		headers.Add(name, strings.TrimSpace(v))
+	}
+	return headers, nil
+}
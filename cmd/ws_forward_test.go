@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSignPayload(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(`1700000000.{"a":1}`))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := SignPayload("secret", "1700000000", body); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+	if SignPayload("other", "1700000000", body) == expected {
+		t.Fatal("signature must depend on the secret")
+	}
+}
+
+func TestForwardBody(t *testing.T) {
+	one, _ := ForwardBody([]map[string]interface{}{{"a": 1}})
+	if string(one) != `{"a":1}` {
+		t.Fatalf("unexpected single body %s", one)
+	}
+	many, _ := ForwardBody([]map[string]interface{}{{"a": 1}, {"b": 2}})
+	if string(many) != `[{"a":1},{"b":2}]` {
+		t.Fatalf("unexpected batch body %s", many)
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	for code, expected := range map[int]bool{200: false, 400: false, 404: false, 408: true, 429: true, 500: true, 503: true} {
+		if RetryableStatus(code) != expected {
+			t.Errorf("RetryableStatus(%d): expected %v", code, expected)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"X-Token: abc", "x-multi:1", "X-Multi: 2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers.Get("X-Token") != "abc" || len(headers.Values("X-Multi")) != 2 {
+		t.Fatalf("unexpected headers %v", headers)
+	}
+	if _, err := ParseHeaders([]string{"no-colon"}); err == nil {
+		t.Fatal("expected error for header without colon")
+	}
+}
+
+func TestWebhookForwarderRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Daptin-Signature") != SignPayload("k", r.Header.Get("X-Daptin-Timestamp"), []byte(`{"n":1}`)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	fw := &webhookForwarder{url: server.URL, secret: "k", retries: 2, retryDelay: time.Millisecond, http: server.Client()}
+	fw.deliver([]map[string]interface{}{{"n": 1}})
+	if fw.forwarded != 1 || fw.failed != 0 || calls.Load() != 2 {
+		t.Fatalf("expected delivery on retry, got forwarded=%d failed=%d calls=%d", fw.forwarded, fw.failed, calls.Load())
+	}
+}
+
+func TestWebhookForwarderNoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	fw := &webhookForwarder{url: server.URL, retries: 3, retryDelay: time.Millisecond, http: server.Client()}
+	fw.deliver([]map[string]interface{}{{"n": 1}, {"n": 2}})
+	if fw.failed != 2 || calls.Load() != 1 {
+		t.Fatalf("expected one attempt and 2 failed events, got failed=%d calls=%d", fw.failed, calls.Load())
+	}
+}
+
+func TestQueueEventsNeverBlocksSender(t *testing.T) {
+	in := make(chan map[string]interface{})
+	out := queueEvents(in, 0, nil)
+	// Nothing reads out yet; every send must still go through
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 5000; i++ {
+			in <- map[string]interface{}{"n": i}
+		}
+		close(in)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("sender blocked while the consumer was idle")
+	}
+	n := 0
+	for msg := range out {
+		if msg["n"] != n {
+			t.Fatalf("expected event %d, got %v", n, msg["n"])
+		}
+		n++
+	}
+	if n != 5000 {
+		t.Fatalf("expected 5000 events, got %d", n)
+	}
+}
+
+func TestQueueEventsOverflow(t *testing.T) {
+	in := make(chan map[string]interface{})
+	var overflowed []interface{}
+	out := queueEvents(in, 2, func(event map[string]interface{}) {
+		overflowed = append(overflowed, event["n"])
+	})
+	for i := 0; i < 5; i++ {
+		in <- map[string]interface{}{"n": i}
+	}
+	close(in)
+	var kept []interface{}
+	for msg := range out {
+		kept = append(kept, msg["n"])
+	}
+	if !reflect.DeepEqual(kept, []interface{}{0, 1}) || !reflect.DeepEqual(overflowed, []interface{}{2, 3, 4}) {
+		t.Fatalf("expected 0,1 kept and 2,3,4 overflowed, got %v and %v", kept, overflowed)
+	}
+}
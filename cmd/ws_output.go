@@ -0,0 +1,517 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+// wsOutputFlags are shared by ws listen and ws subscribe.
+func wsOutputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Event format: ndjson, table, or template",
+			Value:   "ndjson",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "Go template for --output template, e.g. '{{.topic}} {{.message.event_data.reference_id}}'",
+		},
+		&cli.StringFlag{
+			Name:  "columns",
+			Usage: "Comma-separated keys for --output table (default: keys of the first event)",
+		},
+		&cli.StringFlag{
+			Name:  "select",
+			Usage: "jq-like path printed instead of the whole event, e.g. .message.event_data",
+		},
+		&cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: "Filter expression, e.g. event=create or \"price more than 10\" (repeatable)",
+		},
+		&cli.IntFlag{
+			Name:  "count",
+			Usage: "Exit after N matching events",
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Exit after this duration; with --count, fail if fewer events arrived",
+		},
+		&cli.StringFlag{
+			Name:  "exec",
+			Usage: "Run `COMMAND` via sh -c for each event, with the event JSON on stdin",
+		},
+	}
+}
+
+// wsEventSink filters, formats and emits events according to the output flags.
+type wsEventSink struct {
+	format   string
+	tmpl     *template.Template
+	columns  []string
+	selector string
+	filters  []FilterClause
+	exec     string
+	count    int
+
+	seen          int
+	headerPrinted bool
+}
+
+func newWSEventSink(c *cli.Context) (*wsEventSink, error) {
+	sink := &wsEventSink{
+		format:   c.String("output"),
+		selector: c.String("select"),
+		exec:     c.String("exec"),
+		count:    c.Int("count"),
+	}
+	switch sink.format {
+	case "ndjson", "table":
+	case "template":
+		text := c.String("template")
+		if text == "" {
+			return nil, fmt.Errorf("--output template requires --template")
+		}
+		tmpl, err := template.New("event").Funcs(template.FuncMap{"json": templateJSON}).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --template: %w", err)
+		}
+		sink.tmpl = tmpl
+	default:
+		return nil, fmt.Errorf("unknown event output %q, expected ndjson, table, or template", sink.format)
+	}
+	if cols := c.String("columns"); cols != "" {
+		sink.columns = splitCSV(cols)
+	}
+	if sink.selector != "" {
+		if _, _, err := SelectPath(nil, sink.selector); err != nil {
+			return nil, err
+		}
+	}
+	for _, expr := range c.StringSlice("filter") {
+		clauses, err := ParseFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		sink.filters = append(sink.filters, clauses...)
+	}
+	return sink, nil
+}
+
+// serverFilters returns the equality filters Daptin can apply itself.
+func (s *wsEventSink) serverFilters() map[string]interface{} {
+	filters, _ := SplitWSFilters(s.filters)
+	return filters
+}
+
+// handle emits one event; it reports done once --count events were emitted.
+func (s *wsEventSink) handle(msg map[string]interface{}) (bool, error) {
+	if msg["type"] == "reconnected" {
+		// Markers only belong in raw streams; table/template users see the stderr note
+		if s.format == "ndjson" && s.selector == "" && s.exec == "" {
+			line, err := client.EventToJSONLine(msg)
+			if err == nil {
+				fmt.Println(line)
+			}
+		}
+		return false, nil
+	}
+	// Every clause is re-checked here; the server only applies equality filters
+	if !MatchEventFilters(msg, s.filters) {
+		slog.Debug("ws event filtered out", "type", msg["type"])
+		return false, nil
+	}
+
+	var value interface{} = msg
+	if s.selector != "" {
+		selected, ok, err := SelectPath(msg, s.selector)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			slog.Debug("ws event has no value at selector", "select", s.selector)
+			return false, nil
+		}
+		value = selected
+	}
+
+	if err := s.emit(value); err != nil {
+		return false, err
+	}
+	s.seen++
+	return s.count > 0 && s.seen >= s.count, nil
+}
+
+func (s *wsEventSink) emit(value interface{}) error {
+	if s.exec != "" {
+		return runEventCommand(s.exec, value)
+	}
+	switch s.format {
+	case "table":
+		row, _ := value.(map[string]interface{})
+		if row == nil {
+			row = map[string]interface{}{"value": value}
+		}
+		if s.columns == nil {
+			s.columns = sortedKeys(row)
+		}
+		if !s.headerPrinted {
+			fmt.Println(EventTableHeader(s.columns))
+			s.headerPrinted = true
+		}
+		fmt.Println(EventTableRow(row, s.columns))
+		return nil
+	case "template":
+		var buf bytes.Buffer
+		if err := s.tmpl.Execute(&buf, value); err != nil {
+			return fmt.Errorf("render template: %w", err)
+		}
+		fmt.Println(strings.TrimRight(buf.String(), "\n"))
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// runEventCommand runs command through sh -c with the event JSON on stdin.
+// A failing command is reported but does not stop the stream.
+func runEventCommand(command string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if event, ok := value.(map[string]interface{}); ok {
+		cmd.Env = append(os.Environ(),
+			"DAPTIN_EVENT_TYPE="+fmt.Sprint(lookupEventField(event, "event")),
+			"DAPTIN_EVENT_TOPIC="+fmt.Sprint(lookupEventField(event, "topic")),
+		)
+	}
+	if err := cmd.Run(); err != nil {
+		slog.Warn("ws exec command failed", "command", command, "error", err)
+		fmt.Fprintf(os.Stderr, "exec failed: %v\n", err)
+	}
+	return nil
+}
+
+// consumeWSEvents feeds events to the sink until --count or --timeout is
+// reached or the connection is lost for good.
+func consumeWSEvents(appCtx *AppContext, ws *client.ReconnectingWS, sink *wsEventSink, timeout time.Duration) error {
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			ws.Close()
+		})
+		defer timer.Stop()
+	}
+	for {
+		msg, err := ws.Next()
+		if err != nil {
+			if errors.Is(err, client.ErrWSClosed) {
+				if timedOut.Load() && sink.count > 0 && sink.seen < sink.count {
+					return fmt.Errorf("timed out after %s with %d of %d events", timeout, sink.seen, sink.count)
+				}
+				return nil
+			}
+			return err
+		}
+		if msg["type"] == "reconnected" && !appCtx.Quiet {
+			fmt.Fprintf(os.Stderr, "Reconnected (attempt %v)\n", msg["attempt"])
+		}
+		slog.Debug("ws event", "type", msg["type"])
+		done, err := sink.handle(msg)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// SplitWSFilters separates filter clauses Daptin can evaluate in a
+// subscription (equality, sent as typed values) from the rest.
+// Pure function.
+func SplitWSFilters(clauses []FilterClause) (map[string]interface{}, []FilterClause) {
+	var server map[string]interface{}
+	var local []FilterClause
+	for _, clause := range clauses {
+		if (clause.Operator == "is" || clause.Operator == "eq") && !strings.HasPrefix(clause.Column, ".") {
+			if server == nil {
+				server = map[string]interface{}{}
+			}
+			server[clause.Column] = typedFilterValue(clause.Value)
+			continue
+		}
+		local = append(local, clause)
+	}
+	return server, local
+}
+
+// typedFilterValue turns "true", "42" or "null" into the matching JSON value
+// so numeric and boolean columns compare equal on the server.
+func typedFilterValue(value string) interface{} {
+	var typed interface{}
+	if err := json.Unmarshal([]byte(value), &typed); err == nil {
+		switch typed.(type) {
+		case bool, float64, nil:
+			return typed
+		}
+	}
+	return value
+}
+
+// MatchEventFilters reports whether an event satisfies every clause. A
+// column starting with "." is a path from the event root; a bare column is
+// looked up in message.event_data first and then at the event root.
+// Pure function.
+func MatchEventFilters(event map[string]interface{}, clauses []FilterClause) bool {
+	for _, clause := range clauses {
+		if !matchEventClause(lookupEventField(event, clause.Column), clause) {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupEventField(event map[string]interface{}, column string) interface{} {
+	if strings.HasPrefix(column, ".") {
+		value, _, _ := SelectPath(event, column)
+		return value
+	}
+	if message, ok := event["message"].(map[string]interface{}); ok {
+		if data, ok := message["event_data"].(map[string]interface{}); ok {
+			if value, ok := data[column]; ok {
+				return value
+			}
+		}
+		if value, ok := message[column]; ok {
+			return value
+		}
+	}
+	return event[column]
+}
+
+func matchEventClause(actual interface{}, clause FilterClause) bool {
+	text := ""
+	if actual != nil {
+		text = fmt.Sprint(actual)
+	}
+	want := clause.Value
+	switch clause.Operator {
+	case "is", "eq":
+		return text == want
+	case "is not", "neq":
+		return text != want
+	case "contains":
+		return strings.Contains(text, want)
+	case "fuzzy":
+		return strings.Contains(strings.ToLower(text), strings.ToLower(want))
+	case "begins with":
+		return strings.HasPrefix(text, want)
+	case "ends with":
+		return strings.HasSuffix(text, want)
+	case "like":
+		return likeMatch(text, want)
+	case "ilike":
+		return likeMatch(strings.ToLower(text), strings.ToLower(want))
+	case "more than", "gt", "after":
+		return compareFilterValues(text, want) > 0
+	case "less than", "lt", "before":
+		return compareFilterValues(text, want) < 0
+	case "in":
+		for _, option := range splitCSV(want) {
+			if text == option {
+				return true
+			}
+		}
+		return false
+	case "is true":
+		return boolValue(actual)
+	case "is false":
+		return actual != nil && !boolValue(actual)
+	case "is empty":
+		return text == ""
+	}
+	slog.Warn("unsupported ws filter operator", "operator", clause.Operator)
+	return false
+}
+
+// compareFilterValues compares numerically when both sides are numbers and
+// lexically otherwise, which orders RFC 3339 timestamps correctly.
+func compareFilterValues(a, b string) int {
+	af, errA := strconv.ParseFloat(a, 64)
+	bf, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// likeMatch implements SQL LIKE with % and _ wildcards.
+func likeMatch(text, pattern string) bool {
+	if pattern == "" {
+		return text == ""
+	}
+	switch pattern[0] {
+	case '%':
+		for i := 0; i <= len(text); i++ {
+			if likeMatch(text[i:], pattern[1:]) {
+				return true
+			}
+		}
+		return false
+	case '_':
+		return text != "" && likeMatch(text[1:], pattern[1:])
+	}
+	return text != "" && text[0] == pattern[0] && likeMatch(text[1:], pattern[1:])
+}
+
+// SelectPath evaluates a jq-like path such as ".message.event_data",
+// ".items[0].name" or "." against a decoded JSON value. ok is false when the
+// path does not exist; err reports a malformed path.
+// Pure function.
+func SelectPath(value interface{}, path string) (interface{}, bool, error) {
+	path = strings.TrimSpace(path)
+	if !strings.HasPrefix(path, ".") {
+		return nil, false, fmt.Errorf("invalid selector %q: must start with '.'", path)
+	}
+	rest := path[1:]
+	current := value
+	found := true
+	for rest != "" {
+		var key string
+		index := -1
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, false, fmt.Errorf("invalid selector %q: missing ]", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if n, err := strconv.Atoi(inner); err == nil {
+				index = n
+			} else {
+				key = strings.Trim(inner, `"`)
+			}
+		case rest[0] == '.':
+			rest = rest[1:]
+			continue
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key = rest[:end]
+			rest = rest[end:]
+		}
+		if !found {
+			continue
+		}
+		if index >= 0 {
+			list, ok := current.([]interface{})
+			if !ok || index >= len(list) {
+				found = false
+				continue
+			}
+			current = list[index]
+			continue
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			found = false
+			continue
+		}
+		current, found = m[key]
+	}
+	if !found {
+		return nil, false, nil
+	}
+	return current, true, nil
+}
+
+// EventTableHeader and EventTableRow format fixed-width rows so a live
+// stream lines up without buffering.
+const eventColumnWidth = 24
+
+// EventTableHeader formats the header line for --output table.
+// Pure function.
+func EventTableHeader(columns []string) string {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = padCell(column)
+	}
+	return strings.TrimRight(strings.Join(cells, " "), " ")
+}
+
+// EventTableRow formats one event; nested values are shown as JSON.
+// Pure function.
+func EventTableRow(row map[string]interface{}, columns []string) string {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		value := row[column]
+		text := ""
+		switch v := value.(type) {
+		case nil:
+		case map[string]interface{}, []interface{}:
+			data, _ := json.Marshal(v)
+			text = string(data)
+		default:
+			text = fmt.Sprint(v)
+		}
+		cells[i] = padCell(text)
+	}
+	return strings.TrimRight(strings.Join(cells, " "), " ")
+}
+
+func padCell(text string) string {
+	text = strings.ReplaceAll(text, "\n", " ")
+	if len(text) > eventColumnWidth {
+		text = text[:eventColumnWidth-3] + "..."
+	}
+	return text + strings.Repeat(" ", eventColumnWidth-len(text))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func templateJSON(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
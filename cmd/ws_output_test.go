@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testWSEvent() map[string]interface{} {
+	return map[string]interface{}{
+		"type":  "event",
+		"topic": "document",
+		"event": "update",
+		"message": map[string]interface{}{
+			"event_data": map[string]interface{}{
+				"reference_id": "d1",
+				"name":         "Quarterly report",
+				"price":        float64(25),
+				"published":    true,
+			},
+			"tags": []interface{}{"a", "b"},
+		},
+	}
+}
+
+func TestSelectPath(t *testing.T) {
+	event := testWSEvent()
+	value, ok, err := SelectPath(event, ".message.event_data.name")
+	if err != nil || !ok || value != "Quarterly report" {
+		t.Fatalf("unexpected select result: %v %v %v", value, ok, err)
+	}
+	value, ok, _ = SelectPath(event, ".message.tags[1]")
+	if !ok || value != "b" {
+		t.Fatalf("unexpected index result: %v %v", value, ok)
+	}
+	value, ok, _ = SelectPath(event, ".")
+	if !ok || !reflect.DeepEqual(value, event) {
+		t.Fatal("expected identity selector to return the event")
+	}
+	if _, ok, _ = SelectPath(event, ".message.missing.deeper"); ok {
+		t.Fatal("expected missing path to report not found")
+	}
+	if _, _, err = SelectPath(event, "message"); err == nil {
+		t.Fatal("expected error for selector without leading dot")
+	}
+	if _, _, err = SelectPath(event, ".tags[1"); err == nil {
+		t.Fatal("expected error for unterminated index")
+	}
+}
+
+func TestSplitWSFilters(t *testing.T) {
+	clauses, err := ParseFilter("event=update;price more than 10;published is true;count is 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, local := SplitWSFilters(clauses)
+	expected := map[string]interface{}{"event": "update", "count": float64(3)}
+	if !reflect.DeepEqual(server, expected) {
+		t.Fatalf("unexpected server filters: %#v", server)
+	}
+	if len(local) != 2 {
+		t.Fatalf("expected 2 local clauses, got %#v", local)
+	}
+}
+
+func TestMatchEventFilters(t *testing.T) {
+	event := testWSEvent()
+	cases := []struct {
+		filter string
+		match  bool
+	}{
+		{"event=update", true},
+		{"event=create", false},
+		{"price more than 10", true},
+		{"price less than 10", false},
+		{"name like Quarter%", true},
+		{"name ilike %REPORT", true},
+		{"name begins with Annual", false},
+		{"published is true", true},
+		{"event in create,update", true},
+		{".message.tags[0] is a", true},
+		{"reference_id is d1;price gt 30", false},
+	}
+	for _, tc := range cases {
+		clauses, err := ParseFilter(tc.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.filter, err)
+		}
+		if got := MatchEventFilters(event, clauses); got != tc.match {
+			t.Errorf("%s: expected %v, got %v", tc.filter, tc.match, got)
+		}
+	}
+}
+
+func TestEventTableRow(t *testing.T) {
+	columns := []string{"topic", "message"}
+	header := EventTableHeader(columns)
+	if !strings.HasPrefix(header, "topic ") || !strings.HasSuffix(header, "message") {
+		t.Fatalf("unexpected header %q", header)
+	}
+	row := EventTableRow(testWSEvent(), columns)
+	if !strings.HasPrefix(row, "document") || !strings.HasSuffix(row, "...") {
+		t.Fatalf("unexpected row %q", row)
+	}
+	if len(strings.SplitN(row, " ", 2)[0]) != len("document") {
+		t.Fatalf("unexpected first cell in %q", row)
+	}
+}
@@ -0,0 +1,399 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+func wsPublishCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "publish",
+		Usage:     "Publish messages to a topic",
+		ArgsUsage: "<topic> [json-message | -]",
+		UsageText: `daptin ws publish <topic> <json-message> [flags]
+   daptin ws publish chat-room-1 '{"text":"hello"}'
+   daptin ws publish chat-room-1 --file events.ndjson --rate 50
+   cat events.ndjson | daptin ws publish chat-room-1 -
+   daptin ws publish bench-topic --bench --count 5000 --rate 1000`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "file",
+				Usage: "Publish each line of an NDJSON `FILE` (- for stdin)",
+			},
+			&cli.Float64Flag{
+				Name:  "rate",
+				Usage: "Maximum messages per second (0 = unlimited)",
+			},
+			&cli.BoolFlag{
+				Name:  "bench",
+				Usage: "Measure publish throughput and delivery latency through a second, subscribed connection",
+			},
+			&cli.IntFlag{
+				Name:  "count",
+				Usage: "Messages to send in --bench mode",
+				Value: 1000,
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "How long --bench waits for deliveries after the last publish",
+				Value: 10 * time.Second,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			topic := c.Args().Get(0)
+			if topic == "" {
+				return fmt.Errorf("usage: ws publish <topic> <json-message> (or --file, - for stdin, --bench)")
+			}
+			if c.Float64("rate") < 0 {
+				return fmt.Errorf("--rate must not be negative")
+			}
+			if c.Bool("bench") {
+				return wsPublishBench(appCtx, c, topic)
+			}
+
+			source := c.String("file")
+			if source == "" && c.Args().Get(1) == "-" {
+				source = "-"
+			}
+			if source != "" {
+				return wsPublishStream(appCtx, c, topic, source)
+			}
+
+			msgStr := c.Args().Get(1)
+			if msgStr == "" {
+				return fmt.Errorf("usage: ws publish <topic> <json-message> (or --file, - for stdin, --bench)")
+			}
+			slog.Info("ws publish", "topic", topic)
+
+			var msgPayload map[string]interface{}
+			if err := json.Unmarshal([]byte(msgStr), &msgPayload); err != nil {
+				return fmt.Errorf("invalid JSON message: %w", err)
+			}
+
+			ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+
+			id, err := ws.Send("new-message", map[string]interface{}{
+				"topicName": topic,
+				"message":   msgPayload,
+			})
+			if err != nil {
+				return err
+			}
+
+			// Server only responds on error; timeout means success
+			_, err = ws.WaitResponseTimeout(id, 2*time.Second)
+			if err != nil {
+				return fmt.Errorf("publish failed: %w", err)
+			}
+
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Published to %s\n", topic)
+			}
+			return nil
+		},
+	}
+}
+
+// wsPublishStream sends one message per input line over a single connection.
+// Error responses are matched back to their line through the request id.
+func wsPublishStream(appCtx *AppContext, c *cli.Context, topic, source string) error {
+	in := io.Reader(os.Stdin)
+	if source != "-" {
+		f, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	slog.Info("ws publish stream", "topic", topic, "source", source, "rate", c.Float64("rate"))
+
+	ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	var mu sync.Mutex
+	lineByID := map[string]int{}
+	failures := 0
+	reportFailure := func(line int, err interface{}) {
+		mu.Lock()
+		failures++
+		mu.Unlock()
+		fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+	}
+
+	// The server answers new-message only on error; collect those while sending
+	collectorDone := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer close(collectorDone)
+		for {
+			msg, err := ws.ReadMessageContext(ctx)
+			if err != nil {
+				return
+			}
+			id, _ := msg["id"].(string)
+			if msg["type"] != "response" || msg["ok"] != false {
+				continue
+			}
+			mu.Lock()
+			line := lineByID[id]
+			mu.Unlock()
+			reportFailure(line, msg["error"])
+		}
+	}()
+
+	limiter := newRateLimiter(c.Float64("rate"))
+	defer limiter.Stop()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line, sent := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &payload); err != nil {
+			reportFailure(line, fmt.Errorf("invalid JSON: %w", err))
+			continue
+		}
+		limiter.Wait()
+		// Hold the lock across send and record so a fast error response
+		// cannot be looked up before its line number is known
+		mu.Lock()
+		id, err := ws.SendNoWait("new-message", map[string]interface{}{
+			"topicName": topic,
+			"message":   payload,
+		})
+		if err == nil {
+			lineByID[id] = line
+		}
+		mu.Unlock()
+		if err != nil {
+			cancel()
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		sent++
+	}
+	if err := scanner.Err(); err != nil {
+		cancel()
+		return err
+	}
+
+	// Late error responses get a short grace period
+	time.AfterFunc(time.Second, cancel)
+	<-collectorDone
+
+	if !appCtx.Quiet {
+		fmt.Fprintf(os.Stderr, "Published %d messages to %s, %d failed\n", sent, topic, failures)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d messages failed", failures)
+	}
+	return nil
+}
+
+// wsPublishBench publishes --count sequenced messages while a second
+// connection subscribed to the topic timestamps their arrival.
+func wsPublishBench(appCtx *AppContext, c *cli.Context, topic string) error {
+	count := c.Int("count")
+	if count <= 0 {
+		return fmt.Errorf("--count must be positive")
+	}
+	slog.Info("ws publish bench", "topic", topic, "count", count, "rate", c.Float64("rate"))
+
+	subscriber, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+	if err != nil {
+		return err
+	}
+	defer subscriber.Close()
+	id, err := subscriber.Send("subscribe", map[string]interface{}{"topicName": topic})
+	if err != nil {
+		return err
+	}
+	if _, err := subscriber.WaitResponse(id, nil); err != nil {
+		return fmt.Errorf("subscribe to %s failed: %w", topic, err)
+	}
+
+	publisher, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+	if err != nil {
+		return err
+	}
+	defer publisher.Close()
+
+	// Receive concurrently so latency is measured while publishing
+	latencies := make([]time.Duration, 0, count)
+	seen := make(map[int]bool, count)
+	received := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer close(received)
+		for len(seen) < count {
+			msg, err := subscriber.ReadMessageContext(ctx)
+			if err != nil {
+				return
+			}
+			seq, sentAt, ok := BenchMarker(msg)
+			if !ok || seen[seq] {
+				continue
+			}
+			seen[seq] = true
+			latencies = append(latencies, time.Since(time.Unix(0, sentAt)))
+		}
+	}()
+
+	limiter := newRateLimiter(c.Float64("rate"))
+	defer limiter.Stop()
+	start := time.Now()
+	for seq := 0; seq < count; seq++ {
+		limiter.Wait()
+		if _, err := publisher.SendNoWait("new-message", map[string]interface{}{
+			"topicName": topic,
+			"message":   map[string]interface{}{"bench_seq": seq, "bench_ts": time.Now().UnixNano()},
+		}); err != nil {
+			return fmt.Errorf("publish %d: %w", seq, err)
+		}
+	}
+	publishDuration := time.Since(start)
+
+	select {
+	case <-received:
+	case <-time.After(c.Duration("timeout")):
+		cancel()
+		<-received
+	}
+	total := time.Since(start)
+
+	report := BenchReport(count, len(latencies), publishDuration, total, latencies)
+	if appCtx.StructuredOutput() {
+		return appCtx.Renderer.RenderObject(report)
+	}
+	fmt.Printf("Sent:        %d in %s (%.0f msg/s)\n", count, publishDuration.Round(time.Millisecond), report["publish_rate"])
+	fmt.Printf("Delivered:   %d/%d in %s (%.0f msg/s)\n", len(latencies), count, total.Round(time.Millisecond), report["delivery_rate"])
+	if len(latencies) > 0 {
+		fmt.Printf("Latency:     p50 %sms  p95 %sms  p99 %sms  max %sms\n",
+			report["latency_p50_ms"], report["latency_p95_ms"], report["latency_p99_ms"], report["latency_max_ms"])
+	}
+	if len(latencies) < count {
+		return fmt.Errorf("%d of %d messages were not delivered", count-len(latencies), count)
+	}
+	return nil
+}
+
+// BenchMarker extracts the sequence number and send time from a bench event.
+// The payload is looked for in the event's message and at its root.
+// Pure function.
+func BenchMarker(event map[string]interface{}) (int, int64, bool) {
+	candidates := []map[string]interface{}{event}
+	if message, ok := event["message"].(map[string]interface{}); ok {
+		candidates = append([]map[string]interface{}{message}, candidates...)
+	}
+	for _, candidate := range candidates {
+		seq, okSeq := candidate["bench_seq"].(float64)
+		ts, okTs := candidate["bench_ts"].(float64)
+		if okSeq && okTs {
+			return int(seq), int64(ts), true
+		}
+	}
+	return 0, 0, false
+}
+
+// Percentile returns the p-th percentile (0-100) of durations using the
+// nearest-rank method. durations need not be sorted.
+// Pure function.
+func Percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// BenchReport summarises a publish benchmark.
+// Pure function.
+func BenchReport(sent, delivered int, publishDuration, total time.Duration, latencies []time.Duration) map[string]interface{} {
+	report := map[string]interface{}{
+		"sent":             sent,
+		"delivered":        delivered,
+		"publish_duration": publishDuration.String(),
+		"publish_rate":     perSecond(sent, publishDuration),
+		"delivery_rate":    perSecond(delivered, total),
+	}
+	if len(latencies) > 0 {
+		report["latency_p50_ms"] = formatMillis(Percentile(latencies, 50))
+		report["latency_p95_ms"] = formatMillis(Percentile(latencies, 95))
+		report["latency_p99_ms"] = formatMillis(Percentile(latencies, 99))
+		report["latency_max_ms"] = formatMillis(Percentile(latencies, 100))
+	}
+	return report
+}
+
+func perSecond(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+func formatMillis(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d.Microseconds())/1000)
+}
+
+// rateLimiter spaces calls to Wait at most rate per second; rate 0, or a
+// rate too high to space by at least a nanosecond, never waits.
+type rateLimiter struct {
+	ticker *time.Ticker
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{ticker: time.NewTicker(interval)}
+}
+
+func (r *rateLimiter) Wait() {
+	if r.ticker != nil {
+		<-r.ticker.C
+	}
+}
+
+func (r *rateLimiter) Stop() {
+	if r.ticker != nil {
+		r.ticker.Stop()
+	}
+}
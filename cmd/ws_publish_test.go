@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	durations := []time.Duration{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+	cases := map[float64]time.Duration{0: 1, 50: 5, 95: 10, 100: 10, 10: 1, 11: 2}
+	for p, expected := range cases {
+		if got := Percentile(durations, p); got != expected {
+			t.Fatalf("p%v: expected %v, got %v", p, expected, got)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Fatalf("expected 0 for empty input, got %v", got)
+	}
+	if durations[0] != 5 {
+		t.Fatal("Percentile must not reorder its input")
+	}
+}
+
+func TestBenchMarker(t *testing.T) {
+	seq, ts, ok := BenchMarker(map[string]interface{}{
+		"type":    "event",
+		"message": map[string]interface{}{"bench_seq": float64(7), "bench_ts": float64(1700000000000000000)},
+	})
+	if !ok || seq != 7 || ts != 1700000000000000000 {
+		t.Fatalf("unexpected marker: %v %v %v", seq, ts, ok)
+	}
+	if _, _, ok := BenchMarker(map[string]interface{}{"type": "event"}); ok {
+		t.Fatal("expected no marker on a plain event")
+	}
+}
+
+func TestBenchReport(t *testing.T) {
+	report := BenchReport(100, 90, time.Second, 2*time.Second, []time.Duration{time.Millisecond, 3 * time.Millisecond})
+	if report["publish_rate"] != float64(100) || report["delivery_rate"] != float64(45) {
+		t.Fatalf("unexpected rates: %v", report)
+	}
+	if report["latency_max_ms"] != "3.0" || report["latency_p50_ms"] != "1.0" {
+		t.Fatalf("unexpected latencies: %v", report)
+	}
+	if _, ok := BenchReport(1, 0, time.Second, time.Second, nil)["latency_p50_ms"]; ok {
+		t.Fatal("expected no latency keys without deliveries")
+	}
+}
+
+func TestRateLimiterUnlimitedAboveNanosecondSpacing(t *testing.T) {
+	for _, rate := range []float64{0, 1e12} {
+		limiter := newRateLimiter(rate)
+		if limiter.ticker != nil {
+			t.Errorf("rate %g: expected no ticker", rate)
+		}
+		limiter.Wait()
+		limiter.Stop()
+	}
+	limiter := newRateLimiter(1e6)
+	defer limiter.Stop()
+	if limiter.ticker == nil {
+		t.Fatal("rate 1e6: expected a ticker")
+	}
+}
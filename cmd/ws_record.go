@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+// RecordedEvent is one line of a ws record session file.
+type RecordedEvent struct {
+	Time     time.Time              `json:"ts"`
+	OffsetMs int64                  `json:"offset_ms"`
+	Topic    string                 `json:"topic,omitempty"`
+	Event    map[string]interface{} `json:"event"`
+}
+
+func wsRecordCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "record",
+		Usage:     "Record events with timestamps to an NDJSON session file",
+		ArgsUsage: "[topic ...]",
+		UsageText: `daptin ws record --file session.ndjson [topic ...] [flags]
+   daptin ws record --file session.ndjson document
+   daptin ws record --file session.ndjson document user_account --timeout 5m
+   daptin ws record --file - document --count 10 > session.ndjson`,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Session `FILE` to write (- for stdout)",
+				Required: true,
+			},
+			&cli.BoolFlag{
+				Name:  "append",
+				Usage: "Append to the file instead of truncating it",
+			},
+			&cli.IntFlag{
+				Name:  "count",
+				Usage: "Stop after N events",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "Stop after this duration",
+			},
+		}, wsReconnectFlags()...),
+		Action: func(c *cli.Context) error {
+			topics := c.Args().Slice()
+			slog.Info("ws record", "topics", topics, "file", c.String("file"))
+
+			out := io.Writer(os.Stdout)
+			if path := c.String("file"); path != "-" {
+				flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+				if c.Bool("append") {
+					flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+				}
+				f, err := os.OpenFile(path, flags, 0o644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			}
+
+			ws, err := client.DialReconnecting(appCtx.Client.Endpoint, appCtx.Client.AuthToken, wsReconnectOptions(c))
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+			for _, topic := range topics {
+				if err := ws.Subscribe(topic, nil); err != nil {
+					return err
+				}
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Recording %s to %s (Ctrl-C to stop)\n", describeTopics(topics), c.String("file"))
+			}
+
+			// Ctrl-C ends the recording cleanly so the file is flushed and closed
+			sigCh := make(chan os.Signal, 1)
+			signal.Notify(sigCh, os.Interrupt)
+			defer signal.Stop(sigCh)
+			go func() {
+				if _, ok := <-sigCh; ok {
+					ws.Unsubscribe()
+					ws.Close()
+				}
+			}()
+			if timeout := c.Duration("timeout"); timeout > 0 {
+				timer := time.AfterFunc(timeout, func() { ws.Close() })
+				defer timer.Stop()
+			}
+
+			writer := bufio.NewWriter(out)
+			defer writer.Flush()
+			start := time.Now()
+			recorded := 0
+			for {
+				msg, err := ws.Next()
+				if err != nil {
+					if errors.Is(err, client.ErrWSClosed) {
+						break
+					}
+					return err
+				}
+				if msg["type"] == "reconnected" {
+					slog.Warn("recording may have a gap", "attempt", msg["attempt"])
+					continue
+				}
+				now := time.Now()
+				line, err := json.Marshal(RecordedEvent{
+					Time:     now.UTC(),
+					OffsetMs: now.Sub(start).Milliseconds(),
+					Topic:    eventTopic(msg),
+					Event:    msg,
+				})
+				if err != nil {
+					return err
+				}
+				writer.Write(append(line, '\n'))
+				// Flush per event so a killed recorder still leaves a usable file
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+				recorded++
+				if n := c.Int("count"); n > 0 && recorded >= n {
+					break
+				}
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Recorded %d events\n", recorded)
+			}
+			return nil
+		},
+	}
+}
+
+func wsReplayCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "Republish a recorded session through new-message",
+		UsageText: `daptin ws replay --file session.ndjson [flags]
+   daptin ws replay --file session.ndjson --topic dev-document
+   daptin ws replay --file session.ndjson --topic dev-document --speed 2x
+   daptin ws replay --file session.ndjson --topic dev-document --speed max`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "file",
+				Usage:    "Session `FILE` written by ws record (- for stdin)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "topic",
+				Usage: "Topic to publish to (default: each event's recorded topic)",
+			},
+			&cli.StringFlag{
+				Name:  "speed",
+				Usage: "Playback speed: 1x keeps the recorded timing, 2x is twice as fast, max sends without delay",
+				Value: "1x",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			speed, err := ParseReplaySpeed(c.String("speed"))
+			if err != nil {
+				return err
+			}
+			in := io.Reader(os.Stdin)
+			if path := c.String("file"); path != "-" {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			events, err := ReadRecordedEvents(in)
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				return fmt.Errorf("no events in %s", c.String("file"))
+			}
+			slog.Info("ws replay", "events", len(events), "topic", c.String("topic"), "speed", speed)
+
+			ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+
+			var previous int64
+			for i, event := range events {
+				if i > 0 {
+					time.Sleep(ReplayDelay(previous, event.OffsetMs, speed))
+				}
+				previous = event.OffsetMs
+
+				topic := c.String("topic")
+				if topic == "" {
+					topic = event.Topic
+				}
+				if topic == "" {
+					return fmt.Errorf("event %d has no recorded topic; pass --topic", i+1)
+				}
+				// The server only responds to new-message on error; those land in the inbox
+				if _, err := ws.SendNoWait("new-message", map[string]interface{}{
+					"topicName": topic,
+					"message":   ReplayPayload(event.Event),
+				}); err != nil {
+					return fmt.Errorf("publish event %d: %w", i+1, err)
+				}
+			}
+
+			// Give error responses a moment to arrive; unrelated traffic must not extend the wait
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			failures := 0
+			for {
+				msg, err := ws.ReadMessageContext(ctx)
+				if err != nil {
+					break
+				}
+				if msg["type"] == "response" && msg["ok"] == false {
+					failures++
+					fmt.Fprintf(os.Stderr, "publish failed: %v\n", msg["error"])
+				}
+			}
+			if failures > 0 {
+				return fmt.Errorf("%d of %d events failed to publish", failures, len(events))
+			}
+			if !appCtx.Quiet {
+				fmt.Fprintf(os.Stderr, "Replayed %d events\n", len(events))
+			}
+			return nil
+		},
+	}
+}
+
+// ReadRecordedEvents parses a session file, skipping blank lines.
+func ReadRecordedEvents(r io.Reader) ([]RecordedEvent, error) {
+	var events []RecordedEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var event RecordedEvent
+		if err := json.Unmarshal([]byte(text), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if event.Event == nil {
+			return nil, fmt.Errorf("line %d: missing event", line)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ParseReplaySpeed parses "1x", "2", "0.5x" or "max". Zero means no delay.
+// Pure function.
+func ParseReplaySpeed(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "max" || value == "0" || value == "0x" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(value, "x"), 64)
+	if err != nil || speed < 0 {
+		return 0, fmt.Errorf("invalid speed %q, expected e.g. 1x, 2x, 0.5x or max", value)
+	}
+	return speed, nil
+}
+
+// ReplayDelay is the wait between two recorded offsets at the given speed.
+// Pure function.
+func ReplayDelay(previousMs, currentMs int64, speed float64) time.Duration {
+	if speed <= 0 || currentMs <= previousMs {
+		return 0
+	}
+	return time.Duration(float64(time.Duration(currentMs-previousMs)*time.Millisecond) / speed)
+}
+
+// ReplayPayload picks what to republish: the event's message body when it
+// has one, so a replayed event looks like the original to subscribers.
+// Pure function.
+func ReplayPayload(event map[string]interface{}) interface{} {
+	if message, ok := event["message"]; ok && message != nil {
+		return message
+	}
+	return event
+}
+
+func eventTopic(event map[string]interface{}) string {
+	for _, key := range []string{"topic", "topicName"} {
+		if topic, ok := event[key].(string); ok {
+			return topic
+		}
+	}
+	return ""
+}
+
+func describeTopics(topics []string) string {
+	if len(topics) == 0 {
+		return "all events"
+	}
+	return strings.Join(topics, ", ")
+}
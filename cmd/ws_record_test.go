@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReplaySpeed(t *testing.T) {
+	cases := map[string]float64{"1x": 1, "2x": 2, "0.5x": 0.5, "3": 3, "max": 0, "MAX": 0}
+	for input, expected := range cases {
+		got, err := ParseReplaySpeed(input)
+		if err != nil || got != expected {
+			t.Fatalf("%s: expected %v, got %v (%v)", input, expected, got, err)
+		}
+	}
+	for _, input := range []string{"fast", "-1x", ""} {
+		if _, err := ParseReplaySpeed(input); err == nil {
+			t.Fatalf("%s: expected error", input)
+		}
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	if got := ReplayDelay(1000, 3000, 2); got != time.Second {
+		t.Fatalf("expected 1s at 2x, got %v", got)
+	}
+	if got := ReplayDelay(1000, 3000, 0); got != 0 {
+		t.Fatalf("expected no delay at max speed, got %v", got)
+	}
+	if got := ReplayDelay(3000, 1000, 1); got != 0 {
+		t.Fatalf("expected no delay for out-of-order offsets, got %v", got)
+	}
+}
+
+func TestReadRecordedEvents(t *testing.T) {
+	input := `{"ts":"2024-06-01T12:00:00Z","offset_ms":0,"topic":"document","event":{"topic":"document","message":{"a":1}}}
+
+{"ts":"2024-06-01T12:00:01Z","offset_ms":1000,"event":{"type":"event"}}
+`
+	events, err := ReadRecordedEvents(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Topic != "document" || events[1].OffsetMs != 1000 {
+		t.Fatalf("unexpected events: %#v", events)
+	}
+	if _, err := ReadRecordedEvents(strings.NewReader(`{"ts":"2024-06-01T12:00:00Z"}`)); err == nil {
+		t.Fatal("expected error for a line without an event")
+	}
+}
+
+func TestReplayPayload(t *testing.T) {
+	message := map[string]interface{}{"a": float64(1)}
+	if got := ReplayPayload(map[string]interface{}{"message": message}); got.(map[string]interface{})["a"] != float64(1) {
+		t.Fatalf("expected message body, got %v", got)
+	}
+	event := map[string]interface{}{"type": "event"}
+	if got := ReplayPayload(event); got.(map[string]interface{})["type"] != "event" {
+		t.Fatalf("expected whole event, got %v", got)
+	}
+}
@@ -0,0 +1,438 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+// TopicInfo describes one PubSub topic: a table topic that carries row
+// events, or a user topic created with ws topic create.
+type TopicInfo struct {
+	Name          string
+	Kind          string
+	Permission    int64
+	HasPermission bool
+}
+
+func wsTopicCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "topic",
+		Usage: "List, inspect and manage topics",
+		Subcommands: []*cli.Command{
+			wsTopicListCommand(appCtx),
+			wsTopicDescribeCommand(appCtx),
+			{
+				Name:      "create",
+				Usage:     "Create a user topic",
+				ArgsUsage: "<name>",
+				Action: func(c *cli.Context) error {
+					name := c.Args().Get(0)
+					if name == "" {
+						return fmt.Errorf("topic name required")
+					}
+					slog.Info("ws topic create", "name", name)
+
+					ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+					if err != nil {
+						return err
+					}
+					defer ws.Close()
+
+					_, err = topicRequest(ws, "create-topicName", map[string]interface{}{
+						"name": name,
+					})
+					if err != nil {
+						return fmt.Errorf("create topic failed: %w", err)
+					}
+
+					if !appCtx.Quiet {
+						fmt.Fprintf(os.Stderr, "Created topic %s\n", name)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete a user topic",
+				ArgsUsage: "<name>",
+				Action: func(c *cli.Context) error {
+					name := c.Args().Get(0)
+					if name == "" {
+						return fmt.Errorf("topic name required")
+					}
+					slog.Info("ws topic delete", "name", name)
+
+					ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+					if err != nil {
+						return err
+					}
+					defer ws.Close()
+
+					_, err = topicRequest(ws, "destroy-topicName", map[string]interface{}{
+						"name": name,
+					})
+					if err != nil {
+						return fmt.Errorf("delete topic failed: %w", err)
+					}
+
+					if !appCtx.Quiet {
+						fmt.Fprintf(os.Stderr, "Deleted topic %s\n", name)
+					}
+					return nil
+				},
+			},
+			{
+				Name:      "permission",
+				Usage:     "Get or set permission on a user topic",
+				ArgsUsage: "<name>",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:  "set",
+						Usage: "Permission bitmask to set",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					name := c.Args().Get(0)
+					if name == "" {
+						return fmt.Errorf("topic name required")
+					}
+
+					ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+					if err != nil {
+						return err
+					}
+					defer ws.Close()
+
+					if c.IsSet("set") {
+						perm := c.Int64("set")
+						_, err := topicRequest(ws, "set-topic-permission", map[string]interface{}{
+							"topicName":  name,
+							"permission": perm,
+						})
+						if err != nil {
+							return fmt.Errorf("set permission failed: %w", err)
+						}
+						if !appCtx.Quiet {
+							fmt.Fprintf(os.Stderr, "Set permission %d on topic %s\n", perm, name)
+						}
+						return nil
+					}
+
+					permission, err := getTopicPermission(ws, name)
+					if err != nil {
+						return fmt.Errorf("get permission failed: %w", err)
+					}
+					switch {
+					case appCtx.StructuredOutput():
+						return appCtx.Renderer.RenderObject(map[string]interface{}{
+							"topic":              name,
+							"permission":         permission,
+							"permission_decoded": DecodePermission(permission),
+						})
+					case appCtx.Quiet:
+						fmt.Println(permission)
+					default:
+						fmt.Printf("%d:\n%s", permission, FormatPermission(permission))
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func wsTopicListCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List table topics and user topics with their permissions",
+		UsageText: `daptin ws topic list [flags]
+   daptin ws topic list
+   daptin ws topic list --kind user
+   daptin ws topic list --filter "chat-*"
+   daptin -o json ws topic list`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "filter",
+				Usage: "Only show topics whose name contains this text or matches this glob",
+			},
+			&cli.StringFlag{
+				Name:  "kind",
+				Usage: "Only show topics of this kind: table or user",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			kind := c.String("kind")
+			if kind != "" && kind != "table" && kind != "user" {
+				return fmt.Errorf("--kind must be table or user, got %q", kind)
+			}
+			slog.Info("ws topic list", "filter", c.String("filter"), "kind", kind)
+
+			var topics []TopicInfo
+			var tables map[string]bool
+			if kind != "user" {
+				worlds, err := loadWorldSchemas(appCtx)
+				if err != nil {
+					return err
+				}
+				topics = TableTopics(worlds)
+				tables = make(map[string]bool, len(topics))
+				for _, topic := range topics {
+					tables[topic.Name] = true
+				}
+			}
+			if kind != "table" {
+				userTopics, err := listUserTopics(appCtx, tables)
+				if err != nil {
+					return err
+				}
+				topics = append(topics, userTopics...)
+			}
+
+			rows := TopicRows(topics, c.String("filter"))
+			slog.Debug("ws topic list results", "count", len(rows))
+			if appCtx.Quiet {
+				for _, row := range rows {
+					fmt.Println(row["topic"])
+				}
+				return nil
+			}
+			if len(rows) == 0 {
+				fmt.Println("No topics found")
+				return nil
+			}
+			return appCtx.Renderer.RenderArray(rows)
+		},
+	}
+}
+
+func wsTopicDescribeCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:      "describe",
+		Usage:     "Show a topic's kind and permission and check that it can be subscribed to",
+		ArgsUsage: "<name>",
+		UsageText: `daptin ws topic describe <name>
+   daptin ws topic describe document
+   daptin -o json ws topic describe chat-room-1`,
+		Action: func(c *cli.Context) error {
+			name := c.Args().Get(0)
+			if name == "" {
+				return fmt.Errorf("topic name required")
+			}
+			slog.Info("ws topic describe", "name", name)
+
+			worlds, err := loadWorldSchemas(appCtx)
+			if err != nil {
+				return err
+			}
+			ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+			if err != nil {
+				return err
+			}
+			defer ws.Close()
+
+			var topic *TopicInfo
+			for _, candidate := range TableTopics(worlds) {
+				if candidate.Name == name {
+					topic = &candidate
+					break
+				}
+			}
+			if topic == nil {
+				permission, err := getTopicPermission(ws, name)
+				if err != nil {
+					return fmt.Errorf("topic %q not found: %w", name, err)
+				}
+				topic = &TopicInfo{Name: name, Kind: "user", Permission: permission, HasPermission: true}
+			}
+
+			// Subscribing with this session shows whether the current user can receive events
+			subscribeErr := subscribeCheck(ws, name)
+			if subscribeErr != nil {
+				slog.Debug("ws topic subscribe check failed", "topic", name, "error", subscribeErr)
+			}
+
+			if appCtx.StructuredOutput() {
+				description := map[string]interface{}{
+					"topic":        topic.Name,
+					"kind":         topic.Kind,
+					"subscribable": subscribeErr == nil,
+				}
+				if topic.HasPermission {
+					description["permission"] = topic.Permission
+					description["permission_decoded"] = DecodePermission(topic.Permission)
+				}
+				if subscribeErr != nil {
+					description["subscribe_error"] = subscribeErr.Error()
+				}
+				return appCtx.Renderer.RenderObject(description)
+			}
+
+			fmt.Printf("Topic: %s\nKind: %s\n", topic.Name, topic.Kind)
+			if topic.HasPermission {
+				fmt.Printf("Permission: %d\n%s", topic.Permission, FormatPermission(topic.Permission))
+			}
+			if subscribeErr != nil {
+				fmt.Printf("Subscribe: FAIL (%v)\n", subscribeErr)
+			} else {
+				fmt.Println("Subscribe: OK")
+			}
+			return nil
+		},
+	}
+}
+
+// listUserTopics asks the server for its topics over /live and returns the
+// ones that are not table topics, each with its permission. Servers that do
+// not answer list-topicName yield an empty list with a warning.
+func listUserTopics(appCtx *AppContext, tables map[string]bool) ([]TopicInfo, error) {
+	ws, err := client.DialWebSocket(appCtx.Client.Endpoint, appCtx.Client.AuthToken)
+	if err != nil {
+		return nil, err
+	}
+	defer ws.Close()
+
+	id, err := ws.Send("list-topicName", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ws.WaitResponseTimeout(id, 5*time.Second)
+	if err != nil || resp == nil {
+		slog.Warn("server did not list user topics; showing table topics only", "error", err)
+		return nil, nil
+	}
+
+	var topics []TopicInfo
+	for _, name := range ParseTopicList(resp) {
+		if tables[name] {
+			continue
+		}
+		topic := TopicInfo{Name: name, Kind: "user"}
+		if permission, err := getTopicPermission(ws, name); err == nil {
+			topic.Permission, topic.HasPermission = permission, true
+		} else {
+			slog.Debug("topic permission unavailable", "topic", name, "error", err)
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// topicRequestTimeout bounds the wait for each topic request, so a server
+// that never answers fails the command instead of hanging it.
+const topicRequestTimeout = 10 * time.Second
+
+// topicRequest sends one method call and waits up to topicRequestTimeout
+// for its response.
+func topicRequest(ws *client.WSConn, method string, attrs map[string]interface{}) (map[string]interface{}, error) {
+	id, err := ws.Send(method, attrs)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ws.WaitResponseTimeout(id, topicRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("no response to %s after %s", method, topicRequestTimeout)
+	}
+	return resp, nil
+}
+
+func getTopicPermission(ws *client.WSConn, name string) (int64, error) {
+	resp, err := topicRequest(ws, "get-topic-permission", map[string]interface{}{
+		"topicName": name,
+	})
+	if err != nil {
+		return 0, err
+	}
+	data := client.DecodeResponseData(resp)
+	if p, ok := data["permission"].(float64); ok {
+		return int64(p), nil
+	}
+	return 0, fmt.Errorf("no permission in response")
+}
+
+func subscribeCheck(ws *client.WSConn, name string) error {
+	if _, err := topicRequest(ws, "subscribe", map[string]interface{}{"topicName": name}); err != nil {
+		return err
+	}
+	ws.SendNoWait("unsubscribe", map[string]interface{}{"topicName": name})
+	return nil
+}
+
+// ParseTopicList reads topic names from a list-topicName response, accepting
+// {"data":{"topics":[...]}}, {"data":[...]} or data encoded as a JSON string.
+// Pure function.
+func ParseTopicList(resp map[string]interface{}) []string {
+	var items []interface{}
+	if list, ok := resp["data"].([]interface{}); ok {
+		items = list
+	} else if list, ok := client.DecodeResponseData(resp)["topics"].([]interface{}); ok {
+		items = list
+	}
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		switch value := item.(type) {
+		case string:
+			names = append(names, value)
+		case map[string]interface{}:
+			if name, ok := value["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return uniqueStrings(names)
+}
+
+// TableTopics returns one table topic per world; row events for a table are
+// published on a topic named after it, governed by the world's permission.
+// Pure function.
+func TableTopics(worlds []worldSchema) []TopicInfo {
+	topics := make([]TopicInfo, 0, len(worlds))
+	for _, w := range worlds {
+		topic := TopicInfo{Name: w.TableName, Kind: "table"}
+		if value, ok := w.Attrs["permission"].(float64); ok {
+			topic.Permission, topic.HasPermission = int64(value), true
+		}
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+// TopicRows builds display rows sorted by kind then name, keeping topics whose
+// name matches filter (substring or glob).
+// Pure function.
+func TopicRows(topics []TopicInfo, filter string) []map[string]interface{} {
+	sorted := append([]TopicInfo(nil), topics...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Kind != sorted[j].Kind {
+			return sorted[i].Kind < sorted[j].Kind
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	rows := make([]map[string]interface{}, 0, len(sorted))
+	for _, topic := range sorted {
+		if !tableNameMatches(topic.Name, filter) {
+			continue
+		}
+		row := map[string]interface{}{
+			"topic":      topic.Name,
+			"kind":       topic.Kind,
+			"permission": "",
+			"access":     "",
+		}
+		if topic.HasPermission {
+			row["permission"] = topic.Permission
+			row["access"] = FormatPermissionInline(topic.Permission)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTopicList(t *testing.T) {
+	cases := []struct {
+		name string
+		resp map[string]interface{}
+	}{
+		{"object", map[string]interface{}{"data": map[string]interface{}{"topics": []interface{}{"b", "a", "b"}}}},
+		{"array", map[string]interface{}{"data": []interface{}{"b", map[string]interface{}{"name": "a"}}}},
+		{"encoded", map[string]interface{}{"data": `{"topics":["a","b"]}`}},
+	}
+	for _, tc := range cases {
+		if got := ParseTopicList(tc.resp); !reflect.DeepEqual(got, []string{"a", "b"}) {
+			t.Errorf("%s: expected [a b], got %v", tc.name, got)
+		}
+	}
+	if got := ParseTopicList(map[string]interface{}{"type": "response"}); len(got) != 0 {
+		t.Errorf("expected no topics, got %v", got)
+	}
+}
+
+func TestTableTopics(t *testing.T) {
+	topics := TableTopics([]worldSchema{
+		{TableName: "document", Attrs: map[string]interface{}{"permission": float64(561441)}},
+		{TableName: "note", Attrs: map[string]interface{}{}},
+	})
+	if len(topics) != 2 || topics[0].Kind != "table" || !topics[0].HasPermission || topics[0].Permission != 561441 {
+		t.Fatalf("unexpected topics: %+v", topics)
+	}
+	if topics[1].HasPermission {
+		t.Fatalf("expected no permission for note: %+v", topics[1])
+	}
+}
+
+func TestTopicRows(t *testing.T) {
+	rows := TopicRows([]TopicInfo{
+		{Name: "chat-2", Kind: "user"},
+		{Name: "document", Kind: "table", Permission: 561441, HasPermission: true},
+		{Name: "chat-1", Kind: "user", Permission: 0, HasPermission: true},
+	}, "")
+	var names []string
+	for _, row := range rows {
+		names = append(names, row["topic"].(string))
+	}
+	if !reflect.DeepEqual(names, []string{"document", "chat-1", "chat-2"}) {
+		t.Fatalf("unexpected order: %v", names)
+	}
+	if rows[0]["access"] != FormatPermissionInline(561441) || rows[2]["permission"] != "" {
+		t.Fatalf("unexpected rows: %v", rows)
+	}
+
+	filtered := TopicRows([]TopicInfo{{Name: "chat-1", Kind: "user"}, {Name: "document", Kind: "table"}}, "chat-*")
+	if len(filtered) != 1 || filtered[0]["topic"] != "chat-1" {
+		t.Fatalf("unexpected filtered rows: %v", filtered)
+	}
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daptin/daptin-cli/client"
+	"github.com/urfave/cli/v2"
+)
+
+func wsVerifyCommand(appCtx *AppContext) *cli.Command {
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "Verify PubSub delivery between every pair of cluster nodes",
+		UsageText: `daptin ws verify --endpoints <a,b[,c...]> [flags]
+   daptin ws verify --endpoints http://node1:6336,http://node2:6336
+   daptin ws verify --endpoints http://n1:6336,http://n2:6336,http://n3:6336,http://n4:6336 --rounds 5
+   daptin -o json ws verify --endpoints http://n1:6336,http://n2:6336 --timeout 2s`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     "endpoints",
+				Usage:    "Comma-separated endpoints (at least 2)",
+				Required: true,
+			},
+			&cli.IntFlag{
+				Name:  "rounds",
+				Usage: "Times to repeat the check for every ordered pair",
+				Value: 1,
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Usage: "How long to wait for each delivery",
+				Value: 5 * time.Second,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			endpoints := splitCSV(c.String("endpoints"))
+			if len(endpoints) < 2 {
+				return fmt.Errorf("at least 2 endpoints required, got %d", len(endpoints))
+			}
+			rounds := c.Int("rounds")
+			if rounds < 1 {
+				return fmt.Errorf("--rounds must be at least 1")
+			}
+			slog.Info("ws verify", "endpoints", endpoints, "rounds", rounds)
+
+			// Ctrl-C stops the rounds early; deferred cleanup still runs
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			progress := func(format string, args ...interface{}) {
+				if !appCtx.Quiet {
+					fmt.Fprintf(os.Stderr, format, args...)
+				}
+			}
+
+			topicName := fmt.Sprintf("cluster-verify-%d", time.Now().UnixMilli())
+			nodes := make([]*verifyNode, len(endpoints))
+			for i, endpoint := range endpoints {
+				progress("Connecting to %s... ", endpoint)
+				ws, err := client.DialWebSocket(endpoint, appCtx.Client.AuthToken)
+				if err != nil {
+					slog.Warn("ws verify connect failed", "endpoint", endpoint, "error", err)
+					progress("FAIL (%v)\n", err)
+					nodes[i] = &verifyNode{endpoint: endpoint, err: err}
+					continue
+				}
+				defer ws.Close()
+				nodes[i] = newVerifyNode(endpoint, ws)
+				progress("OK\n")
+			}
+
+			owner := firstConnected(nodes)
+			if owner == nil {
+				return fmt.Errorf("could not connect to any endpoint")
+			}
+			progress("Creating topic %q on %s... ", topicName, owner.endpoint)
+			if err := sendAndWait(owner.ws, "create-topicName", map[string]interface{}{"name": topicName}); err != nil {
+				progress("FAIL\n")
+				return fmt.Errorf("create topic on %s: %w", owner.endpoint, err)
+			}
+			progress("OK\n")
+			defer func() {
+				if err := sendAndWait(owner.ws, "destroy-topicName", map[string]interface{}{"name": topicName}); err != nil {
+					slog.Warn("ws verify cleanup failed", "topic", topicName, "error", err)
+					fmt.Fprintf(os.Stderr, "Warning: could not delete topic %s: %v\n", topicName, err)
+				}
+			}()
+
+			for _, node := range nodes {
+				if node.err != nil {
+					continue
+				}
+				progress("Subscribing on %s... ", node.endpoint)
+				if err := sendAndWait(node.ws, "subscribe", map[string]interface{}{"topicName": topicName}); err != nil {
+					progress("FAIL (%v)\n", err)
+					node.err = fmt.Errorf("subscribe: %w", err)
+					continue
+				}
+				progress("OK\n")
+				go node.collect(ctx)
+			}
+
+			results := make([]VerifyPairResult, 0, len(endpoints)*(len(endpoints)-1))
+			for _, pair := range VerifyPairs(len(endpoints)) {
+				results = append(results, VerifyPairResult{From: endpoints[pair[0]], To: endpoints[pair[1]]})
+			}
+
+		rounds:
+			for round := 1; round <= rounds; round++ {
+				progress("Round %d/%d\n", round, rounds)
+				for i, pair := range VerifyPairs(len(endpoints)) {
+					if ctx.Err() != nil {
+						break rounds
+					}
+					from, to := nodes[pair[0]], nodes[pair[1]]
+					result := &results[i]
+					if from.err != nil || to.err != nil {
+						result.Failures++
+						result.LastError = firstError(from.err, to.err).Error()
+						continue
+					}
+					latency, err := verifyDelivery(ctx, from, to, topicName, fmt.Sprintf("%d-%d-%d", round, pair[0], pair[1]), c.Duration("timeout"))
+					if err != nil {
+						slog.Warn("ws verify delivery failed", "from", from.endpoint, "to", to.endpoint, "error", err)
+						result.Failures++
+						result.LastError = err.Error()
+						continue
+					}
+					result.Latencies = append(result.Latencies, latency)
+				}
+			}
+
+			failed, attempted := 0, 0
+			for _, result := range results {
+				failed += result.Failures
+				attempted += result.Failures + len(result.Latencies)
+			}
+			if appCtx.StructuredOutput() {
+				rows := make([]interface{}, 0, len(results))
+				for _, result := range results {
+					rows = append(rows, result.Row())
+				}
+				if err := appCtx.Renderer.RenderObject(map[string]interface{}{
+					"topic":     topicName,
+					"endpoints": endpoints,
+					"rounds":    rounds,
+					"pairs":     rows,
+					"failures":  failed,
+					"passed":    failed == 0,
+				}); err != nil {
+					return err
+				}
+			} else {
+				fmt.Print(RenderVerifyMatrix(endpoints, results))
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("cluster verify failed: %d of %d deliveries failed", failed, attempted)
+			}
+			progress("Cluster PubSub: PASS\n")
+			return nil
+		},
+	}
+}
+
+// verifyNode is one connected endpoint; collect records when each verify
+// message arrives so deliveries can be awaited by id.
+type verifyNode struct {
+	endpoint string
+	ws       *client.WSConn
+	err      error
+
+	mu      sync.Mutex
+	waiters map[string]chan time.Time
+}
+
+func newVerifyNode(endpoint string, ws *client.WSConn) *verifyNode {
+	return &verifyNode{endpoint: endpoint, ws: ws, waiters: map[string]chan time.Time{}}
+}
+
+func (n *verifyNode) expect(id string) chan time.Time {
+	ch := make(chan time.Time, 1)
+	n.mu.Lock()
+	n.waiters[id] = ch
+	n.mu.Unlock()
+	return ch
+}
+
+func (n *verifyNode) forget(id string) {
+	n.mu.Lock()
+	delete(n.waiters, id)
+	n.mu.Unlock()
+}
+
+func (n *verifyNode) collect(ctx context.Context) {
+	for {
+		msg, err := n.ws.ReadMessageContext(ctx)
+		if err != nil {
+			return
+		}
+		arrived := time.Now()
+		id, ok := verifyMessageID(msg)
+		if !ok {
+			continue
+		}
+		n.mu.Lock()
+		if ch, ok := n.waiters[id]; ok {
+			ch <- arrived
+			delete(n.waiters, id)
+		}
+		n.mu.Unlock()
+	}
+}
+
+func verifyDelivery(ctx context.Context, from, to *verifyNode, topic, id string, timeout time.Duration) (time.Duration, error) {
+	arrival := to.expect(id)
+	defer to.forget(id)
+
+	sentAt := time.Now()
+	// The server only answers new-message on error, so don't wait for a response
+	if _, err := from.ws.SendNoWait("new-message", map[string]interface{}{
+		"topicName": topic,
+		"message":   map[string]interface{}{"verify_id": id, "ts": sentAt.UnixMilli()},
+	}); err != nil {
+		return 0, fmt.Errorf("publish: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case at := <-arrival:
+		return at.Sub(sentAt), nil
+	case <-timer.C:
+		return 0, fmt.Errorf("timeout after %s", timeout)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func verifyMessageID(msg map[string]interface{}) (string, bool) {
+	for _, candidate := range []interface{}{msg["message"], msg} {
+		if m, ok := candidate.(map[string]interface{}); ok {
+			if id, ok := m["verify_id"].(string); ok {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+func sendAndWait(ws *client.WSConn, method string, attrs map[string]interface{}) error {
+	id, err := ws.Send(method, attrs)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = ws.Wait(ctx, id)
+	return err
+}
+
+func firstConnected(nodes []*verifyNode) *verifyNode {
+	for _, node := range nodes {
+		if node.err == nil {
+			return node
+		}
+	}
+	return nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyPairs lists every ordered (from, to) pair of n nodes, from != to.
+// Pure function.
+func VerifyPairs(n int) [][2]int {
+	pairs := make([][2]int, 0, n*(n-1))
+	for from := 0; from < n; from++ {
+		for to := 0; to < n; to++ {
+			if from != to {
+				pairs = append(pairs, [2]int{from, to})
+			}
+		}
+	}
+	return pairs
+}
+
+// VerifyPairResult collects the outcome of every round for one ordered pair.
+type VerifyPairResult struct {
+	From      string
+	To        string
+	Latencies []time.Duration
+	Failures  int
+	LastError string
+}
+
+// Row returns the result as a flat map for JSON output.
+// Pure function.
+func (r VerifyPairResult) Row() map[string]interface{} {
+	row := map[string]interface{}{
+		"from":      r.From,
+		"to":        r.To,
+		"delivered": len(r.Latencies),
+		"failed":    r.Failures,
+	}
+	if len(r.Latencies) > 0 {
+		row["latency_p50_ms"] = millis(Percentile(r.Latencies, 50))
+		row["latency_p95_ms"] = millis(Percentile(r.Latencies, 95))
+		row["latency_max_ms"] = millis(Percentile(r.Latencies, 100))
+	}
+	if r.LastError != "" {
+		row["error"] = r.LastError
+	}
+	return row
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// RenderVerifyMatrix renders a from/to grid of "p50/p95/max" latencies in
+// milliseconds, or FAIL with the failure count. Endpoints are numbered to
+// keep the grid narrow, with a legend underneath.
+// Pure function.
+func RenderVerifyMatrix(endpoints []string, results []VerifyPairResult) string {
+	cells := map[[2]string]string{}
+	for _, r := range results {
+		cell := "FAIL"
+		if r.Failures == 0 && len(r.Latencies) > 0 {
+			cell = fmt.Sprintf("%s/%s/%s", formatMillis(Percentile(r.Latencies, 50)),
+				formatMillis(Percentile(r.Latencies, 95)), formatMillis(Percentile(r.Latencies, 100)))
+		} else if r.Failures > 0 {
+			cell = fmt.Sprintf("FAIL %d/%d", r.Failures, r.Failures+len(r.Latencies))
+		}
+		cells[[2]string{r.From, r.To}] = cell
+	}
+
+	width := len("from\\to")
+	for _, cell := range cells {
+		if len(cell) > width {
+			width = len(cell)
+		}
+	}
+	pad := func(s string) string { return s + strings.Repeat(" ", width-len(s)+2) }
+
+	var sb strings.Builder
+	sb.WriteString("Latency ms (p50/p95/max)\n")
+	sb.WriteString(pad("from\\to"))
+	for i := range endpoints {
+		sb.WriteString(pad(fmt.Sprintf("[%d]", i+1)))
+	}
+	sb.WriteString("\n")
+	for i, from := range endpoints {
+		sb.WriteString(pad(fmt.Sprintf("[%d]", i+1)))
+		for _, to := range endpoints {
+			cell := "-"
+			if from != to {
+				cell = cells[[2]string{from, to}]
+			}
+			sb.WriteString(pad(cell))
+		}
+		sb.WriteString("\n")
+	}
+	for i, endpoint := range endpoints {
+		sb.WriteString(fmt.Sprintf("[%d] %s\n", i+1, endpoint))
+	}
+	for _, r := range results {
+		if r.LastError != "" {
+			sb.WriteString(fmt.Sprintf("%s -> %s: %s\n", r.From, r.To, r.LastError))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyPairs(t *testing.T) {
+	pairs := VerifyPairs(3)
+	if len(pairs) != 6 {
+		t.Fatalf("expected 6 ordered pairs, got %d", len(pairs))
+	}
+	seen := map[[2]int]bool{}
+	for _, pair := range pairs {
+		if pair[0] == pair[1] {
+			t.Fatalf("self pair %v", pair)
+		}
+		seen[pair] = true
+	}
+	if !seen[[2]int{0, 2}] || !seen[[2]int{2, 0}] {
+		t.Fatalf("missing reverse pairs: %v", pairs)
+	}
+	if len(VerifyPairs(1)) != 0 {
+		t.Fatal("expected no pairs for a single node")
+	}
+}
+
+func TestVerifyPairResultRow(t *testing.T) {
+	row := VerifyPairResult{
+		From:      "a",
+		To:        "b",
+		Latencies: []time.Duration{2 * time.Millisecond, 4 * time.Millisecond},
+		Failures:  1,
+		LastError: "timeout after 5s",
+	}.Row()
+	if row["delivered"] != 2 || row["failed"] != 1 || row["error"] != "timeout after 5s" {
+		t.Fatalf("unexpected row: %v", row)
+	}
+	if row["latency_p50_ms"] != 2.0 || row["latency_max_ms"] != 4.0 {
+		t.Fatalf("unexpected latencies: %v", row)
+	}
+
+	empty := VerifyPairResult{From: "a", To: "b", Failures: 1}.Row()
+	if _, ok := empty["latency_p50_ms"]; ok {
+		t.Fatal("expected no latency without deliveries")
+	}
+}
+
+func TestRenderVerifyMatrix(t *testing.T) {
+	endpoints := []string{"http://a", "http://b"}
+	out := RenderVerifyMatrix(endpoints, []VerifyPairResult{
+		{From: "http://a", To: "http://b", Latencies: []time.Duration{3 * time.Millisecond}},
+		{From: "http://b", To: "http://a", Latencies: []time.Duration{time.Millisecond}, Failures: 1, LastError: "timeout after 5s"},
+	})
+	for _, expected := range []string{"3.0/3.0/3.0", "FAIL 1/2", "[1] http://a", "[2] http://b", "http://b -> http://a: timeout after 5s"} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, out)
+		}
+	}
+}
+
+func TestVerifyMessageID(t *testing.T) {
+	id, ok := verifyMessageID(map[string]interface{}{"message": map[string]interface{}{"verify_id": "1-0-1"}})
+	if !ok || id != "1-0-1" {
+		t.Fatalf("unexpected id %q %v", id, ok)
+	}
+	if _, ok := verifyMessageID(map[string]interface{}{"type": "pong"}); ok {
+		t.Fatal("expected no id for unrelated message")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"sort"
 	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
 )
 
 type Renderer interface {
@@ -132,3 +134,30 @@ func (j *JsonRenderer) RenderArray(data []map[string]interface{}) error {
 	fmt.Println(string(jsonData))
 	return nil
 }
+
+// YamlRenderer outputs data as YAML.
+type YamlRenderer struct{}
+
+func NewYamlRenderer() *YamlRenderer {
+	return &YamlRenderer{}
+}
+
+func (y *YamlRenderer) RenderObject(data map[string]interface{}) error {
+	slog.Debug("render yaml object", "columns", len(data))
+	yamlData, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(yamlData))
+	return nil
+}
+
+func (y *YamlRenderer) RenderArray(data []map[string]interface{}) error {
+	slog.Debug("render yaml array", "rows", len(data))
+	yamlData, err := yaml.Marshal(data)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(yamlData))
+	return nil
+}
@@ -91,3 +91,13 @@ func TestFilterColumns_EmptyColumns(t *testing.T) {
 		t.Errorf("expected 0 keys with empty columns, got %d", len(result[0]))
 	}
 }
+
+func TestYamlRenderer_ImplementsRenderer(t *testing.T) {
+	var r Renderer = NewYamlRenderer()
+	if err := r.RenderObject(map[string]interface{}{"name": "doc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenderArray([]map[string]interface{}{{"name": "doc"}}); err != nil {
+		t.Fatal(err)
+	}
+}